@@ -0,0 +1,173 @@
+// Package authtest signs tokens and serves their keys as a local stand-in for an identity provider,
+// so verification of tokens is tested without one.
+package authtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Issuer and Audience are issuer and audience of tokens returned by Claims.
+const (
+	Issuer   = "https://issuer.example"
+	Audience = "boolean-as-service"
+)
+
+// hashes are hashes of signature algorithms by number of bits in their names.
+var hashes = map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+// Key is a private key which signs tokens, with id it has in key set.
+type Key struct {
+	ID     string
+	Signer crypto.Signer
+}
+
+// NewRSAKey generates a new RSA key with given id.
+func NewRSAKey(t *testing.T, id string) Key {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Key{ID: id, Signer: key}
+}
+
+// NewECKey generates a new EC key on curve with given id.
+func NewECKey(t *testing.T, id string, curve elliptic.Curve) Key {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Key{ID: id, Signer: key}
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func encodeCoordinate(i *big.Int, size int) string {
+	return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, size)))
+}
+
+// JWKS returns JSON Web Key Set with public parts of keys.
+func JWKS(keys ...Key) string {
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{Keys: []map[string]string{}}
+	for _, k := range keys {
+		switch key := k.Signer.(type) {
+		case *rsa.PrivateKey:
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA", "kid": k.ID, "use": "sig",
+				"n": encodeInt(key.N), "e": encodeInt(big.NewInt(int64(key.E))),
+			})
+		case *ecdsa.PrivateKey:
+			// Coordinates have the full size of the curve, even when they start with zero bytes.
+			size := (key.Curve.Params().BitSize + 7) / 8
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "EC", "kid": k.ID, "crv": key.Curve.Params().Name,
+				"x": encodeCoordinate(key.X, size), "y": encodeCoordinate(key.Y, size),
+			})
+		}
+	}
+	encoded, _ := json.Marshal(set)
+	return string(encoded)
+}
+
+// Sign returns token with claims, signed by k with algorithm alg, which is one of RS, PS and ES algorithms.
+func Sign(t *testing.T, k Key, alg string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": k.ID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := hashes[alg[len(alg)-3:]]
+	digest := hash.New()
+	digest.Write([]byte(signed))
+	hashed := digest.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch key := k.Signer.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, key, hash, hashed, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, hashed)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, hashed)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		if err == nil {
+			r.FillBytes(signature[:size])
+			s.FillBytes(signature[size:])
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Claims returns valid claims of a token for subject with roles, which expires in an hour.
+func Claims(subject string, roles ...string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": subject,
+		"iss": Issuer,
+		"aud": Audience,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+	return claims
+}
+
+// Server serves a key set over http until the test ends, and counts requests for it.
+type Server struct {
+	URL      string
+	mutex    sync.Mutex
+	keys     []Key
+	requests int
+}
+
+// NewServer returns server of key set with keys.
+func NewServer(t *testing.T, keys ...Key) *Server {
+	s := &Server{keys: keys}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(JWKS(s.keys...)))
+	}))
+	t.Cleanup(server.Close)
+	s.URL = server.URL
+	return s
+}
+
+// SetKeys replaces keys which the server serves.
+func (s *Server) SetKeys(keys ...Key) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = keys
+}
+
+// Requests returns how many times the key set was requested.
+func (s *Server) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
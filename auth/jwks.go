@@ -0,0 +1,210 @@
+// Package auth verifies bearer tokens issued by an identity provider, with keys the provider
+// publishes as a JSON Web Key Set.
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+// DefaultRefresh is how long keys are used before they are loaded again.
+const DefaultRefresh = time.Hour
+
+// maxJWKSSize is maximum size of key set read from a file or URL.
+const maxJWKSSize = 1 << 20
+
+// minReload is how long after the last load keys are not loaded again for a token signed
+// by an unknown key, so such tokens do not make the service load keys on every request.
+var minReload = 10 * time.Second
+
+// jwksClient loads key sets from URLs.
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// parseJWKS reads public keys used for signatures from a JSON Web Key Set. Keys are parsed and checked
+// by go-jose, keys of types other than RSA and EC are left out, and an error is returned when there
+// are no keys left.
+func parseJWKS(data []byte) ([]jose.JSONWebKey, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("key set is not valid JSON: %v", err)
+	}
+
+	keys := []jose.JSONWebKey{}
+	for _, raw := range set.Keys {
+		var header struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return nil, fmt.Errorf("key set is not valid JSON: %v", err)
+		}
+		if (header.Kty != "RSA" && header.Kty != "EC") || (header.Use != "" && header.Use != "sig") {
+			continue
+		}
+
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("key %q is not valid: %v", header.Kid, err)
+		}
+		keys = append(keys, key.Public())
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("key set has no RSA or EC keys for signatures")
+	}
+	return keys, nil
+}
+
+// fits reports whether key may verify signatures made with alg, which is one of RS, PS and ES algorithms.
+func fits(key jose.JSONWebKey, alg jose.SignatureAlgorithm) bool {
+	if key.Algorithm != "" && key.Algorithm != string(alg) {
+		return false
+	}
+	switch k := key.Key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(string(alg), "RS") || strings.HasPrefix(string(alg), "PS")
+	case *ecdsa.PublicKey:
+		curves := map[jose.SignatureAlgorithm]string{jose.ES256: "P-256", jose.ES384: "P-384", jose.ES512: "P-521"}
+		return curves[alg] == k.Curve.Params().Name
+	default:
+		return false
+	}
+}
+
+// JWKS is a JSON Web Key Set read from a file or URL. Keys are loaded again in background once they are
+// older than refresh, and also when a token is signed by a key which is not in the set, so keys rotated
+// by the identity provider are picked up. Keys are never loaded while the mutex is held, so requests
+// with known keys do not wait for a load. It is safe for concurrent use.
+type JWKS struct {
+	location string
+	refresh  time.Duration
+	mutex    sync.RWMutex
+	keys     []jose.JSONWebKey
+	loadedAt time.Time
+	triedAt  time.Time
+	// loading is closed once the load which is running ends, and it is nil when no load runs.
+	loading chan struct{}
+}
+
+// NewJWKS returns key set read from location, which is a http or https URL or path of a file.
+// Keys are loaded at once, so a location which can not be read is reported early.
+func NewJWKS(location string, refresh time.Duration) (*JWKS, error) {
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	s := &JWKS{location: location, refresh: refresh, triedAt: time.Now()}
+
+	keys, err := s.fetch()
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.loadedAt = keys, s.triedAt
+	return s, nil
+}
+
+// read returns key set as it is at location.
+func (s *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(s.location, "http://") && !strings.HasPrefix(s.location, "https://") {
+		return ioutil.ReadFile(s.location)
+	}
+
+	response, err := jwksClient.Get(s.location)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key set was returned with %s status", response.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(response.Body, maxJWKSSize))
+}
+
+// fetch reads and parses keys at location.
+func (s *JWKS) fetch() ([]jose.JSONWebKey, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("can not read key set from %s: %v", s.location, err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("can not read key set from %s: %v", s.location, err)
+	}
+	return keys, nil
+}
+
+// reload starts loading keys in background, unless a load already runs or keys were tried within
+// minReload, and returns channel which is closed once the load ends. It is nil when keys are not loaded.
+// Previous keys are kept when the new ones can not be read.
+func (s *JWKS) reload() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.loading != nil {
+		return s.loading
+	}
+	if time.Since(s.triedAt) <= minReload {
+		return nil
+	}
+	s.triedAt = time.Now()
+	loading := make(chan struct{})
+	s.loading = loading
+
+	go func() {
+		defer close(loading)
+		keys, err := s.fetch()
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if err == nil {
+			s.keys, s.loadedAt = keys, time.Now()
+		}
+		s.loading = nil
+	}()
+	return loading
+}
+
+// candidates returns keys which may have signed a token with given key id and algorithm. Every key fitting
+// the algorithm is a candidate for a token without key id.
+func (s *JWKS) candidates(kid string, alg jose.SignatureAlgorithm) ([]jose.JSONWebKey, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var found []jose.JSONWebKey
+	for _, k := range s.keys {
+		if (kid == "" || k.KeyID == kid) && fits(k, alg) {
+			found = append(found, k)
+		}
+	}
+	return found, time.Since(s.loadedAt) > s.refresh
+}
+
+// find returns keys which may have signed a token with given key id and algorithm. Old keys are loaded
+// again in background, and the token waits for a load only when none of the keys fits it.
+func (s *JWKS) find(kid string, alg jose.SignatureAlgorithm) []jose.JSONWebKey {
+	found, stale := s.candidates(kid, alg)
+	if len(found) > 0 {
+		if stale {
+			s.reload()
+		}
+		return found
+	}
+
+	if loading := s.reload(); loading != nil {
+		<-loading
+		found, _ = s.candidates(kid, alg)
+	}
+	return found
+}
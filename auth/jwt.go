@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+// Leeway is how far clocks of the service and the identity provider may be apart,
+// when times of a token are checked.
+const Leeway = time.Minute
+
+// DefaultSubjectClaim is claim which names the principal of a token.
+const DefaultSubjectClaim = "sub"
+
+// DefaultRolesClaim is claim which lists roles of the principal of a token.
+const DefaultRolesClaim = "roles"
+
+// ErrInvalidToken means token can not be trusted, because it is malformed, expired, or its signature,
+// issuer or audience is not the expected one. It is wrapped with the reason.
+var ErrInvalidToken = errors.New("token is not valid")
+
+// Claims are claims of a token, as they are in its payload.
+type Claims map[string]interface{}
+
+// algorithms are supported signature algorithms. Tokens without signature, and tokens signed
+// with a shared secret, are never accepted.
+var algorithms = map[jose.SignatureAlgorithm]bool{
+	jose.RS256: true, jose.RS384: true, jose.RS512: true,
+	jose.PS256: true, jose.PS384: true, jose.PS512: true,
+	jose.ES256: true, jose.ES384: true, jose.ES512: true,
+}
+
+// Identity is principal of a verified token, with its roles.
+type Identity struct {
+	Subject string
+	Roles   []string
+	Claims  Claims
+}
+
+// Verifier verifies tokens signed by keys of Keys, and maps their claims to an Identity.
+// Issuer and Audience are checked only when they are set. Claims are names of claims in payload,
+// and a name with dots, like realm_access.roles, looks into nested objects.
+type Verifier struct {
+	Keys         *JWKS
+	Issuer       string
+	Audience     string
+	SubjectClaim string
+	RolesClaim   string
+}
+
+// invalid returns ErrInvalidToken with given reason.
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, fmt.Sprintf(format, args...))
+}
+
+// Verify checks signature and times of token, along with its issuer and audience, and returns its claims.
+// Signature is checked by go-jose. Every failure is an ErrInvalidToken.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	// Only compact serialization is a token, and it is checked before parsing, so a malformed token
+	// is rejected early.
+	if strings.Count(token, ".") != 2 {
+		return nil, invalid("token must have three parts")
+	}
+	signed, err := jose.ParseSigned(token)
+	if err != nil || len(signed.Signatures) != 1 {
+		return nil, invalid("token is malformed")
+	}
+	header := signed.Signatures[0].Header
+	alg := jose.SignatureAlgorithm(header.Algorithm)
+	if !algorithms[alg] {
+		return nil, invalid("algorithm %q is not supported", header.Algorithm)
+	}
+
+	var payload []byte
+	for _, k := range v.Keys.find(header.KeyID, alg) {
+		if payload, err = signed.Verify(k); err == nil {
+			break
+		}
+	}
+	if payload == nil {
+		return nil, invalid("signature does not match any key")
+	}
+
+	claims := Claims{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, invalid("payload is malformed")
+	}
+	if err := v.check(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// check returns ErrInvalidToken when claims are not valid at given time, or are not meant for the verifier.
+func (v *Verifier) check(claims Claims, at time.Time) error {
+	exp, ok := claims.time("exp")
+	if !ok {
+		return invalid("exp claim is missing")
+	}
+	if !at.Before(exp.Add(Leeway)) {
+		return invalid("token expired")
+	}
+	if nbf, ok := claims.time("nbf"); ok && at.Add(Leeway).Before(nbf) {
+		return invalid("token is not valid yet")
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return invalid("issuer is not %q", v.Issuer)
+		}
+	}
+	if v.Audience != "" && !contains(claims.strings("aud"), v.Audience) {
+		return invalid("audience is not %q", v.Audience)
+	}
+	return nil
+}
+
+// Authenticate verifies token and returns identity of its principal, which is named by SubjectClaim.
+func (v *Verifier) Authenticate(token string) (Identity, error) {
+	claims, err := v.Verify(token)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	subjectClaim := v.SubjectClaim
+	if subjectClaim == "" {
+		subjectClaim = DefaultSubjectClaim
+	}
+	rolesClaim := v.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = DefaultRolesClaim
+	}
+
+	subject, _ := claims.lookup(subjectClaim).(string)
+	if subject == "" {
+		return Identity{}, invalid("%s claim is missing", subjectClaim)
+	}
+	return Identity{Subject: subject, Roles: claims.strings(rolesClaim), Claims: claims}, nil
+}
+
+// lookup returns value of claim with given name, where dots separate names of nested objects.
+func (c Claims) lookup(name string) interface{} {
+	var value interface{} = map[string]interface{}(c)
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// strings returns claim with given name as a list of strings. A string claim is split on spaces,
+// like the scope claim is, and values which are not strings are left out.
+func (c Claims) strings(name string) []string {
+	switch value := c.lookup(name).(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// time returns claim with given name as a time in seconds since epoch, and false when it is not a number.
+func (c Claims) time(name string) (time.Time, bool) {
+	number, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64((seconds-whole)*float64(time.Second))), true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifierFromEnv returns verifier configured by environment variables, and nil when neither JWKS_URL
+// nor JWKS_FILE is set. JWT_ISSUER and JWT_AUDIENCE are checked when they are set, JWT_SUBJECT_CLAIM
+// and JWT_ROLES_CLAIM name claims of the principal, and JWKS_REFRESH_INTERVAL is how often keys are loaded again.
+func VerifierFromEnv() (*Verifier, error) {
+	location := os.Getenv("JWKS_URL")
+	if file := os.Getenv("JWKS_FILE"); file != "" {
+		if location != "" {
+			return nil, errors.New("only one of JWKS_URL and JWKS_FILE can be set")
+		}
+		location = file
+	}
+	if location == "" {
+		return nil, nil
+	}
+
+	refresh := DefaultRefresh
+	if value := os.Getenv("JWKS_REFRESH_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("JWKS_REFRESH_INTERVAL=%q is not a positive duration", value)
+		}
+		refresh = parsed
+	}
+
+	keys, err := NewJWKS(location, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{
+		Keys:         keys,
+		Issuer:       os.Getenv("JWT_ISSUER"),
+		Audience:     os.Getenv("JWT_AUDIENCE"),
+		SubjectClaim: os.Getenv("JWT_SUBJECT_CLAIM"),
+		RolesClaim:   os.Getenv("JWT_ROLES_CLAIM"),
+	}, nil
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/elliptic"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hrishi32/boolean-as-service/auth/authtest"
+)
+
+func newVerifier(t *testing.T, location string) *Verifier {
+	keys, err := NewJWKS(location, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Verifier{Keys: keys, Issuer: authtest.Issuer, Audience: authtest.Audience}
+}
+
+func assertInvalid(t *testing.T, err error) bool {
+	return assert.True(t, errors.Is(err, ErrInvalidToken), "expected ErrInvalidToken, got %v", err)
+}
+
+func TestVerifyAlgorithms(t *testing.T) {
+	rsaKey := authtest.NewRSAKey(t, "rsa")
+	ecKeys := map[string]authtest.Key{
+		"ES256": authtest.NewECKey(t, "p256", elliptic.P256()),
+		"ES384": authtest.NewECKey(t, "p384", elliptic.P384()),
+		"ES512": authtest.NewECKey(t, "p521", elliptic.P521()),
+	}
+	server := authtest.NewServer(t, rsaKey, ecKeys["ES256"], ecKeys["ES384"], ecKeys["ES512"])
+	verifier := newVerifier(t, server.URL)
+
+	for _, alg := range []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"} {
+		key, ok := ecKeys[alg]
+		if !ok {
+			key = rsaKey
+		}
+		verified, err := verifier.Verify(authtest.Sign(t, key, alg, authtest.Claims("alice")))
+		if assert.NoError(t, err, alg) {
+			assert.Equal(t, "alice", verified["sub"], alg)
+		}
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	key := authtest.NewRSAKey(t, "rsa")
+	other := authtest.NewRSAKey(t, "rsa")
+	server := authtest.NewServer(t, key)
+	verifier := newVerifier(t, server.URL)
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := authtest.Claims("alice")
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	valid := authtest.Sign(t, key, "RS256", authtest.Claims("alice"))
+	parts := strings.Split(valid, ".")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-token"},
+		{"without signature", unsigned},
+		{"signed by another key", authtest.Sign(t, other, "RS256", authtest.Claims("alice"))},
+		{"changed payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`)) + "." + parts[2]},
+		{"expired", authtest.Sign(t, key, "RS256", with("exp", time.Now().Add(-2*Leeway).Unix()))},
+		{"without expiry", authtest.Sign(t, key, "RS256", with("exp", nil))},
+		{"not valid yet", authtest.Sign(t, key, "RS256", with("nbf", time.Now().Add(2*Leeway).Unix()))},
+		{"another issuer", authtest.Sign(t, key, "RS256", with("iss", "https://other.example"))},
+		{"another audience", authtest.Sign(t, key, "RS256", with("aud", []string{"other"}))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(tt.token)
+			assertInvalid(t, err)
+		})
+	}
+
+	// Times within leeway and audience among several are accepted.
+	_, err := verifier.Verify(authtest.Sign(t, key, "RS256", with("exp", time.Now().Add(-Leeway/2).Unix())))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(authtest.Sign(t, key, "RS256", with("aud", []string{"other", authtest.Audience})))
+	assert.NoError(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	key := authtest.NewECKey(t, "ec", elliptic.P256())
+	server := authtest.NewServer(t, key)
+	verifier := newVerifier(t, server.URL)
+
+	claims := authtest.Claims("alice", "write", "flags-admin")
+	identity, err := verifier.Authenticate(authtest.Sign(t, key, "ES256", claims))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", identity.Subject)
+	assert.Equal(t, []string{"write", "flags-admin"}, identity.Roles)
+
+	// Claims are configurable, and may be nested or space separated.
+	verifier.SubjectClaim = "email"
+	verifier.RolesClaim = "realm_access.roles"
+	claims["email"] = "alice@example.com"
+	claims["realm_access"] = map[string]interface{}{"roles": "read write"}
+	identity, err = verifier.Authenticate(authtest.Sign(t, key, "ES256", claims))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", identity.Subject)
+	assert.Equal(t, []string{"read", "write"}, identity.Roles)
+
+	delete(claims, "email")
+	_, err = verifier.Authenticate(authtest.Sign(t, key, "ES256", claims))
+	assertInvalid(t, err)
+}
+
+func TestJWKSFile(t *testing.T) {
+	key := authtest.NewRSAKey(t, "")
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(file, []byte(authtest.JWKS(key)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Token without key id is checked against every key of the set.
+	_, err = newVerifier(t, file).Verify(authtest.Sign(t, key, "RS256", authtest.Claims("alice")))
+	assert.NoError(t, err)
+
+	for _, set := range []string{`not json`, `{"keys": []}`, `{"keys": [{"kty": "RSA", "n": "!", "e": "AQAB"}]}`} {
+		if err := ioutil.WriteFile(file, []byte(set), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err = NewJWKS(file, time.Hour)
+		assert.Error(t, err, set)
+	}
+	_, err = NewJWKS(filepath.Join(dir, "missing.json"), time.Hour)
+	assert.Error(t, err)
+}
+
+func TestJWKSRotation(t *testing.T) {
+	defer func(previous time.Duration) { minReload = previous }(minReload)
+	minReload = 0
+
+	old := authtest.NewRSAKey(t, "old")
+	rotated := authtest.NewRSAKey(t, "new")
+	server := authtest.NewServer(t, old)
+	verifier := newVerifier(t, server.URL)
+	_, err := verifier.Verify(authtest.Sign(t, old, "RS256", authtest.Claims("alice")))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, server.Requests())
+
+	// Token signed by an unknown key makes keys load again.
+	server.SetKeys(rotated)
+	_, err = verifier.Verify(authtest.Sign(t, rotated, "RS256", authtest.Claims("alice")))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.Requests())
+
+	// Keys are not loaded again more often than minReload.
+	minReload = time.Hour
+	_, err = verifier.Verify(authtest.Sign(t, old, "RS256", authtest.Claims("alice")))
+	assertInvalid(t, err)
+	assert.Equal(t, 2, server.Requests())
+}
+
+func TestJWKSLoadDoesNotBlock(t *testing.T) {
+	defer func(previous time.Duration) { minReload = previous }(minReload)
+	minReload = 0
+
+	known := authtest.NewRSAKey(t, "known")
+	unknown := authtest.NewRSAKey(t, "unknown")
+	loading := make(chan struct{}, 1)
+	release := make(chan struct{})
+	blocked := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if blocked {
+			loading <- struct{}{}
+			<-release
+		}
+		w.Write([]byte(authtest.JWKS(known)))
+	}))
+	defer server.Close()
+	verifier := newVerifier(t, server.URL)
+	blocked = true
+
+	// Token signed by an unknown key waits for keys to load.
+	failed := make(chan error)
+	go func() {
+		_, err := verifier.Verify(authtest.Sign(t, unknown, "RS256", authtest.Claims("alice")))
+		failed <- err
+	}()
+	<-loading
+
+	// Token signed by a known key is verified meanwhile.
+	verified := make(chan error)
+	go func() {
+		_, err := verifier.Verify(authtest.Sign(t, known, "RS256", authtest.Claims("alice")))
+		verified <- err
+	}()
+	select {
+	case err := <-verified:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("expected token signed by a known key to be verified while keys load")
+	}
+
+	close(release)
+	assertInvalid(t, <-failed)
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// APIKeyHeader is header which carries API key of a request. The key may also be given
+// as a bearer token in Authorization header.
+const APIKeyHeader = "X-API-Key"
+
+// MinAdminKeyLength is minimum number of characters in admin key given by SetAdminKey.
+const MinAdminKeyLength = 16
+
+// adminKeyName is name of the API key set by SetAdminKey, which stored keys can not have.
+const adminKeyName = models.AdminAPIKeyName
+
+// adminKeyHash is hash of the admin key, which is empty when there is no admin key.
+var adminKeyHash string
+
+// apiKeyRequest is body of POST request for a new API key.
+type apiKeyRequest struct {
+	Name   string         `json:"name" binding:"required"`
+	Scopes []models.Scope `json:"scopes" binding:"required"`
+}
+
+// SetAdminKey sets API key with admin scope which is not stored, so the first stored keys can be created
+// with it. Empty key removes the admin key.
+func SetAdminKey(key string) error {
+	if key != "" && len(key) < MinAdminKeyLength {
+		return fmt.Errorf("admin key must be at least %d characters long", MinAdminKeyLength)
+	}
+	adminKeyHash = ""
+	if key != "" {
+		adminKeyHash = models.HashAPIKey(key)
+	}
+	return nil
+}
+
+// authenticateAPIKey returns principal of API key given by client, which is either the admin key or a stored one.
+func authenticateAPIKey(key string) (Principal, error) {
+	hash := models.HashAPIKey(key)
+	if adminKeyHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(adminKeyHash)) == 1 {
+		return Principal{Type: PrincipalAPIKey, Name: adminKeyName, Scopes: models.Scopes{models.ScopeAdmin}}, nil
+	}
+
+	apiKey, err := models.GetRepo().AuthenticateAPIKey(key)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Type: PrincipalAPIKey, ID: apiKey.ID.String(), Name: apiKey.Name, Scopes: apiKey.Scopes}, nil
+}
+
+// apiKeyBody returns JSON body of API key k, which never has the key itself.
+func apiKeyBody(k models.APIKey) gin.H {
+	body := gin.H{
+		"id":         k.ID,
+		"name":       k.Name,
+		"prefix":     k.Prefix,
+		"scopes":     k.Scopes,
+		"created_at": k.CreatedAt,
+		"rotated_at": nil,
+		"revoked_at": nil,
+	}
+	if k.RotatedAt.Valid {
+		body["rotated_at"] = k.RotatedAt.Time
+	}
+	if k.RevokedAt.Valid {
+		body["revoked_at"] = k.RevokedAt.Time
+	}
+	return body
+}
+
+// secretBody returns JSON body of API key k along with the key itself, which is shown only once.
+func secretBody(k models.APIKey, secret string) gin.H {
+	body := apiKeyBody(k)
+	body["key"] = secret
+	return body
+}
+
+// ListAPIKeysHandler handles GET request for every API key by using model's APIKeys method.
+func ListAPIKeysHandler(c *gin.Context) {
+	keys, databaseError := models.GetRepo().APIKeys()
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	bodies := make([]gin.H, 0, len(keys))
+	for _, k := range keys {
+		bodies = append(bodies, apiKeyBody(k))
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": bodies})
+}
+
+// CreateAPIKeyHandler handles POST request for a new API key by using model's CreateAPIKey method.
+// The key itself is returned only in this response.
+func CreateAPIKeyHandler(c *gin.Context) {
+	var request apiKeyRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	k, secret, databaseError := models.GetRepo().CreateAPIKey(models.APIKey{Name: request.Name, Scopes: request.Scopes})
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, secretBody(k, secret))
+}
+
+// RotateAPIKeyHandler handles POST request to rotate endpoint by using model's RotateAPIKey method.
+// The new key is returned only in this response, and the old one stops working at once.
+func RotateAPIKeyHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	k, secret, databaseError := models.GetRepo().RotateAPIKey(id)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, secretBody(k, secret))
+}
+
+// RevokeAPIKeyHandler handles DELETE request for an API key by using model's RevokeAPIKey method.
+// Revoked key is still listed, but it does not authenticate anymore.
+func RevokeAPIKeyHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	k, databaseError := models.GetRepo().RevokeAPIKey(id)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKeyBody(k))
+}
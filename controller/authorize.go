@@ -0,0 +1,355 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// roleKey is key of role of the principal in namespace of the request in gin context.
+const roleKey = "role"
+
+// Action is what a request does, and it is allowed by a role or a scope of the same rank.
+type Action string
+
+// Actions of requests, from the narrowest one.
+const (
+	// ActionRead reads booleans, and it needs viewer role.
+	ActionRead Action = "read"
+	// ActionWrite creates, changes and deletes booleans, and it needs editor role.
+	ActionWrite Action = "write"
+	// ActionAdmin purges booleans, and manages roles and access lists, and it needs admin role.
+	ActionAdmin Action = "admin"
+)
+
+// role returns role which allows the action, and empty role for an unknown action.
+func (a Action) role() models.Role {
+	return models.Scope(a).Role()
+}
+
+// Resource is what a request acts on. It is the whole service when Namespace is empty, and a boolean
+// of the namespace when ID is set.
+type Resource struct {
+	Namespace string
+	ID        uuid.UUID
+}
+
+// Decision is outcome of authorization of an action. Role is role of the principal on the resource,
+// which is empty when it has none, and Reason tells how it was decided.
+type Decision struct {
+	Allowed bool
+	Role    models.Role
+	Reason  string
+}
+
+// higher reports whether role allows more than other does.
+func higher(role models.Role, other models.Role) bool {
+	return role.Allow(other) && role != other
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeRole returns the highest role which scopes grant in every namespace, along with the scope granting it.
+func scopeRole(scopes models.Scopes) (models.Role, models.Scope) {
+	var role models.Role
+	var granting models.Scope
+	for _, scope := range scopes {
+		if higher(scope.Role(), role) {
+			role, granting = scope.Role(), scope
+		}
+	}
+	return role, granting
+}
+
+// namespaceRole returns the highest role of principal in namespace, granted either by its scopes
+// or by a role binding of any of its subjects, and tells where it comes from.
+func namespaceRole(principal Principal, namespace string) (models.Role, string, error) {
+	role, scope := scopeRole(principal.Scopes)
+	reason := fmt.Sprintf("%s has %s scope, which makes it %s in every namespace", principal, scope, role)
+	if role == "" {
+		reason = fmt.Sprintf("%s has no scope", principal)
+	}
+	if namespace == "" {
+		return role, reason, nil
+	}
+
+	bindings, err := models.GetRepo().RoleBindings(namespace)
+	if err != nil && !errors.Is(err, models.ErrNamespaceNotFound) {
+		return "", "", err
+	}
+	subjects := principal.Subjects()
+	for _, binding := range bindings {
+		if contains(subjects, binding.Subject) && higher(binding.Role, role) {
+			role = binding.Role
+			reason = fmt.Sprintf("%s is %s in namespace %s by role binding of %s", principal, role, namespace, binding.Subject)
+		}
+	}
+	if role == "" {
+		reason = fmt.Sprintf("%s has neither a scope nor a role in namespace %s", principal, namespace)
+	}
+	return role, reason, nil
+}
+
+// accessListAllows reports whether access list of boolean with id in namespace lets principal change it,
+// which an empty list does for everyone. Deleted boolean keeps its access list, so it is restored only by
+// subjects on the list, and boolean which is not found at all has no access list.
+func accessListAllows(principal Principal, namespace string, id uuid.UUID) (bool, string, error) {
+	repo := models.GetRepo().InNamespace(namespace)
+	subjects, err := repo.AccessList(id)
+	if errors.Is(err, models.ErrNotFound) {
+		subjects, err = repo.DeletedAccessList(id)
+	}
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return false, "", err
+	}
+	if len(subjects) == 0 {
+		return true, "", nil
+	}
+	for _, subject := range principal.Subjects() {
+		if contains(subjects, subject) {
+			return true, fmt.Sprintf("access list of boolean %s lists %s", id, subject), nil
+		}
+	}
+	return false, fmt.Sprintf("access list of boolean %s lists none of %s, and only admins may change it otherwise", id, strings.Join(principal.Subjects(), ", ")), nil
+}
+
+// Decide decides whether principal may take action on resource. Scopes grant a role in every namespace,
+// and role bindings grant roles in a single one. Service resources are allowed only by scopes. Editors change
+// a boolean with an access list only when the list has any of their subjects, while admins change every boolean.
+func Decide(principal Principal, action Action, resource Resource) (Decision, error) {
+	required := action.role()
+	if !required.Valid() {
+		return Decision{}, fmt.Errorf("%w: action %q must be one of %s, %s, %s", models.ErrValidation, action, ActionRead, ActionWrite, ActionAdmin)
+	}
+
+	role, reason, err := namespaceRole(principal, resource.Namespace)
+	if err != nil {
+		return Decision{}, err
+	}
+	decision := Decision{Allowed: role.Allow(required), Role: role, Reason: reason}
+	if !decision.Allowed {
+		decision.Reason = fmt.Sprintf("%s, and %s needs %s role", reason, action, required)
+		return decision, nil
+	}
+
+	if required == models.RoleEditor && role != models.RoleAdmin && resource.ID != uuid.Nil {
+		allowed, listReason, err := accessListAllows(principal, resource.Namespace, resource.ID)
+		if err != nil {
+			return Decision{}, err
+		}
+		if listReason != "" {
+			decision.Allowed = allowed
+			decision.Reason = reason + ", and " + listReason
+		}
+	}
+	return decision, nil
+}
+
+// authorize decides whether principal of the request may take action on resource, and responds with 403 status
+// when it may not. Field names what the request was rejected for in the response.
+func authorize(c *gin.Context, action Action, resource Resource, field string) bool {
+	principal, _ := PrincipalOf(c)
+	decision, err := Decide(principal, action, resource)
+	if err != nil {
+		HandleError(c, err)
+		return false
+	}
+	if !decision.Allowed {
+		Handle403(c, &FieldError{Field: field, Message: decision.Reason})
+		return false
+	}
+	if resource.Namespace != "" {
+		c.Set(roleKey, decision.Role)
+	}
+	return true
+}
+
+// requestedBoolean returns id of the boolean in path of the request, given either as its id or its key.
+// It is uuid.Nil when the path has no boolean, or has one which does not exist yet.
+func requestedBoolean(c *gin.Context) (uuid.UUID, error) {
+	if id, err := uuid.Parse(c.Param("id")); err == nil {
+		return id, nil
+	}
+	if key := c.Param("key"); key != "" {
+		b, err := namespacedRepo(c).GetByKey(key)
+		if errors.Is(err, models.ErrNotFound) {
+			return uuid.Nil, nil
+		}
+		return b.ID, err
+	}
+	return uuid.Nil, nil
+}
+
+// Authorize is a middleware which lets through only requests whose principal may take action in namespace
+// of the request, and on the boolean in its path when it changes one. Other requests get 403 status.
+// It must be used after Authenticate, and after Namespace for paths with a namespace.
+func Authorize(action Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource := Resource{Namespace: namespaceOf(c)}
+		if action == ActionWrite {
+			id, err := requestedBoolean(c)
+			if err != nil {
+				HandleError(c, err)
+				return
+			}
+			resource.ID = id
+		}
+		if authorize(c, action, resource, "principal") {
+			c.Next()
+		}
+	}
+}
+
+// RequireScope is a middleware which lets through only requests whose principal has scope, for routes which
+// are not about a single namespace. Other requests get 403 status. It must be used after Authenticate.
+func RequireScope(scope models.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authorize(c, Action(scope), Resource{}, "principal") {
+			c.Next()
+		}
+	}
+}
+
+// checkAccessLists responds with 403 status when any of operations changes a boolean whose access list
+// does not let principal of the request change it. Only requests authorized for a namespace are checked,
+// and admins of the namespace change every boolean.
+func checkAccessLists(c *gin.Context, operations []models.Operation) bool {
+	value, ok := c.Get(roleKey)
+	if !ok || value.(models.Role) == models.RoleAdmin {
+		return true
+	}
+	for i, operation := range operations {
+		if operation.Type == models.OperationCreate {
+			continue
+		}
+		if !authorize(c, ActionWrite, Resource{Namespace: namespaceOf(c), ID: operation.ID}, fmt.Sprintf("operations[%d]", i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// policyRequest is body of request to evaluate policy. Subject is the principal of the request when it is
+// missing, and Roles are roles of a token subject. Namespace is models.DefaultNamespace when it is missing.
+type policyRequest struct {
+	Subject   string   `json:"subject"`
+	Roles     []string `json:"roles"`
+	Action    Action   `json:"action" binding:"required"`
+	Namespace string   `json:"namespace"`
+	ID        string   `json:"id"`
+}
+
+// subjectPrincipal returns principal which subject of a token or an API key would be. Token subject has
+// given roles, and API key subject has scopes of every key with its name which is not revoked.
+func subjectPrincipal(subject string, roles []string) (Principal, error) {
+	if err := models.ValidateSubject(subject); err != nil {
+		return Principal{}, err
+	}
+	parts := strings.SplitN(subject, ":", 2)
+	kind, name := parts[0], parts[1]
+	switch kind {
+	case PrincipalToken:
+		return Principal{Type: PrincipalToken, ID: name, Name: name, Roles: roles, Scopes: scopesOf(roles)}, nil
+	case PrincipalAPIKey:
+		principal := Principal{Type: PrincipalAPIKey, Name: name}
+		if adminKeyHash != "" && name == adminKeyName {
+			principal.Scopes = models.Scopes{models.ScopeAdmin}
+		}
+		keys, err := models.GetRepo().APIKeys()
+		if err != nil {
+			return Principal{}, err
+		}
+		found := principal.Scopes != nil
+		for _, key := range keys {
+			if key.Name == name && !key.Revoked() {
+				principal.Scopes = append(principal.Scopes, key.Scopes...)
+				found = true
+			}
+		}
+		if !found {
+			return Principal{}, models.ErrAPIKeyNotFound
+		}
+		return principal, nil
+	default:
+		return Principal{}, fmt.Errorf("%w: subject must be a token or an API key", models.ErrValidation)
+	}
+}
+
+// EvaluatePolicyHandler handles POST request which asks whether a subject may take an action in a namespace,
+// or on a boolean of it, and answers with the decision and its reason. Every principal may ask about itself,
+// and admins of the namespace may ask about any token or API key.
+func EvaluatePolicyHandler(c *gin.Context) {
+	var request policyRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+	if !request.Action.role().Valid() {
+		Handle400(c, &FieldError{Field: "action", Message: "must be one of read, write, admin"})
+		return
+	}
+	resource := Resource{Namespace: request.Namespace}
+	if resource.Namespace == "" {
+		resource.Namespace = models.DefaultNamespace
+	}
+	if err := models.ValidateNamespace(resource.Namespace); err != nil {
+		Handle400(c, &FieldError{Field: "namespace", Message: "must be a valid namespace", Err: err})
+		return
+	}
+	if request.ID != "" {
+		id, err := uuid.Parse(request.ID)
+		if err != nil {
+			Handle400(c, &FieldError{Field: "id", Message: "must be a valid uuid"})
+			return
+		}
+		resource.ID = id
+	}
+
+	principal, _ := PrincipalOf(c)
+	if request.Subject != "" && request.Subject != principal.String() {
+		if !authorize(c, ActionAdmin, Resource{Namespace: resource.Namespace}, "subject") {
+			return
+		}
+		var err error
+		if principal, err = subjectPrincipal(request.Subject, request.Roles); err != nil {
+			HandleError(c, err)
+			return
+		}
+	}
+
+	decision, err := Decide(principal, request.Action, resource)
+	if err != nil {
+		HandleError(c, err)
+		return
+	}
+
+	body := gin.H{
+		"subject":   principal.String(),
+		"action":    request.Action,
+		"namespace": resource.Namespace,
+		"id":        nil,
+		"allowed":   decision.Allowed,
+		"role":      nil,
+		"reason":    decision.Reason,
+	}
+	if resource.ID != uuid.Nil {
+		body["id"] = resource.ID
+	}
+	if decision.Role != "" {
+		body["role"] = decision.Role
+	}
+	c.JSON(http.StatusOK, body)
+}
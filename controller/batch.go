@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// batchGetRequest is body of batch get request, booleans are requested by ids, keys or both.
+type batchGetRequest struct {
+	IDs  []string `json:"ids"`
+	Keys []string `json:"keys"`
+}
+
+// BatchGetHandler handles POST request for many booleans at once by using model's GetMany method.
+// Response has a result for each requested id and then for each key, in order of request,
+// and a boolean which is not found is reported in its result instead of failing the request.
+func BatchGetHandler(c *gin.Context) {
+	var request batchGetRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	if count := len(request.IDs) + len(request.Keys); count == 0 || count > models.MaxGetMany {
+		Handle400(c, &FieldError{Field: "body", Message: "must request from 1 to " + strconv.Itoa(models.MaxGetMany) + " ids and keys"})
+		return
+	}
+
+	ids := make([]uuid.UUID, len(request.IDs))
+	for i, requested := range request.IDs {
+		id, err := uuid.Parse(requested)
+		if err != nil {
+			Handle400(c, &FieldError{Field: "ids[" + strconv.Itoa(i) + "]", Message: "must be a valid uuid"})
+			return
+		}
+		ids[i] = id
+	}
+	for i, key := range request.Keys {
+		if key == "" {
+			Handle400(c, &FieldError{Field: "keys[" + strconv.Itoa(i) + "]", Message: "must not be empty"})
+			return
+		}
+	}
+
+	booleans, databaseError := namespacedRepo(c).GetMany(ids, request.Keys)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	byID := map[uuid.UUID]models.Boolean{}
+	byKey := map[string]models.Boolean{}
+	for _, b := range booleans {
+		byID[b.ID] = b
+		if b.Key != "" {
+			byKey[b.Key] = b
+		}
+	}
+
+	results := make([]gin.H, 0, len(ids)+len(request.Keys))
+	for _, id := range ids {
+		b, ok := byID[id]
+		if !ok {
+			results = append(results, gin.H{"status": http.StatusNotFound, "id": id, "error": itemError(notFound)})
+			continue
+		}
+		result := booleanBody(b)
+		result["status"] = http.StatusOK
+		results = append(results, result)
+	}
+	for _, key := range request.Keys {
+		b, ok := byKey[key]
+		if !ok {
+			results = append(results, gin.H{"status": http.StatusNotFound, "key": key, "error": itemError(notFound)})
+			continue
+		}
+		result := booleanBody(b)
+		result["status"] = http.StatusOK
+		results = append(results, result)
+	}
+
+	c.JSON(200, gin.H{"results": results})
+}
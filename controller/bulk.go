@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// Modes of bulk request. All-or-nothing is the default.
+const (
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best_effort"
+)
+
+// bulkRequest is body of bulk request.
+type bulkRequest struct {
+	Mode       string          `json:"mode"`
+	Operations []bulkOperation `json:"operations"`
+}
+
+// bulkOperation is a single operation of bulk request. Update changes only value and key
+// which are given, and empty key removes the key.
+type bulkOperation struct {
+	Op      string  `json:"op"`
+	ID      string  `json:"id"`
+	Value   *bool   `json:"value"`
+	Key     *string `json:"key"`
+	Version int64   `json:"version"`
+}
+
+// operation validates o and converts it to models.Operation. Field is name of o in request.
+func (o bulkOperation) operation(field string) (models.Operation, error) {
+	operation := models.Operation{Type: models.OperationType(o.Op), Version: o.Version}
+	if o.Version < models.AnyVersion {
+		return operation, &FieldError{Field: field + ".version", Message: "must not be negative"}
+	}
+
+	if operation.Type == models.OperationCreate {
+		if o.Value == nil {
+			return operation, &FieldError{Field: field + ".value", Message: "failed on required rule"}
+		}
+		operation.Boolean = models.Boolean{Value: *o.Value}
+		if o.Key != nil {
+			operation.Boolean.Key = *o.Key
+		}
+		return operation, nil
+	}
+
+	if operation.Type != models.OperationUpdate && operation.Type != models.OperationDelete {
+		return operation, &FieldError{Field: field + ".op", Message: "must be one of create, update, delete"}
+	}
+
+	id, err := uuid.Parse(o.ID)
+	if err != nil {
+		return operation, &FieldError{Field: field + ".id", Message: "must be a valid uuid"}
+	}
+	operation.ID = id
+	operation.Patch = models.BooleanPatch{Value: o.Value, Key: o.Key}
+	return operation, nil
+}
+
+// operationResult returns result of a single operation in response of bulk request.
+func operationResult(c *gin.Context, operation models.Operation, result models.OperationResult) gin.H {
+	if result.Err != nil {
+		kind := errorKindOf(result.Err)
+		if kind == internalError || kind == unavailable {
+			c.Error(result.Err)
+		}
+		return gin.H{"status": kind.status, "error": itemError(kind)}
+	}
+
+	b := result.Boolean
+	switch operation.Type {
+	case models.OperationCreate:
+		body := booleanBody(b)
+		body["status"] = http.StatusCreated
+		return body
+	case models.OperationDelete:
+		return gin.H{"status": http.StatusNoContent, "id": b.ID}
+	default:
+		body := booleanBody(b)
+		body["status"] = http.StatusOK
+		return body
+	}
+}
+
+// BulkHandler handles POST request with many create, update and delete operations by using model's Bulk method.
+// In atomic mode either all operations are applied or none, and failure of an operation fails the whole request.
+// In best_effort mode each operation succeeds or fails alone. Response lists result of each operation in order.
+// Request which changes a boolean whose access list does not let the principal change it is rejected as a whole.
+func BulkHandler(c *gin.Context) {
+	var request bulkRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	atomic := true
+	switch request.Mode {
+	case "", bulkModeAtomic:
+	case bulkModeBestEffort:
+		atomic = false
+	default:
+		Handle400(c, &FieldError{Field: "mode", Message: "must be one of atomic, best_effort"})
+		return
+	}
+
+	if len(request.Operations) == 0 || len(request.Operations) > models.MaxBulkOperations {
+		Handle400(c, &FieldError{Field: "operations", Message: "must have from 1 to " + strconv.Itoa(models.MaxBulkOperations) + " operations"})
+		return
+	}
+
+	operations := make([]models.Operation, len(request.Operations))
+	for i, o := range request.Operations {
+		operation, operationError := o.operation("operations[" + strconv.Itoa(i) + "]")
+		if operationError != nil {
+			Handle400(c, operationError)
+			return
+		}
+		operations[i] = operation
+	}
+	if !checkAccessLists(c, operations) {
+		return
+	}
+
+	results, databaseError := auditedRepo(c).Bulk(operations, atomic)
+	var operationError *models.OperationError
+	if errors.As(databaseError, &operationError) {
+		HandleError(c, &FieldError{
+			Field:   "operations[" + strconv.Itoa(operationError.Index) + "]",
+			Message: errorKindOf(operationError.Err).message,
+			Err:     operationError.Err,
+		})
+		return
+	}
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	response := make([]gin.H, len(results))
+	for i, result := range results {
+		response[i] = operationResult(c, operations[i], result)
+	}
+	c.JSON(200, gin.H{"results": response})
+}
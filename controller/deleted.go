@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/jobs"
+)
+
+// purgeRequest is optional body of purge request. OlderThan is a duration like "24h",
+// and retention configured for background purge is used when it is empty.
+type purgeRequest struct {
+	OlderThan string `json:"older_than"`
+}
+
+// RestoreHandler handles POST request to restore endpoint by using model's Restore method.
+// It brings back a deleted boolean with its key as a new version, and returns it.
+func RestoreHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	b, databaseError := auditedRepo(c).Restore(id)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
+}
+
+// PurgeHandler handles POST request to purge endpoint by using model's Purge method.
+// It removes booleans deleted longer than given time ago for good, and returns how many were removed.
+func PurgeHandler(c *gin.Context) {
+	var request purgeRequest
+	if c.Request.ContentLength != 0 {
+		bindError := c.ShouldBindJSON(&request)
+		if bindError != nil {
+			Handle400(c, bindError)
+			return
+		}
+	}
+
+	retention := jobs.PurgeRetention()
+	if request.OlderThan != "" {
+		parsed, err := time.ParseDuration(request.OlderThan)
+		if err != nil || parsed < 0 {
+			Handle400(c, &FieldError{Field: "older_than", Message: "must be a duration like 24h"})
+			return
+		}
+		retention = parsed
+	}
+
+	purged, databaseError := namespacedRepo(c).Purge(time.Now().Add(-retention))
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// EnvironmentHeader is header which selects environment whose value of a boolean is returned by GET.
+// Environment given in path takes precedence over it.
+const EnvironmentHeader = "X-Environment"
+
+// promoteRequest is body of promote request. To is the environment after from when it is missing.
+type promoteRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to"`
+}
+
+// environmentField returns FieldError of field when environment is not one of models.Environments.
+func environmentField(field string, environment string) error {
+	if err := models.ValidateEnvironment(environment); err != nil {
+		return &FieldError{Field: field, Message: "must be one of " + strings.Join(models.Environments(), ", "), Err: err}
+	}
+	return nil
+}
+
+// requestedEnvironment returns environment of the request, given in path or in EnvironmentHeader.
+// It is empty when request does not select an environment. Response varies by EnvironmentHeader
+// unless environment is in path, so caches keep value of each environment apart.
+func requestedEnvironment(c *gin.Context) (string, error) {
+	environment := c.Param("environment")
+	if environment == "" {
+		c.Header("Vary", EnvironmentHeader)
+		environment = c.GetHeader(EnvironmentHeader)
+	}
+	if environment == "" {
+		return "", nil
+	}
+	return environment, environmentField("environment", environment)
+}
+
+// environmentBody returns JSON body of b as it is in environment, or as it is stored when environment is empty.
+func environmentBody(b models.Boolean, environment string) gin.H {
+	if environment == "" {
+		return booleanBody(b)
+	}
+	body := booleanBody(b.In(environment))
+	body["environment"] = environment
+	return body
+}
+
+// CompareEnvironmentsHandler handles GET request for values of a boolean in every environment by using model's Get method.
+// Environments are listed in order values are promoted through them, and same tells whether all of them have the same value.
+func CompareEnvironmentsHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	b, databaseError := namespacedRepo(c).Get(id)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	environments := make([]gin.H, 0, len(models.Environments()))
+	first := b.In(models.Environments()[0]).Value
+	same := true
+	for _, name := range models.Environments() {
+		_, own := b.Environments[name]
+		value := b.In(name).Value
+		same = same && value == first
+		environments = append(environments, gin.H{"name": name, "value": value, "own": own})
+	}
+
+	setETag(c, b.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"id":           b.ID,
+		"version":      b.Version,
+		"value":        b.Value,
+		"environments": environments,
+		"same":         same,
+	})
+}
+
+// PromoteHandler handles POST request to promote endpoint by using model's Promote method.
+// It gives environment to the value which the boolean has in environment from, as a single change.
+func PromoteHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	var request promoteRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+	if err := environmentField("from", request.From); err != nil {
+		Handle400(c, err)
+		return
+	}
+	if request.To != "" {
+		if err := environmentField("to", request.To); err != nil {
+			Handle400(c, err)
+			return
+		}
+	}
+
+	version, versionError := ifMatchVersion(c, id)
+	if versionError != nil {
+		HandleError(c, versionError)
+		return
+	}
+
+	b, databaseError := auditedRepo(c).Promote(id, request.From, request.To, version)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	setETag(c, b.Version)
+	c.JSON(http.StatusOK, booleanBody(b))
+}
+
+// patchEnvironments reads changes of environment values from raw JSON object, where null removes value
+// of an environment. Null instead of the object removes values of every environment.
+func patchEnvironments(raw json.RawMessage) (models.EnvironmentPatch, error) {
+	var environments *models.EnvironmentPatch
+	if err := json.Unmarshal(raw, &environments); err != nil {
+		return nil, errors.New("must be object of bools or null")
+	}
+	if environments == nil {
+		patch := models.EnvironmentPatch{}
+		for _, name := range models.Environments() {
+			patch[name] = nil
+		}
+		return patch, nil
+	}
+	return *environments, nil
+}
+
+// environmentPath returns environment whose value JSON Patch path points at, like /environments/prod.
+func environmentPath(path string) (string, bool) {
+	environment := strings.TrimPrefix(path, "/environments/")
+	return environment, environment != path && environment != ""
+}
+
+// copyEnvironments returns a copy of environments, which can be changed without changing environments.
+func copyEnvironments(environments models.EnvironmentValues) models.EnvironmentValues {
+	copied := models.EnvironmentValues{}
+	for name, value := range environments {
+		copied[name] = value
+	}
+	return copied
+}
+
+// environmentChanges returns patch which changes environment values from to to.
+func environmentChanges(from models.EnvironmentValues, to models.EnvironmentValues) models.EnvironmentPatch {
+	patch := models.EnvironmentPatch{}
+	for name := range from {
+		if _, ok := to[name]; !ok {
+			patch[name] = nil
+		}
+	}
+	for name, value := range to {
+		value := value
+		patch[name] = &value
+	}
+	return patch
+}
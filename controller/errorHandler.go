@@ -1,48 +1,249 @@
 package controller
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/hrishi32/boolean-as-service/models"
 )
 
+func init() {
+	// Report JSON names of invalid fields instead of names of struct fields.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// ErrorResponse is body of every failure response.
+// Code is stable and meant for machines, Message is meant for humans.
+// Current is the stored boolean, for conflicts where client needs to know it.
+type ErrorResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id"`
+	Details   []FieldError `json:"details,omitempty"`
+	Current   gin.H        `json:"current,omitempty"`
+}
+
+// FieldError describes why a single field of request was rejected.
+// Err is the cause, when the field was rejected by storage.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// respondError aborts request with given status and ErrorResponse.
+func respondError(c *gin.Context, status int, code string, message string, details []FieldError) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: GetRequestID(c),
+		Details:   details,
+	})
+}
+
 // Handle400 handles bad request error
 func Handle400(c *gin.Context, err error) {
-	c.Writer.WriteHeader(400)
+	details := fieldErrors(err)
+	message := "Request is not valid"
+	if len(details) == 0 && errors.Is(err, models.ErrValidation) {
+		message = err.Error()
+	}
+
+	respondError(c, http.StatusBadRequest, "BAD_REQUEST", message, details)
+}
+
+// errorKind is status, code and message of an error response.
+type errorKind struct {
+	status  int
+	code    string
+	message string
+}
+
+// Kinds of error responses which do not depend on details of the error.
+var (
+	notFound            = errorKind{http.StatusNotFound, "NOT_FOUND", "Boolean not found"}
+	namespaceNotFound   = errorKind{http.StatusNotFound, "NAMESPACE_NOT_FOUND", "Namespace not found"}
+	apiKeyNotFound      = errorKind{http.StatusNotFound, "API_KEY_NOT_FOUND", "API key not found"}
+	roleBindingNotFound = errorKind{http.StatusNotFound, "ROLE_BINDING_NOT_FOUND", "Subject has no role in the namespace"}
+	versionNotFound     = errorKind{http.StatusNotFound, "VERSION_NOT_FOUND", "Version not found in history of the boolean"}
+	unauthorized        = errorKind{http.StatusUnauthorized, "UNAUTHORIZED", "API key or token is missing or not valid"}
+	forbidden           = errorKind{http.StatusForbidden, "FORBIDDEN", "Principal of the request is not allowed to make it"}
+	conflict            = errorKind{http.StatusConflict, "CONFLICT", "Request conflicts with stored data"}
+	duplicateKey        = errorKind{http.StatusConflict, "DUPLICATE_KEY", "Key is already used by another boolean"}
+	quotaExceeded       = errorKind{http.StatusConflict, "QUOTA_EXCEEDED", "Namespace has as many booleans as its quota allows"}
+	notDeleted          = errorKind{http.StatusConflict, "NOT_DELETED", "Boolean is not deleted"}
+	scheduleNotPending  = errorKind{http.StatusConflict, "SCHEDULE_NOT_PENDING", "Schedule was already applied or canceled"}
+	apiKeyRevoked       = errorKind{http.StatusConflict, "API_KEY_REVOKED", "API key is revoked"}
+	preconditionFailed  = errorKind{http.StatusPreconditionFailed, "PRECONDITION_FAILED", "Boolean was changed since the given version"}
+	internalError       = errorKind{http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error"}
+	unavailable         = errorKind{http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Storage is unavailable, try again later"}
+)
+
+// errorKindOf maps an error returned by Repo to kind of error response.
+// Errors unknown to models are treated as internal server errors.
+func errorKindOf(err error) errorKind {
+	switch {
+	case errors.Is(err, models.ErrNamespaceNotFound):
+		return namespaceNotFound
+	case errors.Is(err, models.ErrAPIKeyNotFound):
+		return apiKeyNotFound
+	case errors.Is(err, models.ErrRoleBindingNotFound):
+		return roleBindingNotFound
+	case errors.Is(err, models.ErrVersionNotFound):
+		return versionNotFound
+	case errors.Is(err, models.ErrNotFound):
+		return notFound
+	case errors.Is(err, models.ErrValidation):
+		return errorKind{http.StatusBadRequest, "BAD_REQUEST", err.Error()}
+	case errors.Is(err, models.ErrVersionMismatch):
+		return preconditionFailed
+	case errors.Is(err, models.ErrDuplicateKey):
+		return duplicateKey
+	case errors.Is(err, models.ErrQuotaExceeded):
+		return quotaExceeded
+	case errors.Is(err, models.ErrNotDeleted):
+		return notDeleted
+	case errors.Is(err, models.ErrScheduleNotPending):
+		return scheduleNotPending
+	case errors.Is(err, models.ErrAPIKeyRevoked):
+		return apiKeyRevoked
+	case errors.Is(err, models.ErrConflict):
+		return conflict
+	case errors.Is(err, models.ErrUnavailable):
+		return unavailable
+	default:
+		return internalError
+	}
+}
+
+// itemError returns error of a single item in response about many booleans, which does not fail the whole request.
+func itemError(kind errorKind) gin.H {
+	return gin.H{
+		"code":    kind.code,
+		"message": kind.message,
+	}
 }
 
 // Handle404 Handles content not found error
 func Handle404(c *gin.Context, err error) {
-	c.Writer.WriteHeader(404)
+	respondError(c, notFound.status, notFound.code, notFound.message, fieldErrors(err))
+}
+
+// Handle401 handles requests without a valid API key or token.
+func Handle401(c *gin.Context, err error) {
+	respondError(c, unauthorized.status, unauthorized.code, unauthorized.message, fieldErrors(err))
+}
+
+// Handle403 handles requests whose principal is not allowed to make them.
+func Handle403(c *gin.Context, err error) {
+	respondError(c, forbidden.status, forbidden.code, forbidden.message, fieldErrors(err))
+}
+
+// Handle409 handles conflict with the current state of a boolean
+func Handle409(c *gin.Context, err error) {
+	respondError(c, conflict.status, conflict.code, conflict.message, fieldErrors(err))
+}
+
+// HandleValueMismatch handles compare-and-swap conflicts, and returns the current boolean.
+func HandleValueMismatch(c *gin.Context, current models.Boolean) {
+	c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse{
+		Code:      "VALUE_MISMATCH",
+		Message:   "Boolean does not have the expected value",
+		RequestID: GetRequestID(c),
+		Current:   booleanBody(current),
+	})
+}
+
+// Handle412 handles requests whose If-Match does not match current version of a boolean
+func Handle412(c *gin.Context, err error) {
+	respondError(c, preconditionFailed.status, preconditionFailed.code, preconditionFailed.message, fieldErrors(err))
 }
 
 // Handle500 handles internal server error
 func Handle500(c *gin.Context, err error) {
-	c.Writer.WriteHeader(500)
-}
-
-// DatabaseError handles all errors from database, including connection errors and record not found errors.
-// func DatabaseError(c *gin.Context, err error) {
-// 	// fmt.Println("Error from database", err)
-// 	// log.Fatal(err)
-// 	c.JSON(http.StatusInternalServerError, gin.H{
-// 		"message": "Error from database",
-// 		"error":   err.Error(),
-// 	})
-// }
-
-// // ParseError handles errors regarding parsing uuid from requests.
-// func ParseError(c *gin.Context, err error) {
-// 	fmt.Println("Error while parsing uuid from request", err)
-// 	c.JSON(http.StatusBadRequest, gin.H{
-// 		"message": "Error while parsing uuid from request",
-// 		"error":   err.Error(),
-// 	})
-// }
-
-// // BindError handles JSON binding errors.
-// func BindError(c *gin.Context, err error) {
-// 	fmt.Println("Error while binding JSON into struct", err)
-// 	c.JSON(http.StatusBadRequest, gin.H{
-// 		"message": "Error while binding JSON into struct",
-// 		"error":   err.Error(),
-// 	})
-// }
+	c.Error(err)
+	respondError(c, internalError.status, internalError.code, internalError.message, nil)
+}
+
+// Handle503 handles errors when storage is unavailable
+func Handle503(c *gin.Context, err error) {
+	c.Error(err)
+	respondError(c, unavailable.status, unavailable.code, unavailable.message, fieldErrors(err))
+}
+
+// HandleNoRoute handles requests to paths which do not exist.
+func HandleNoRoute(c *gin.Context) {
+	respondError(c, http.StatusNotFound, "PAGE_NOT_FOUND", "Page not found", nil)
+}
+
+// HandleError maps an error returned by Repo to matching status code.
+// Errors unknown to models are treated as internal server errors.
+func HandleError(c *gin.Context, err error) {
+	switch kind := errorKindOf(err); {
+	case kind == internalError:
+		Handle500(c, err)
+	case kind == unavailable:
+		Handle503(c, err)
+	case kind.status == http.StatusBadRequest:
+		Handle400(c, err)
+	default:
+		respondError(c, kind.status, kind.code, kind.message, fieldErrors(err))
+	}
+}
+
+// fieldErrors lists fields rejected by id parsing, JSON binding or validation.
+func fieldErrors(err error) []FieldError {
+	var fieldError *FieldError
+	if errors.As(err, &fieldError) {
+		return []FieldError{*fieldError}
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return []FieldError{{Field: typeError.Field, Message: "must be " + typeError.Type.String()}}
+	}
+
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return []FieldError{{Field: "body", Message: "must be valid JSON"}}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return []FieldError{{Field: "body", Message: "must not be empty"}}
+	}
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		details := make([]FieldError, 0, len(validationErrors))
+		for _, e := range validationErrors {
+			details = append(details, FieldError{Field: e.Field(), Message: "failed on " + e.Tag() + " rule"})
+		}
+		return details
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// entityTag is a single entity tag from If-Match or If-None-Match header. Environment is set for a tag
+// of value of the boolean in that environment.
+type entityTag struct {
+	weak        bool
+	version     int64
+	environment string
+}
+
+// setETag returns version of boolean in ETag header.
+func setETag(c *gin.Context, version int64) {
+	setEnvironmentETag(c, version, "")
+}
+
+// setEnvironmentETag returns version of boolean in ETag header, along with environment whose value is returned,
+// like "3-prod", so values of different environments never share a tag.
+func setEnvironmentETag(c *gin.Context, version int64, environment string) {
+	tag := strconv.FormatInt(version, 10)
+	if environment != "" {
+		tag += "-" + environment
+	}
+	c.Header("ETag", `"`+tag+`"`)
+}
+
+// parseETags reads list of entity tags from header. Tags which are not versions of this service are skipped.
+// any is true for "*".
+func parseETags(header string) (tags []entityTag, any bool) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		// Versions have no dash, and environments do not start with one.
+		parts := strings.SplitN(tag[1:len(tag)-1], "-", 2)
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || version < models.FirstVersion {
+			continue
+		}
+		parsed := entityTag{weak: weak, version: version}
+		if len(parts) == 2 {
+			parsed.environment = parts[1]
+		}
+		tags = append(tags, parsed)
+	}
+	return tags, false
+}
+
+// ifMatchVersion returns version required by If-Match header, for storage to check it along with the change.
+// It is models.AnyVersion when header is missing or "*". When header lists several versions,
+// current version of boolean is used if it is one of them. Tags of a single environment require their version,
+// as a change applies to the whole boolean.
+func ifMatchVersion(c *gin.Context, id uuid.UUID) (int64, error) {
+	return ifMatch(c, func() (models.Boolean, error) {
+		return namespacedRepo(c).Get(id)
+	})
+}
+
+// ifMatch is ifMatchVersion for boolean which is read by current, when it is needed.
+func ifMatch(c *gin.Context, current func() (models.Boolean, error)) (int64, error) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return models.AnyVersion, nil
+	}
+
+	tags, any := parseETags(header)
+	if any {
+		return models.AnyVersion, nil
+	}
+
+	// If-Match uses strong comparison, so weak tags never match.
+	var versions []int64
+	for _, tag := range tags {
+		if !tag.weak {
+			versions = append(versions, tag.version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, models.ErrVersionMismatch
+	case 1:
+		return versions[0], nil
+	}
+
+	b, err := current()
+	if err != nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == b.Version {
+			return version, nil
+		}
+	}
+	return 0, models.ErrVersionMismatch
+}
+
+// checkReadPreconditions evaluates If-Match and If-None-Match headers of a read of boolean in environment,
+// which is empty for the whole boolean, against its current version. It responds and returns false when
+// request must not continue.
+func checkReadPreconditions(c *gin.Context, b models.Boolean, environment string) bool {
+	if header := c.GetHeader("If-Match"); header != "" {
+		tags, any := parseETags(header)
+		matches := any
+		for _, tag := range tags {
+			matches = matches || (!tag.weak && tag.version == b.Version && tag.environment == environment)
+		}
+		if !matches {
+			Handle412(c, models.ErrVersionMismatch)
+			return false
+		}
+	}
+
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		tags, any := parseETags(header)
+		matches := any
+		// If-None-Match uses weak comparison.
+		for _, tag := range tags {
+			matches = matches || (tag.version == b.Version && tag.environment == environment)
+		}
+		if matches {
+			setEnvironmentETag(c, b.Version, environment)
+			c.AbortWithStatus(http.StatusNotModified)
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// booleanBody returns JSON body of boolean b. Timestamps, metadata, expires_at and fallback
+// are left out when the boolean does not have them.
+func booleanBody(b models.Boolean) gin.H {
+	body := gin.H{
+		"id":    b.ID,
+		"value": b.Value,
+		"key":   b.Key,
+	}
+	if !b.CreatedAt.IsZero() {
+		body["created_at"] = b.CreatedAt
+	}
+	if !b.UpdatedAt.IsZero() {
+		body["updated_at"] = b.UpdatedAt
+	}
+	if b.Description != "" {
+		body["description"] = b.Description
+	}
+	if b.Owner != "" {
+		body["owner"] = b.Owner
+	}
+	if len(b.Tags) > 0 {
+		body["tags"] = b.Tags
+	}
+	if len(b.Environments) > 0 {
+		body["environments"] = b.Environments
+	}
+	if b.ExpiresAt.Valid {
+		body["expires_at"] = b.ExpiresAt.Time
+	}
+	if b.Fallback.Valid {
+		body["fallback"] = b.Fallback.Bool
+	}
+	return body
+}
+
+// postRequest is body of POST request. Expiry is given either as expires_at time in RFC 3339 format
+// or as ttl duration from now, and fallback is value the boolean gets once it expires.
+// Environments are values of environments which differ from value.
+type postRequest struct {
+	Value        bool            `json:"value"`
+	Key          string          `json:"key"`
+	Description  string          `json:"description"`
+	Owner        string          `json:"owner"`
+	Tags         []string        `json:"tags"`
+	Environments map[string]bool `json:"environments"`
+	ExpiresAt    *time.Time      `json:"expires_at"`
+	TTL          string          `json:"ttl"`
+	Fallback     *bool           `json:"fallback"`
+}
+
+// boolean returns the boolean which request creates.
+func (r postRequest) boolean() (models.Boolean, error) {
+	b := models.Boolean{Value: r.Value, Key: r.Key, Description: r.Description, Owner: r.Owner, Tags: r.Tags, Environments: r.Environments}
+
+	if r.ExpiresAt != nil && r.TTL != "" {
+		return b, &FieldError{Field: "ttl", Message: "must not be given along with expires_at"}
+	}
+	if r.ExpiresAt != nil {
+		b.ExpiresAt = sql.NullTime{Time: *r.ExpiresAt, Valid: true}
+	}
+	if r.TTL != "" {
+		expiresAt, err := parseTTL(r.TTL)
+		if err != nil {
+			return b, &FieldError{Field: "ttl", Message: err.Error()}
+		}
+		b.ExpiresAt = sql.NullTime{Time: expiresAt, Valid: true}
+	}
+
+	// Expiry is returned as it is stored, in UTC and with microsecond precision.
+	b.ExpiresAt.Time = b.ExpiresAt.Time.UTC().Truncate(time.Microsecond)
+
+	if r.Fallback != nil {
+		if !b.ExpiresAt.Valid {
+			return b, &FieldError{Field: "fallback", Message: "requires expires_at or ttl"}
+		}
+		b.Fallback = sql.NullBool{Bool: *r.Fallback, Valid: true}
+	}
+	return b, nil
+}
+
+// parseTTL returns time when time to live given as duration, like "90s" or "1h30m", passes.
+func parseTTL(ttl string) (time.Time, error) {
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration <= 0 {
+		return time.Time{}, errors.New("must be a positive duration like 1h30m")
+	}
+	return time.Now().Add(duration), nil
+}
+
+// patchExpiresAt reads new expiry from raw JSON time in RFC 3339 format, null removes expiry.
+func patchExpiresAt(raw json.RawMessage) (sql.NullTime, error) {
+	var expiresAt *time.Time
+	if err := json.Unmarshal(raw, &expiresAt); err != nil {
+		return sql.NullTime{}, errors.New("must be a time in RFC 3339 format or null")
+	}
+	if expiresAt == nil {
+		return sql.NullTime{}, nil
+	}
+	return sql.NullTime{Time: *expiresAt, Valid: true}, nil
+}
+
+// patchTTL reads new expiry from raw JSON duration, it can not be null.
+func patchTTL(raw json.RawMessage) (sql.NullTime, error) {
+	var ttl *string
+	if err := json.Unmarshal(raw, &ttl); err != nil || ttl == nil {
+		return sql.NullTime{}, errors.New("must be a positive duration like 1h30m")
+	}
+	expiresAt, err := parseTTL(*ttl)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: expiresAt, Valid: true}, nil
+}
+
+// patchFallback reads new fallback from raw JSON, null removes fallback.
+func patchFallback(raw json.RawMessage) (sql.NullBool, error) {
+	var fallback *bool
+	if err := json.Unmarshal(raw, &fallback); err != nil {
+		return sql.NullBool{}, errors.New("must be bool or null")
+	}
+	if fallback == nil {
+		return sql.NullBool{}, nil
+	}
+	return sql.NullBool{Bool: *fallback, Valid: true}, nil
+}
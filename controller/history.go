@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// ActorHeader is header which names who makes a change, recorded in history of booleans.
+// It is recorded as given by client, along with principal which authenticated the request.
+const ActorHeader = "X-Actor"
+
+// anonymousActor is recorded in history for changes made without ActorHeader.
+const anonymousActor = "anonymous"
+
+// historyCursor is content of opaque cursor returned to client for the next page of history.
+type historyCursor struct {
+	Before uint64 `json:"b"`
+}
+
+// auditedRepo returns repo of namespace of the request, which records changes made by the request
+// with its actor, principal and request id.
+func auditedRepo(c *gin.Context) models.Repo {
+	repo := namespacedRepo(c)
+	auditable, ok := repo.(models.Auditable)
+	if !ok {
+		return repo
+	}
+
+	actor := c.GetHeader(ActorHeader)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	audit := models.Audit{Actor: actor, RequestID: GetRequestID(c)}
+	if principal, ok := PrincipalOf(c); ok {
+		audit.Principal = principal.String()
+	}
+	return auditable.WithAudit(audit)
+}
+
+// historyOptions reads page of history from query of request.
+func historyOptions(c *gin.Context) (models.HistoryOptions, error) {
+	options := models.HistoryOptions{Limit: models.DefaultHistoryLimit}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > models.MaxHistoryLimit {
+			return options, &FieldError{Field: "limit", Message: "must be a number from 1 to " + strconv.Itoa(models.MaxHistoryLimit)}
+		}
+		options.Limit = parsed
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		var content historyCursor
+		encoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err == nil {
+			err = json.Unmarshal(encoded, &content)
+		}
+		if err != nil || content.Before == 0 {
+			return options, &FieldError{Field: "cursor", Message: "is not valid"}
+		}
+		options.Before = content.Before
+	}
+
+	return options, nil
+}
+
+// HistoryHandler handles GET request for changes of a boolean by using model's History method.
+// It returns a page of changes, newest first, and next_cursor to get the next page with, unless it is the last page.
+func HistoryHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	options, parseError := historyOptions(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	page, databaseError := namespacedRepo(c).History(id, options)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	entries := make([]gin.H, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		body := gin.H{
+			"version":    entry.Version,
+			"action":     entry.Action,
+			"old_value":  entry.OldValue,
+			"new_value":  entry.NewValue,
+			"old_key":    entry.OldKey,
+			"new_key":    entry.NewKey,
+			"actor":      entry.Actor,
+			"request_id": entry.RequestID,
+			"at":         entry.CreatedAt,
+		}
+		// Principal is left out of entries recorded before requests were authenticated.
+		if entry.Principal != "" {
+			body["principal"] = entry.Principal
+		}
+		// Environment values are left out along with booleans which have none, same as in body of a boolean.
+		if len(entry.OldEnvironments) > 0 {
+			body["old_environments"] = entry.OldEnvironments
+		}
+		if len(entry.NewEnvironments) > 0 {
+			body["new_environments"] = entry.NewEnvironments
+		}
+		entries = append(entries, body)
+	}
+
+	response := gin.H{"id": id, "history": entries}
+	if page.Next != 0 {
+		encoded, _ := json.Marshal(historyCursor{Before: page.Next})
+		response["next_cursor"] = base64.RawURLEncoding.EncodeToString(encoded)
+	}
+	c.JSON(200, response)
+}
+
+// getAt responds with boolean with id as it was at time given in RFC 3339 format, by using model's GetAt method.
+// Value is the one the boolean had in environment, unless it is empty.
+// An earlier state is not the current version, so no ETag is returned.
+func getAt(c *gin.Context, id uuid.UUID, at string, environment string) {
+	moment, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		Handle400(c, &FieldError{Field: "at", Message: "must be a time in RFC 3339 format"})
+		return
+	}
+
+	b, databaseError := namespacedRepo(c).GetAt(id, moment)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	body := gin.H{
+		"id":      b.ID,
+		"value":   b.In(environment).Value,
+		"key":     b.Key,
+		"version": b.Version,
+	}
+	if len(b.Environments) > 0 {
+		body["environments"] = b.Environments
+	}
+	if environment != "" {
+		body["environment"] = environment
+	}
+	c.JSON(200, body)
+}
+
+// rollbackRequest is body of rollback request, with version of the boolean to restore.
+type rollbackRequest struct {
+	Version *int64 `json:"version" binding:"required"`
+}
+
+// RollbackHandler handles POST request to rollback endpoint by using model's Rollback method.
+// It restores value and key of an earlier version as a new version, and returns the updated boolean.
+func RollbackHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	var request rollbackRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+	if *request.Version < models.FirstVersion {
+		Handle400(c, &FieldError{Field: "version", Message: "must be at least " + strconv.FormatInt(models.FirstVersion, 10)})
+		return
+	}
+
+	version, versionError := ifMatchVersion(c, id)
+	if versionError != nil {
+		HandleError(c, versionError)
+		return
+	}
+
+	b, databaseError := auditedRepo(c).Rollback(id, *request.Version, version)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// keyPutRequest is body of PUT request by key, the key itself comes from path.
+type keyPutRequest struct {
+	Value *bool `json:"value" binding:"required"`
+}
+
+// GetByKeyHandler handles GET request for a boolean by its key, using model's GetByKey method.
+// With EnvironmentHeader it returns value of the boolean in that environment.
+func GetByKeyHandler(c *gin.Context) {
+	environment, parseError := requestedEnvironment(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	b, databaseError := namespacedRepo(c).GetByKey(c.Param("key"))
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	if !checkReadPreconditions(c, b, environment) {
+		return
+	}
+
+	setEnvironmentETag(c, b.Version, environment)
+	c.JSON(200, environmentBody(b, environment))
+}
+
+// PutByKeyHandler handles PUT request for a boolean by its key, using model's UpsertByKey method.
+// It replaces value of the boolean, or creates a new one with 201 status when there is no boolean with the key.
+func PutByKeyHandler(c *gin.Context) {
+	key := c.Param("key")
+
+	var request keyPutRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	version, versionError := ifMatch(c, func() (models.Boolean, error) {
+		return namespacedRepo(c).GetByKey(key)
+	})
+	if versionError != nil {
+		HandleError(c, versionError)
+		return
+	}
+
+	b, created, databaseError := auditedRepo(c).UpsertByKey(key, models.Boolean{Value: *request.Value}, version)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	setETag(c, b.Version)
+	c.JSON(status, booleanBody(b))
+}
+
+// DeleteByKeyHandler handles DELETE request for a boolean by its key, using model's GetByKey and Delete methods.
+func DeleteByKeyHandler(c *gin.Context) {
+	b, databaseError := namespacedRepo(c).GetByKey(c.Param("key"))
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	version, versionError := ifMatch(c, func() (models.Boolean, error) {
+		return b, nil
+	})
+	if versionError != nil {
+		HandleError(c, versionError)
+		return
+	}
+
+	snapshot := false
+	if version == models.AnyVersion {
+		// Boolean is deleted only if it was not changed since it was found, so it still has the key.
+		version = b.Version
+		snapshot = true
+	}
+
+	databaseError = auditedRepo(c).Delete(b.ID, version)
+	if snapshot && errors.Is(databaseError, models.ErrVersionMismatch) {
+		// Client did not ask for a version, so it is a conflict with concurrent change.
+		Handle409(c, databaseError)
+		return
+	}
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
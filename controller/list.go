@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// listCursor is content of opaque cursor returned to client. Sort is kept in it,
+// so a cursor can not be used with a listing of different order.
+type listCursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	Key       string    `json:"k,omitempty"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// encodeCursor returns opaque cursor for position in a listing of given sort.
+func encodeCursor(sort string, cursor *models.Cursor) string {
+	content := listCursor{Sort: sort, ID: cursor.ID}
+	if strings.TrimPrefix(sort, "-") == string(models.SortByKey) {
+		content.Key = cursor.Key
+	} else {
+		content.CreatedAt = cursor.CreatedAt
+	}
+
+	encoded, _ := json.Marshal(content)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor reads cursor given by client, which must come from a listing of given sort.
+func decodeCursor(sort string, cursor string) (*models.Cursor, error) {
+	var content listCursor
+	encoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(encoded, &content)
+	}
+	if err != nil {
+		return nil, &FieldError{Field: "cursor", Message: "is not valid"}
+	}
+	if content.Sort != sort {
+		return nil, &FieldError{Field: "cursor", Message: "does not match sort"}
+	}
+
+	return &models.Cursor{CreatedAt: content.CreatedAt, Key: content.Key, ID: content.ID}, nil
+}
+
+// listOptions reads filters, sort order and page of listing from query of request.
+// Sort is field name, with "-" prefix for descending order.
+func listOptions(c *gin.Context) (models.ListOptions, string, error) {
+	options := models.ListOptions{Limit: models.DefaultListLimit}
+
+	if value, ok := c.GetQuery("value"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, "", &FieldError{Field: "value", Message: "must be bool"}
+		}
+		options.Value = &parsed
+	}
+
+	if key, ok := c.GetQuery("key"); ok {
+		options.Key = &key
+	}
+	options.KeyPrefix = c.Query("key_prefix")
+
+	if owner, ok := c.GetQuery("owner"); ok {
+		options.Owner = &owner
+	}
+	if tags, ok := c.GetQueryArray("tag"); ok {
+		options.Tags = tags
+	}
+
+	sort := c.DefaultQuery("sort", string(models.SortByCreated))
+	options.Descending = strings.HasPrefix(sort, "-")
+	switch models.SortOrder(strings.TrimPrefix(sort, "-")) {
+	case models.SortByCreated:
+		options.Sort = models.SortByCreated
+	case models.SortByKey:
+		options.Sort = models.SortByKey
+	default:
+		return options, "", &FieldError{Field: "sort", Message: "must be one of created_at, -created_at, key, -key"}
+	}
+
+	if limit, ok := c.GetQuery("limit"); ok {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > models.MaxListLimit {
+			return options, "", &FieldError{Field: "limit", Message: "must be a number from 1 to " + strconv.Itoa(models.MaxListLimit)}
+		}
+		options.Limit = parsed
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := decodeCursor(sort, cursor)
+		if err != nil {
+			return options, "", err
+		}
+		options.After = after
+	}
+
+	return options, sort, nil
+}
+
+// ListHandler handles GET request for all booleans by using model's List method.
+// It returns a page of booleans, and next_cursor to get the next page with, unless it is the last page.
+func ListHandler(c *gin.Context) {
+	options, sort, parseError := listOptions(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	page, databaseError := namespacedRepo(c).List(options)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	booleans := make([]gin.H, 0, len(page.Booleans))
+	for _, b := range page.Booleans {
+		booleans = append(booleans, booleanBody(b))
+	}
+
+	response := gin.H{"booleans": booleans}
+	if page.Next != nil {
+		response["next_cursor"] = encodeCursor(sort, page.Next)
+	}
+	c.JSON(200, response)
+}
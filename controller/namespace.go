@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// namespaceKey is key of namespace of the request in gin context.
+const namespaceKey = "namespace"
+
+// namespaceRequest is body of PUT request for a namespace. Quota is models.DefaultMaxBooleans
+// when max_booleans is missing, and zero means no quota.
+type namespaceRequest struct {
+	MaxBooleans *int64 `json:"max_booleans"`
+}
+
+// Namespace is a middleware which checks namespace given in path of request, so handlers
+// after it read and change only booleans of that namespace.
+func Namespace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.Param("namespace")
+		if err := models.ValidateNamespace(namespace); err != nil {
+			Handle400(c, &FieldError{Field: "namespace", Message: "must be at most " + strconv.Itoa(models.MaxNamespaceLength) + " lowercase letters, digits and dashes, not starting or ending with a dash", Err: err})
+			return
+		}
+		c.Set(namespaceKey, namespace)
+		c.Next()
+	}
+}
+
+// namespaceOf returns namespace of the request, which is models.DefaultNamespace for paths without one.
+func namespaceOf(c *gin.Context) string {
+	if namespace := c.GetString(namespaceKey); namespace != "" {
+		return namespace
+	}
+	return models.DefaultNamespace
+}
+
+// namespacedRepo returns repo which reads and changes only booleans of namespace of the request.
+func namespacedRepo(c *gin.Context) models.Repo {
+	return models.GetRepo().InNamespace(namespaceOf(c))
+}
+
+// namespaceBody returns JSON body of namespace ns.
+func namespaceBody(ns models.Namespace) gin.H {
+	return gin.H{
+		"name":         ns.Name,
+		"max_booleans": ns.MaxBooleans,
+		"booleans":     ns.Booleans,
+		"created_at":   ns.CreatedAt,
+	}
+}
+
+// ListNamespacesHandler handles GET request for every namespace by using model's Namespaces method.
+// It returns namespaces in order of their names, with number of booleans in each.
+func ListNamespacesHandler(c *gin.Context) {
+	namespaces, databaseError := models.GetRepo().Namespaces()
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	bodies := make([]gin.H, 0, len(namespaces))
+	for _, ns := range namespaces {
+		bodies = append(bodies, namespaceBody(ns))
+	}
+	c.JSON(http.StatusOK, gin.H{"namespaces": bodies})
+}
+
+// GetNamespaceHandler handles GET request for a namespace by using model's GetNamespace method.
+func GetNamespaceHandler(c *gin.Context) {
+	ns, databaseError := models.GetRepo().GetNamespace(namespaceOf(c))
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, namespaceBody(ns))
+}
+
+// PutNamespaceHandler handles PUT request for a namespace by using model's PutNamespace method.
+// It creates the namespace with 201 status, or changes quota of the existing one with 200 status.
+// Quota which is not given is DefaultMaxBooleans for a new namespace, and stays as it is for an existing one.
+func PutNamespaceHandler(c *gin.Context) {
+	var request namespaceRequest
+	if c.Request.ContentLength != 0 {
+		bindError := c.ShouldBindJSON(&request)
+		if bindError != nil {
+			Handle400(c, bindError)
+			return
+		}
+	}
+
+	ns := models.Namespace{Name: namespaceOf(c)}
+	if request.MaxBooleans != nil {
+		if *request.MaxBooleans < 0 {
+			Handle400(c, &FieldError{Field: "max_booleans", Message: "must not be negative"})
+			return
+		}
+		ns.MaxBooleans = *request.MaxBooleans
+	} else {
+		// Existing namespace keeps its quota, and a new one gets the default quota.
+		existing, databaseError := models.GetRepo().GetNamespace(ns.Name)
+		switch {
+		case databaseError == nil:
+			ns.MaxBooleans = existing.MaxBooleans
+		case errors.Is(databaseError, models.ErrNamespaceNotFound):
+			ns.MaxBooleans = models.DefaultMaxBooleans
+		default:
+			HandleError(c, databaseError)
+			return
+		}
+	}
+
+	ns, created, databaseError := models.GetRepo().PutNamespace(ns)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, namespaceBody(ns))
+}
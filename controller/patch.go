@@ -0,0 +1,312 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// Content types accepted by PATCH request. Requests without content type are read as merge patch.
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// mergePatch parses RFC 7396 JSON Merge Patch document into BooleanPatch.
+// Fields missing from document stay unchanged, and null removes any field but value.
+// Environments are merged same as the document, so null removes value of a single environment.
+// Expiry is given either as expires_at time or as ttl duration from now. Member names must match exactly,
+// and members which are not fields of boolean are rejected.
+func mergePatch(body []byte) (models.BooleanPatch, error) {
+	var patch models.BooleanPatch
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(body, &document); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return patch, &FieldError{Field: "body", Message: "must be a JSON object"}
+		}
+		return patch, err
+	}
+
+	names := make([]string, 0, len(document))
+	for name := range document {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw := document[name]
+		switch name {
+		case "value":
+			value, err := patchValue(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "value", Message: err.Error()}
+			}
+			patch.Value = &value
+		case "key":
+			key, err := patchKey(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "key", Message: err.Error()}
+			}
+			patch.Key = &key
+		case "description":
+			description, err := patchText(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "description", Message: err.Error()}
+			}
+			patch.Description = &description
+		case "owner":
+			owner, err := patchText(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "owner", Message: err.Error()}
+			}
+			patch.Owner = &owner
+		case "tags":
+			tags, err := patchTags(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "tags", Message: err.Error()}
+			}
+			patch.Tags = &tags
+		case "environments":
+			environments, err := patchEnvironments(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "environments", Message: err.Error()}
+			}
+			patch.Environments = &environments
+		case "expires_at":
+			if _, ok := document["ttl"]; ok {
+				return patch, &FieldError{Field: "ttl", Message: "must not be given along with expires_at"}
+			}
+			expiresAt, err := patchExpiresAt(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "expires_at", Message: err.Error()}
+			}
+			patch.ExpiresAt = &expiresAt
+		case "ttl":
+			expiresAt, err := patchTTL(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "ttl", Message: err.Error()}
+			}
+			patch.ExpiresAt = &expiresAt
+		case "fallback":
+			fallback, err := patchFallback(raw)
+			if err != nil {
+				return patch, &FieldError{Field: "fallback", Message: err.Error()}
+			}
+			patch.Fallback = &fallback
+		default:
+			return patch, &FieldError{Field: name, Message: "is not a field of boolean"}
+		}
+	}
+
+	return patch, nil
+}
+
+// jsonPatchOperation is a single operation of RFC 6902 JSON Patch.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonPatch applies RFC 6902 JSON Patch document to current boolean, and returns
+// BooleanPatch with the fields touched by operations. Failed test operation returns models.ErrConflict.
+func jsonPatch(body []byte, current models.Boolean) (models.BooleanPatch, error) {
+	var patch models.BooleanPatch
+
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return patch, &FieldError{Field: "body", Message: "must be an array of operations"}
+		}
+		return patch, err
+	}
+
+	result := current
+	environmentsChanged := false
+	for i, operation := range operations {
+		field := "operations[" + strconv.Itoa(i) + "]"
+
+		switch operation.Op {
+		case "add", "replace":
+			switch operation.Path {
+			case "/value":
+				value, err := patchValue(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Value = value
+				patch.Value = &result.Value
+			case "/key":
+				key, err := patchKey(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Key = key
+				patch.Key = &result.Key
+			case "/description":
+				description, err := patchText(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Description = description
+				patch.Description = &result.Description
+			case "/owner":
+				owner, err := patchText(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Owner = owner
+				patch.Owner = &result.Owner
+			case "/tags":
+				tags, err := patchTags(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Tags = tags
+				patch.Tags = &result.Tags
+			case "/expires_at":
+				expiresAt, err := patchExpiresAt(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.ExpiresAt = expiresAt
+				patch.ExpiresAt = &result.ExpiresAt
+			case "/fallback":
+				fallback, err := patchFallback(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Fallback = fallback
+				patch.Fallback = &result.Fallback
+			case "/environments":
+				var environments models.EnvironmentValues
+				if err := json.Unmarshal(operation.Value, &environments); err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: "must be object of bools"}
+				}
+				result.Environments = environments
+				environmentsChanged = true
+			default:
+				environment, ok := environmentPath(operation.Path)
+				if !ok {
+					return patch, &FieldError{Field: field + ".path", Message: "must be /value, /key, /description, /owner, /tags, /environments, /environments/{name}, /expires_at or /fallback"}
+				}
+				value, err := patchValue(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				result.Environments = copyEnvironments(result.Environments)
+				result.Environments[environment] = value
+				environmentsChanged = true
+			}
+		case "remove":
+			switch operation.Path {
+			case "/key":
+				result.Key = ""
+				patch.Key = &result.Key
+			case "/description":
+				result.Description = ""
+				patch.Description = &result.Description
+			case "/owner":
+				result.Owner = ""
+				patch.Owner = &result.Owner
+			case "/tags":
+				result.Tags = nil
+				patch.Tags = &result.Tags
+			case "/expires_at":
+				result.ExpiresAt = sql.NullTime{}
+				patch.ExpiresAt = &result.ExpiresAt
+			case "/fallback":
+				result.Fallback = sql.NullBool{}
+				patch.Fallback = &result.Fallback
+			case "/environments":
+				result.Environments = nil
+				environmentsChanged = true
+			default:
+				environment, ok := environmentPath(operation.Path)
+				if !ok {
+					return patch, &FieldError{Field: field + ".path", Message: "must be /key, /description, /owner, /tags, /environments, /environments/{name}, /expires_at or /fallback, value can not be removed"}
+				}
+				result.Environments = copyEnvironments(result.Environments)
+				delete(result.Environments, environment)
+				environmentsChanged = true
+			}
+		case "test":
+			var matches bool
+			switch operation.Path {
+			case "/value":
+				value, err := patchValue(operation.Value)
+				if err != nil {
+					return patch, &FieldError{Field: field + ".value", Message: err.Error()}
+				}
+				matches = value == result.Value
+			case "/key":
+				var key *string
+				if err := json.Unmarshal(operation.Value, &key); err != nil || key == nil {
+					return patch, &FieldError{Field: field + ".value", Message: "must be string"}
+				}
+				matches = *key == result.Key
+			default:
+				return patch, &FieldError{Field: field + ".path", Message: "must be /value or /key"}
+			}
+			if !matches {
+				return patch, fmt.Errorf("%w: test of %s failed", models.ErrConflict, operation.Path)
+			}
+		default:
+			return patch, &FieldError{Field: field + ".op", Message: "must be one of add, replace, remove, test"}
+		}
+	}
+
+	if environmentsChanged {
+		environments := environmentChanges(current.Environments, result.Environments)
+		patch.Environments = &environments
+	}
+	return patch, nil
+}
+
+// patchValue reads new value from raw JSON, it can not be null.
+func patchValue(raw json.RawMessage) (bool, error) {
+	var value *bool
+	if err := json.Unmarshal(raw, &value); err != nil || value == nil {
+		return false, errors.New("must be bool")
+	}
+	return *value, nil
+}
+
+// patchKey reads new key from raw JSON, null means empty key.
+func patchKey(raw json.RawMessage) (string, error) {
+	var key *string
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return "", errors.New("must be string or null")
+	}
+	if key == nil {
+		return "", nil
+	}
+	return *key, nil
+}
+
+// patchText reads new description or owner from raw JSON, null means empty.
+func patchText(raw json.RawMessage) (string, error) {
+	var text *string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return "", errors.New("must be string or null")
+	}
+	if text == nil {
+		return "", nil
+	}
+	return *text, nil
+}
+
+// patchTags reads new tags from raw JSON, null means no tags.
+func patchTags(raw json.RawMessage) (models.Tags, error) {
+	var tags models.Tags
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		return nil, errors.New("must be array of strings or null")
+	}
+	return tags, nil
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/auth"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// Types of principals.
+const (
+	// PrincipalAPIKey is principal authenticated by an API key.
+	PrincipalAPIKey = models.SubjectAPIKey
+	// PrincipalToken is principal authenticated by a bearer token of the identity provider.
+	PrincipalToken = models.SubjectToken
+)
+
+// principalKey is key of principal of the request in gin context.
+const principalKey = "principal"
+
+// bearerPrefix starts Authorization header which carries a bearer token.
+const bearerPrefix = "Bearer "
+
+// Principal is who made the request, as authenticated by Authenticate.
+// ID is id of API key, which is empty for the admin key, or subject of token. Name is name of API key,
+// or subject of token. Roles and Claims are only of tokens.
+type Principal struct {
+	Type   string
+	ID     string
+	Name   string
+	Roles  []string
+	Scopes models.Scopes
+	Claims auth.Claims
+}
+
+// String returns principal as it is recorded in history, like token:alice or api_key:deploy.
+func (p Principal) String() string {
+	return p.Type + ":" + p.Name
+}
+
+// Subjects returns subjects which role bindings and access lists name the principal by, which are
+// the principal itself and every role it has.
+func (p Principal) Subjects() []string {
+	subjects := []string{p.String()}
+	for _, role := range p.Roles {
+		subjects = append(subjects, models.SubjectRole+":"+role)
+	}
+	return subjects
+}
+
+// errMissingCredentials means request has neither API key nor bearer token.
+var errMissingCredentials = errors.New("API key or token is missing")
+
+// tokenVerifier verifies bearer tokens, and it is nil when tokens are not accepted.
+var tokenVerifier *auth.Verifier
+
+// defaultRoleScopes grants scopes to roles named after them.
+var defaultRoleScopes = map[string]models.Scope{
+	string(models.ScopeRead):  models.ScopeRead,
+	string(models.ScopeWrite): models.ScopeWrite,
+	string(models.ScopeAdmin): models.ScopeAdmin,
+}
+
+// roleScopes are scopes which roles of tokens grant.
+var roleScopes = defaultRoleScopes
+
+// SetTokenVerifier makes Authenticate accept bearer tokens verified by verifier. Nil verifier stops
+// accepting tokens.
+func SetTokenVerifier(verifier *auth.Verifier) {
+	tokenVerifier = verifier
+}
+
+// SetRoleScopes sets scopes which roles of tokens grant, given as "role=scope" pairs separated by commas,
+// like "flags-admin=admin,developer=write". Empty mapping grants scopes to roles named after them.
+func SetRoleScopes(mapping string) error {
+	if mapping == "" {
+		roleScopes = defaultRoleScopes
+		return nil
+	}
+
+	scopes := map[string]models.Scope{}
+	for _, pair := range strings.Split(mapping, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || !models.Scope(parts[1]).Valid() {
+			return fmt.Errorf("%q must be a role and one of read, write and admin, separated by =", pair)
+		}
+		scopes[parts[0]] = models.Scope(parts[1])
+	}
+	roleScopes = scopes
+	return nil
+}
+
+// scopesOf returns scopes which roles of a token grant.
+func scopesOf(roles []string) models.Scopes {
+	var scopes models.Scopes
+	for _, role := range roles {
+		if scope, ok := roleScopes[role]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// tokenPrincipal returns principal of identity from a token, with scopes which its roles grant.
+func tokenPrincipal(identity auth.Identity) Principal {
+	return Principal{
+		Type:   PrincipalToken,
+		ID:     identity.Subject,
+		Name:   identity.Subject,
+		Roles:  identity.Roles,
+		Scopes: scopesOf(identity.Roles),
+		Claims: identity.Claims,
+	}
+}
+
+// bearerToken returns bearer token given in Authorization header.
+func bearerToken(c *gin.Context) string {
+	authorization := c.GetHeader("Authorization")
+	if len(authorization) > len(bearerPrefix) && strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(authorization[len(bearerPrefix):])
+	}
+	return ""
+}
+
+// isToken reports whether bearer token is a JSON Web Token rather than an API key.
+func isToken(bearer string) bool {
+	return tokenVerifier != nil && !strings.HasPrefix(bearer, models.APIKeyPrefix) && strings.Count(bearer, ".") == 2
+}
+
+// authenticate returns principal of credentials given by client.
+func authenticate(c *gin.Context) (Principal, error) {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return authenticateAPIKey(key)
+	}
+
+	bearer := bearerToken(c)
+	switch {
+	case bearer == "":
+		return Principal{}, errMissingCredentials
+	case isToken(bearer):
+		identity, err := tokenVerifier.Authenticate(bearer)
+		if err != nil {
+			return Principal{}, err
+		}
+		return tokenPrincipal(identity), nil
+	default:
+		return authenticateAPIKey(bearer)
+	}
+}
+
+// Authenticate is a middleware which lets through only requests with a valid API key or bearer token,
+// and keeps their principal in context for handlers after it. Other requests get 401 status.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authenticate(c)
+		switch {
+		case err == nil:
+		case errors.Is(err, errMissingCredentials), errors.Is(err, models.ErrAPIKeyNotFound), errors.Is(err, auth.ErrInvalidToken):
+			Handle401(c, err)
+			return
+		default:
+			HandleError(c, err)
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// PrincipalOf returns principal of the request, and false when the request was not authenticated.
+func PrincipalOf(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -10,100 +11,243 @@ import (
 )
 
 // GetHandler handles GET request of server by using model's get function.
+// With at query it returns the boolean as it was at that time instead.
+// With environment in path or in EnvironmentHeader it returns value of the boolean in that environment.
 func GetHandler(c *gin.Context) {
-	id, parseError := uuid.Parse(c.Param("id"))
+	id, parseError := parseID(c)
 	if parseError != nil {
 		// ParseError(c, parseError)
 		Handle400(c, parseError)
 		return
 	}
 
-	b, databaseError := models.GetRepo().Get(id)
-	if databaseError != nil && databaseError.Error() == "Record not found" {
-		Handle404(c, databaseError)
+	environment, parseError := requestedEnvironment(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	if at, ok := c.GetQuery("at"); ok {
+		getAt(c, id, at, environment)
 		return
 	}
 
+	b, databaseError := namespacedRepo(c).Get(id)
 	if databaseError != nil {
-		Handle500(c, databaseError)
+		HandleError(c, databaseError)
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"id":    b.ID,
-		"value": b.Value,
-		"key":   b.Key,
-	})
+	if !checkReadPreconditions(c, b, environment) {
+		return
+	}
+
+	setEnvironmentETag(c, b.Version, environment)
+	c.JSON(200, environmentBody(b, environment))
 }
 
 // PostHandler handles POST request of server by usning model's Create function.
 // It returns saved boolean object with uuid assigned to it, or an error in JSON format.
 func PostHandler(c *gin.Context) {
-	var b models.Boolean
+	var request postRequest
 
-	bindError := c.ShouldBindJSON(&b)
+	bindError := c.ShouldBindJSON(&request)
 	if bindError != nil {
 		// BindError(c, bindError)
 		Handle400(c, bindError)
 		return
 	}
+	b, requestError := request.boolean()
+	if requestError != nil {
+		Handle400(c, requestError)
+		return
+	}
 
-	bID, databaseError := models.GetRepo().Create(b)
-	// Error must be 500 in this case, because we are not fetching anything from database.
+	b, databaseError := auditedRepo(c).Create(b)
 	if databaseError != nil {
 		// DatabaseError(c, databaseError)
-		Handle500(c, databaseError)
+		HandleError(c, databaseError)
 		return
 	}
 
-	b.ID = bID
-
-	c.JSON(200, gin.H{
-		"id":    b.ID,
-		"value": b.Value,
-		"key":   b.Key,
-	})
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
 }
 
-// PatchHandler handles PATCH request of server by using model's Update method.
+// PatchHandler handles PATCH request of server by using model's Patch method.
+// Body is a JSON Merge Patch, or a JSON Patch when sent with application/json-patch+json content type,
+// and only fields mentioned in it are changed.
 func PatchHandler(c *gin.Context) {
-	id, parseError := uuid.Parse(c.Param("id"))
+	c.Header("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+
+	id, parseError := parseID(c)
 	if parseError != nil {
 		// ParseError(c, parseError)
 		Handle400(c, parseError)
 		return
 	}
 
-	var b models.Boolean
-	bindError := c.ShouldBindJSON(&b)
-	if bindError != nil {
+	body, readError := c.GetRawData()
+	if readError != nil {
+		Handle400(c, readError)
+		return
+	}
+
+	version, versionError := ifMatchVersion(c, id)
+	if versionError != nil {
+		HandleError(c, versionError)
+		return
+	}
+
+	var patch models.BooleanPatch
+	var patchError error
+	snapshot := false
+	if c.ContentType() == jsonPatchContentType {
+		// Operations are applied to current boolean, so test operations can be checked.
+		current, databaseError := namespacedRepo(c).Get(id)
+		if databaseError != nil {
+			HandleError(c, databaseError)
+			return
+		}
+		if version == models.AnyVersion {
+			// Patch is stored only if nobody changed the boolean since test operations were checked.
+			version = current.Version
+			snapshot = true
+		} else if version != current.Version {
+			HandleError(c, models.ErrVersionMismatch)
+			return
+		}
+		patch, patchError = jsonPatch(body, current)
+	} else {
+		patch, patchError = mergePatch(body)
+	}
+
+	if errors.Is(patchError, models.ErrConflict) {
+		HandleError(c, patchError)
+		return
+	}
+	if patchError != nil {
 		// BindError(c, bindError)
+		Handle400(c, patchError)
+		return
+	}
+
+	b, databaseError := auditedRepo(c).Patch(id, patch, version)
+	if snapshot && errors.Is(databaseError, models.ErrVersionMismatch) {
+		// Client did not ask for a version, so it is a conflict with concurrent change.
+		Handle409(c, databaseError)
+		return
+	}
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
+}
+
+// putRequest is body of PUT request, value is required, and missing key and metadata are stored empty.
+type putRequest struct {
+	Value        *bool           `json:"value" binding:"required"`
+	Key          string          `json:"key"`
+	Description  string          `json:"description"`
+	Owner        string          `json:"owner"`
+	Tags         []string        `json:"tags"`
+	Environments map[string]bool `json:"environments"`
+}
+
+// PutHandler handles PUT request of server by replacing whole boolean using model's Update method.
+func PutHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	var request putRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
 		Handle400(c, bindError)
 		return
 	}
 
-	databaseError := models.GetRepo().Update(id, b)
-	if databaseError != nil && databaseError.Error() == "Record not found" {
-		// DatabaseError(c, databaseError)
-		Handle404(c, databaseError)
+	version, versionError := ifMatchVersion(c, id)
+	if versionError != nil {
+		HandleError(c, versionError)
+		return
+	}
+
+	b := models.Boolean{ID: id, Value: *request.Value, Key: request.Key, Description: request.Description, Owner: request.Owner, Tags: request.Tags, Environments: request.Environments}
+	b, databaseError := auditedRepo(c).Update(id, b, version)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
+}
+
+// ToggleHandler handles POST request to toggle endpoint by using model's Toggle method.
+// It flips the value atomically and returns the updated boolean.
+func ToggleHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
 		return
 	}
 
+	b, databaseError := auditedRepo(c).Toggle(id)
 	if databaseError != nil {
-		Handle500(c, databaseError)
+		HandleError(c, databaseError)
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"id":    id,
-		"value": b.Value,
-		"key":   b.Key,
-	})
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
+}
+
+// casRequest is body of compare-and-swap request, both values are required.
+type casRequest struct {
+	Expected *bool `json:"expected" binding:"required"`
+	New      *bool `json:"new" binding:"required"`
+}
+
+// CompareAndSwapHandler handles POST request to cas endpoint by using model's CompareAndSwap method.
+// Value is set to new only if it is expected, otherwise 409 with the current boolean is returned.
+func CompareAndSwapHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	var request casRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	b, databaseError := auditedRepo(c).CompareAndSwap(id, *request.Expected, *request.New)
+	if errors.Is(databaseError, models.ErrValueMismatch) {
+		setETag(c, b.Version)
+		HandleValueMismatch(c, b)
+		return
+	}
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	setETag(c, b.Version)
+	c.JSON(200, booleanBody(b))
 }
 
 // DeleteHandler handles DELETE request of server by using model's Delete method.
 func DeleteHandler(c *gin.Context) {
-	id, parseError := uuid.Parse(c.Param("id"))
+	id, parseError := parseID(c)
 
 	if parseError != nil {
 		// ParseError(c, parseError)
@@ -111,17 +255,27 @@ func DeleteHandler(c *gin.Context) {
 		return
 	}
 
-	databaseError := models.GetRepo().Delete(id)
-
-	if databaseError != nil && databaseError.Error() == "Record not found" {
-		Handle404(c, databaseError)
+	version, versionError := ifMatchVersion(c, id)
+	if versionError != nil {
+		HandleError(c, versionError)
 		return
 	}
 
+	databaseError := auditedRepo(c).Delete(id, version)
+
 	if databaseError != nil {
-		Handle500(c, databaseError)
+		HandleError(c, databaseError)
 		return
 	}
 
 	c.Writer.WriteHeader(http.StatusNoContent)
 }
+
+// parseID reads uuid of a boolean from id path parameter.
+func parseID(c *gin.Context) (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.UUID{}, &FieldError{Field: "id", Message: "must be a valid uuid"}
+	}
+	return id, nil
+}
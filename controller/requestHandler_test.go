@@ -1,17 +1,23 @@
 package controller
 
 import (
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/hrishi32/boolean-as-service/auth"
+	"github.com/hrishi32/boolean-as-service/auth/authtest"
 	"github.com/hrishi32/boolean-as-service/models"
 
 	"github.com/google/uuid"
@@ -23,10 +29,29 @@ import (
 
 // Sort out all errors properly, tests will depend on this only
 
+// assertErrorBody checks that body is an ErrorResponse with given code.
+func assertErrorBody(t *testing.T, body []byte, code string) ErrorResponse {
+	errorResponse := ErrorResponse{}
+	if err := json.Unmarshal(body, &errorResponse); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, code, errorResponse.Code)
+	assert.NotEmpty(t, errorResponse.Message)
+	assert.NotEmpty(t, errorResponse.RequestID)
+	return errorResponse
+}
+
+// newMockRepo returns mock repo, which is also repo of the default namespace.
+func newMockRepo(ctrl *gomock.Controller) *mocks.MockRepo {
+	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo.EXPECT().InNamespace(models.DefaultNamespace).Return(mockRepo).AnyTimes()
+	return mockRepo
+}
+
 // Get
 func TestGetSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	expectedBoolean := models.Boolean{
@@ -74,7 +99,7 @@ func TestGetSuccess(t *testing.T) {
 }
 func TestGet400(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	badUUID := "A_Bad_UUID"
 
@@ -108,18 +133,18 @@ func TestGet400(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	errorResponse := assertErrorBody(t, responseBody, "BAD_REQUEST")
+	assert.Equal(t, []FieldError{{Field: "id", Message: "must be a valid uuid"}}, errorResponse.Details)
 }
 func TestGet404(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	expectedBoolean := models.Boolean{}
 
-	mockRepo.EXPECT().Get(demoUUID).Return(expectedBoolean, errors.New("Record not found"))
+	mockRepo.EXPECT().Get(demoUUID).Return(expectedBoolean, models.ErrNotFound)
 
 	// Preservice setup
 	models.SetRepo(mockRepo)
@@ -149,13 +174,12 @@ func TestGet404(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody, "NOT_FOUND")
 }
 func TestGet500(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	expectedBoolean := models.Boolean{}
@@ -190,9 +214,35 @@ func TestGet500(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody, "INTERNAL_ERROR")
+}
+
+func TestGet503(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+
+	mockRepo.EXPECT().Get(demoUUID).Return(models.Boolean{}, fmt.Errorf("%w: connection refused", models.ErrUnavailable))
+
+	// Preservice setup
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/:id", GetHandler)
+
+	// Make request to above created server
+	request, err := http.NewRequest(http.MethodGet, "/"+demoUUID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Response Code verification
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
 }
 
 /* **************************************** */
@@ -201,7 +251,7 @@ func TestGet500(t *testing.T) {
 
 func TestPostSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	demoBoolean := models.Boolean{
@@ -214,7 +264,7 @@ func TestPostSuccess(t *testing.T) {
 		Value: demoBoolean.Value,
 		Key:   demoBoolean.Key,
 	}
-	mockRepo.EXPECT().Create(demoBoolean).Return(demoUUID, nil)
+	mockRepo.EXPECT().Create(demoBoolean).Return(expectedBoolean, nil)
 
 	// Preservice
 	models.SetRepo(mockRepo)
@@ -254,7 +304,7 @@ func TestPostSuccess(t *testing.T) {
 }
 func TestPost400(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	// badUUID := "A_Bad_UUID"
 	badRequestBody := strings.NewReader(`{
@@ -292,25 +342,24 @@ func TestPost400(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	expectedResponse := []byte{}
-	assert.Equal(t, expectedResponse, responseBody)
+	// Checking error code in response body
+	errorResponse := assertErrorBody(t, responseBody, "BAD_REQUEST")
+	assert.Equal(t, []FieldError{{Field: "key", Message: "must be string"}}, errorResponse.Details)
 }
 
 // POST 404 does not exist
 func TestPost404(t *testing.T) {}
 func TestPost500(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
-	demoUUID := uuid.UUID{}
 	demoBoolean := models.Boolean{
 		Value: true,
 		Key:   "demo key",
 	}
 
 	// expectedBoolean := models.Boolean{}
-	mockRepo.EXPECT().Create(demoBoolean).Return(demoUUID, errors.New("Some new error"))
+	mockRepo.EXPECT().Create(demoBoolean).Return(models.Boolean{}, errors.New("Some new error"))
 
 	// Preservice
 	models.SetRepo(mockRepo)
@@ -346,14 +395,47 @@ func TestPost500(t *testing.T) {
 	// 	t.Fatal(err)
 	// }
 
-	expectedResponse := []byte{}
-	assert.Equal(t, expectedResponse, responseBody)
+	assertErrorBody(t, responseBody, "INTERNAL_ERROR")
+}
+
+func TestPost409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoBoolean := models.Boolean{
+		Value: true,
+		Key:   "demo key",
+	}
+
+	mockRepo.EXPECT().Create(demoBoolean).Return(models.Boolean{}, fmt.Errorf("%w: duplicate key", models.ErrConflict))
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/", PostHandler)
+
+	// Make request
+	requestBody := strings.NewReader(`{
+		"key": "demo key",
+		"value": true
+	  }`)
+	request, err := http.NewRequest(http.MethodPost, "/", requestBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Response Code verification
+	assert.Equal(t, http.StatusConflict, response.Code)
 }
 
 // PATCH Tests
 func TestPatchSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	// We update a boolean to following key and value, we don't care about previous values
@@ -367,7 +449,10 @@ func TestPatchSuccess(t *testing.T) {
 		Value: demoBoolean.Value,
 		Key:   demoBoolean.Key,
 	}
-	mockRepo.EXPECT().Update(demoUUID, demoBoolean).Return(nil)
+	mockRepo.EXPECT().Patch(demoUUID, models.BooleanPatch{
+		Value: &demoBoolean.Value,
+		Key:   &demoBoolean.Key,
+	}, models.AnyVersion).Return(expectedBoolean, nil)
 
 	// Preservice
 	models.SetRepo(mockRepo)
@@ -406,7 +491,7 @@ func TestPatchSuccess(t *testing.T) {
 }
 func TestPatch400(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	badUUID := "A_Bad_UUID"
 	demoUUID := uuid.New()
@@ -448,9 +533,8 @@ func TestPatch400(t *testing.T) {
 		t.Fatal(err1)
 	}
 
-	// Checking whether we have empty response
-	expectedResponse1 := []byte{}
-	assert.Equal(t, expectedResponse1, responseBody1)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody1, "BAD_REQUEST")
 
 	// Another request
 
@@ -472,14 +556,13 @@ func TestPatch400(t *testing.T) {
 		t.Fatal(err2)
 	}
 
-	// Checking whether we have empty response
-	expectedResponse2 := []byte{}
-	assert.Equal(t, expectedResponse2, responseBody2)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody2, "BAD_REQUEST")
 
 }
 func TestPatch404(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 
@@ -489,7 +572,7 @@ func TestPatch404(t *testing.T) {
 	  }`)
 	// expectedBoolean := models.Boolean{}
 
-	mockRepo.EXPECT().Update(demoUUID, gomock.Any()).Return(errors.New("Record not found"))
+	mockRepo.EXPECT().Patch(demoUUID, gomock.Any(), models.AnyVersion).Return(models.Boolean{}, models.ErrNotFound)
 
 	// Preservice setup
 	models.SetRepo(mockRepo)
@@ -519,13 +602,12 @@ func TestPatch404(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody, "NOT_FOUND")
 }
 func TestPatch500(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	demoBoolean := models.Boolean{
@@ -534,7 +616,10 @@ func TestPatch500(t *testing.T) {
 	}
 
 	// expectedBoolean := models.Boolean{}
-	mockRepo.EXPECT().Update(demoUUID, demoBoolean).Return(errors.New("Some new error"))
+	mockRepo.EXPECT().Patch(demoUUID, models.BooleanPatch{
+		Value: &demoBoolean.Value,
+		Key:   &demoBoolean.Key,
+	}, models.AnyVersion).Return(models.Boolean{}, errors.New("Some new error"))
 
 	// Preservice
 	models.SetRepo(mockRepo)
@@ -570,13 +655,229 @@ func TestPatch500(t *testing.T) {
 	// 	t.Fatal(err)
 	// }
 
-	expectedResponse := []byte{}
-	assert.Equal(t, expectedResponse, responseBody)
+	assertErrorBody(t, responseBody, "INTERNAL_ERROR")
+}
+
+func TestPatchKeepsMissingFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	value := false
+	storedBoolean := models.Boolean{
+		ID:    demoUUID,
+		Value: false,
+		Key:   "stored key",
+	}
+
+	// Only value is sent, so key must not be a part of the patch
+	mockRepo.EXPECT().Patch(demoUUID, models.BooleanPatch{Value: &value}, models.AnyVersion).Return(storedBoolean, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PATCH("/:id", PatchHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPatch, "/"+demoUUID.String(), strings.NewReader(`{"value": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	responseBoolean := models.Boolean{}
+	err = json.Unmarshal(response.Body.Bytes(), &responseBoolean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, storedBoolean, responseBoolean)
+}
+
+func TestJSONPatch409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	storedBoolean := models.Boolean{
+		ID:    demoUUID,
+		Value: false,
+		Key:   "stored key",
+	}
+
+	// Test operation fails, so Patch must not be called
+	mockRepo.EXPECT().Get(demoUUID).Return(storedBoolean, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PATCH("/:id", PatchHandler)
+
+	// Make request
+	requestBody := strings.NewReader(`[
+		{"op": "test", "path": "/value", "value": true},
+		{"op": "replace", "path": "/value", "value": false}
+	]`)
+	request, err := http.NewRequest(http.MethodPatch, "/"+demoUUID.String(), requestBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json-patch+json")
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "CONFLICT")
+}
+
+// PUT Tests
+func TestPutSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	expectedBoolean := models.Boolean{
+		ID:    demoUUID,
+		Value: true,
+	}
+
+	// Missing key replaces stored key with empty one
+	mockRepo.EXPECT().Update(demoUUID, expectedBoolean, models.AnyVersion).Return(expectedBoolean, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PUT("/:id", PutHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPut, "/"+demoUUID.String(), strings.NewReader(`{"value": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	responseBoolean := models.Boolean{}
+	err = json.Unmarshal(response.Body.Bytes(), &responseBoolean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expectedBoolean, responseBoolean)
+}
+func TestPut400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+
+	// no need to mock Update function as it will not be called in this case.
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PUT("/:id", PutHandler)
+
+	// Make request without value
+	request, err := http.NewRequest(http.MethodPut, "/"+demoUUID.String(), strings.NewReader(`{"key": "demo key"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+	assert.Equal(t, []FieldError{{Field: "value", Message: "failed on required rule"}}, errorResponse.Details)
+}
+
+// Toggle Tests
+func TestToggleSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	expectedBoolean := models.Boolean{
+		ID:    demoUUID,
+		Value: false,
+		Key:   "demo key",
+	}
+
+	mockRepo.EXPECT().Toggle(demoUUID).Return(expectedBoolean, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/toggle", ToggleHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/toggle", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	responseBoolean := models.Boolean{}
+	err = json.Unmarshal(response.Body.Bytes(), &responseBoolean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expectedBoolean, responseBoolean)
+}
+func TestToggle404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+
+	mockRepo.EXPECT().Toggle(demoUUID).Return(models.Boolean{}, models.ErrNotFound)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/toggle", ToggleHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/toggle", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "NOT_FOUND")
 }
 
+// DELETE Tests
 func TestDeleteSuccess(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	// expectedBoolean := models.Boolean{
@@ -585,7 +886,7 @@ func TestDeleteSuccess(t *testing.T) {
 	// 	Key:   "somekey",
 	// }
 
-	mockRepo.EXPECT().Delete(demoUUID).Return(nil)
+	mockRepo.EXPECT().Delete(demoUUID, models.AnyVersion).Return(nil)
 
 	// Preservice setup
 	models.SetRepo(mockRepo)
@@ -626,7 +927,7 @@ func TestDeleteSuccess(t *testing.T) {
 }
 func TestDelete400(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	badUUID := "A_Bad_UUID"
 
@@ -660,18 +961,17 @@ func TestDelete400(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody, "BAD_REQUEST")
 }
 func TestDelete404(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	// expectedBoolean := models.Boolean{}
 
-	mockRepo.EXPECT().Delete(demoUUID).Return(errors.New("Record not found"))
+	mockRepo.EXPECT().Delete(demoUUID, models.AnyVersion).Return(models.ErrNotFound)
 
 	// Preservice setup
 	models.SetRepo(mockRepo)
@@ -701,19 +1001,18 @@ func TestDelete404(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody, "NOT_FOUND")
 
 }
 func TestDelete500(t *testing.T) {
 	ctrl := gomock.NewController(t)
-	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo := newMockRepo(ctrl)
 
 	demoUUID := uuid.New()
 	// expectedBoolean := models.Boolean{}
 
-	mockRepo.EXPECT().Delete(demoUUID).Return(errors.New("Some new error"))
+	mockRepo.EXPECT().Delete(demoUUID, models.AnyVersion).Return(errors.New("Some new error"))
 
 	// Preservice setup
 	models.SetRepo(mockRepo)
@@ -743,7 +1042,2083 @@ func TestDelete500(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Checking whether we have empty response
-	a := []byte{}
-	assert.Equal(t, a, responseBody)
+	// Checking error code in response body
+	assertErrorBody(t, responseBody, "INTERNAL_ERROR")
+}
+
+func TestCompareAndSwapSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	expectedBoolean := models.Boolean{
+		ID:    demoUUID,
+		Value: true,
+		Key:   "demo key",
+	}
+
+	mockRepo.EXPECT().CompareAndSwap(demoUUID, false, true).Return(expectedBoolean, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/cas", CompareAndSwapHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/cas", strings.NewReader(`{"expected": false, "new": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	responseBoolean := models.Boolean{}
+	err = json.Unmarshal(response.Body.Bytes(), &responseBoolean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expectedBoolean, responseBoolean)
+}
+
+func TestCompareAndSwap409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	currentBoolean := models.Boolean{
+		ID:      demoUUID,
+		Value:   true,
+		Key:     "demo key",
+		Version: 4,
+	}
+
+	mockRepo.EXPECT().CompareAndSwap(demoUUID, false, true).Return(currentBoolean, models.ErrValueMismatch)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/cas", CompareAndSwapHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/cas", strings.NewReader(`{"expected": false, "new": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Equal(t, `"4"`, response.Header().Get("ETag"))
+
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "VALUE_MISMATCH")
+	assert.Equal(t, gin.H{"id": demoUUID.String(), "value": true, "key": "demo key"}, errorResponse.Current)
+}
+
+func TestListSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	value := true
+	key := "demo key"
+	mockRepo.EXPECT().List(models.ListOptions{Value: &value, Key: &key, Sort: models.SortByKey, Descending: true, Limit: 5}).
+		Return(models.Page{Booleans: []models.Boolean{{ID: demoUUID, Value: true, Key: key}}}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/", ListHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/?value=true&key=demo+key&sort=-key&limit=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"booleans": [{"id": "`+demoUUID.String()+`", "value": true, "key": "demo key"}]}`, response.Body.String())
+}
+
+func TestList400(t *testing.T) {
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"value=maybe", "value"},
+		{"sort=value", "sort"},
+		{"limit=0", "limit"},
+		{"limit=101", "limit"},
+		{"cursor=not-a-cursor", "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.GET("/", ListHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			if assert.Len(t, errorResponse.Details, 1) {
+				assert.Equal(t, tt.field, errorResponse.Details[0].Field)
+			}
+		})
+	}
+}
+
+func TestGetByKeySuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	expectedBoolean := models.Boolean{
+		ID:      uuid.New(),
+		Value:   true,
+		Key:     "demo",
+		Version: 3,
+	}
+
+	mockRepo.EXPECT().GetByKey("demo").Return(expectedBoolean, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/keys/:key", GetByKeyHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/keys/demo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"3"`, response.Header().Get("ETag"))
+
+	responseBoolean := models.Boolean{}
+	err = json.Unmarshal(response.Body.Bytes(), &responseBoolean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBoolean.Version = 0
+	assert.Equal(t, expectedBoolean, responseBoolean)
+}
+
+func TestPutByKeyCreated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	expectedBoolean := models.Boolean{
+		ID:      uuid.New(),
+		Value:   true,
+		Key:     "demo",
+		Version: models.FirstVersion,
+	}
+
+	mockRepo.EXPECT().UpsertByKey("demo", models.Boolean{Value: true}, models.AnyVersion).Return(expectedBoolean, true, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PUT("/keys/:key", PutByKeyHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPut, "/keys/demo", strings.NewReader(`{"value": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.JSONEq(t, `{"id": "`+expectedBoolean.ID.String()+`", "value": true, "key": "demo"}`, response.Body.String())
+}
+
+func TestPutByKey409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	mockRepo.EXPECT().UpsertByKey("demo", models.Boolean{Value: false}, models.AnyVersion).Return(models.Boolean{}, false, models.ErrDuplicateKey)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PUT("/keys/:key", PutByKeyHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPut, "/keys/demo", strings.NewReader(`{"value": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "DUPLICATE_KEY")
+}
+
+func TestBulk400(t *testing.T) {
+	tests := []struct {
+		body  string
+		field string
+	}{
+		{`{"operations": []}`, "operations"},
+		{`{"mode": "sometimes", "operations": [{"op": "create", "value": true}]}`, "mode"},
+		{`{"operations": [{"op": "create", "value": true}, {"op": "create"}]}`, "operations[1].value"},
+		{`{"operations": [{"op": "toggle", "id": "` + uuid.New().String() + `"}]}`, "operations[0].op"},
+		{`{"operations": [{"op": "delete", "id": "not-uuid"}]}`, "operations[0].id"},
+		{`{"operations": [{"op": "update", "id": "` + uuid.New().String() + `", "version": -1}]}`, "operations[0].version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.POST("/bulk", BulkHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPost, "/bulk", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			if assert.Len(t, errorResponse.Details, 1) {
+				assert.Equal(t, tt.field, errorResponse.Details[0].Field)
+			}
+		})
+	}
+}
+
+func TestBulk500(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	operations := []models.Operation{{Type: models.OperationDelete, ID: demoUUID}}
+	mockRepo.EXPECT().Bulk(operations, false).Return([]models.OperationResult{{Err: errors.New("demo error")}}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/bulk", BulkHandler)
+
+	// Make request
+	body := `{"mode": "best_effort", "operations": [{"op": "delete", "id": "` + demoUUID.String() + `"}]}`
+	request, err := http.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response, error of a single operation does not fail the request
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"results": [{"status": 500, "error": {"code": "INTERNAL_ERROR", "message": "Internal server error"}}]}`, response.Body.String())
+}
+
+func TestBatchGet503(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().GetMany([]uuid.UUID{demoUUID}, []string{"demo"}).Return(nil, models.ErrUnavailable)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/batch-get", BatchGetHandler)
+
+	// Make request
+	body := `{"ids": ["` + demoUUID.String() + `"], "keys": ["demo"]}`
+	request, err := http.NewRequest(http.MethodPost, "/batch-get", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "SERVICE_UNAVAILABLE")
+}
+
+func TestHistorySuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	oldValue, newValue := true, false
+	oldKey, newKey := "demo", "demo"
+	at := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().History(demoUUID, models.HistoryOptions{Before: 7, Limit: 1}).
+		Return(models.HistoryPage{Entries: []models.HistoryEntry{{
+			ID: 6, BooleanID: demoUUID, Version: 2, Action: models.HistoryUpdate,
+			OldValue: &oldValue, NewValue: &newValue, OldKey: &oldKey, NewKey: &newKey,
+			Actor: "alice", RequestID: "request", CreatedAt: at,
+		}}, Next: 6}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/:id/history", HistoryHandler)
+
+	// Make request
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"b":7}`))
+	request, err := http.NewRequest(http.MethodGet, "/"+demoUUID.String()+"/history?limit=1&cursor="+cursor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{
+		"id": "`+demoUUID.String()+`",
+		"history": [{
+			"version": 2, "action": "update",
+			"old_value": true, "new_value": false, "old_key": "demo", "new_key": "demo",
+			"actor": "alice", "request_id": "request", "at": "2020-10-01T12:00:00Z"
+		}],
+		"next_cursor": "`+base64.RawURLEncoding.EncodeToString([]byte(`{"b":6}`))+`"
+	}`, response.Body.String())
+}
+
+func TestHistory400(t *testing.T) {
+	demoUUID := uuid.New()
+	tests := []struct {
+		path  string
+		field string
+	}{
+		{"/not-a-uuid/history", "id"},
+		{"/" + demoUUID.String() + "/history?limit=0", "limit"},
+		{"/" + demoUUID.String() + "/history?limit=101", "limit"},
+		{"/" + demoUUID.String() + "/history?cursor=not-a-cursor", "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.GET("/:id/history", HistoryHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			if assert.Len(t, errorResponse.Details, 1) {
+				assert.Equal(t, tt.field, errorResponse.Details[0].Field)
+			}
+		})
+	}
+}
+
+func TestGetAtSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	at := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().GetAt(demoUUID, at).Return(models.Boolean{ID: demoUUID, Value: true, Key: "demo", Version: 2}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/:id", GetHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/"+demoUUID.String()+"?at=2020-10-01T12:00:00Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, response.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "demo", "version": 2}`, response.Body.String())
+}
+
+func TestGetAt400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/:id", GetHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/"+uuid.New().String()+"?at=yesterday", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+	assert.Equal(t, []FieldError{{Field: "at", Message: "must be a time in RFC 3339 format"}}, errorResponse.Details)
+}
+
+func TestRollbackSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().Rollback(demoUUID, int64(1), int64(3)).Return(models.Boolean{ID: demoUUID, Value: true, Key: "demo", Version: 4}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/rollback", RollbackHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/rollback", strings.NewReader(`{"version": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("If-Match", `"3"`)
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"4"`, response.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "demo"}`, response.Body.String())
+}
+
+func TestRollback404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().Rollback(demoUUID, int64(7), models.AnyVersion).Return(models.Boolean{}, models.ErrVersionNotFound)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/rollback", RollbackHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/rollback", strings.NewReader(`{"version": 7}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "VERSION_NOT_FOUND")
+}
+
+func TestRestoreSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().Restore(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true, Key: "demo", Version: 3}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/restore", RestoreHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"3"`, response.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "demo"}`, response.Body.String())
+}
+
+func TestRestore409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().Restore(demoUUID).Return(models.Boolean{}, models.ErrNotDeleted)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/restore", RestoreHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "NOT_DELETED")
+}
+
+func TestPurgeSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	var before time.Time
+	mockRepo.EXPECT().Purge(gomock.Any()).DoAndReturn(func(t time.Time) (int64, error) {
+		before = t
+		return 2, nil
+	})
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/purge", PurgeHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/purge", strings.NewReader(`{"older_than": "24h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"purged": 2}`, response.Body.String())
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Second)
+}
+
+func TestPurge400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/purge", PurgeHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/purge", strings.NewReader(`{"older_than": "a while"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+	assert.Equal(t, []FieldError{{Field: "older_than", Message: "must be a duration like 24h"}}, errorResponse.Details)
+}
+
+func TestPostExpirySuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	var created models.Boolean
+	mockRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(b models.Boolean) (models.Boolean, error) {
+		created = b
+		b.ID = demoUUID
+		return b, nil
+	})
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/", PostHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"value": true, "ttl": "1h", "fallback": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.True(t, created.ExpiresAt.Valid)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), created.ExpiresAt.Time, time.Second)
+	assert.Equal(t, sql.NullBool{Bool: false, Valid: true}, created.Fallback)
+	expiresAt := created.ExpiresAt.Time.Format(time.RFC3339Nano)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "", "expires_at": "`+expiresAt+`", "fallback": false}`, response.Body.String())
+}
+
+func TestPostExpiry400(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected FieldError
+	}{
+		{"both", `{"value": true, "ttl": "1h", "expires_at": "2100-01-01T00:00:00Z"}`, FieldError{Field: "ttl", Message: "must not be given along with expires_at"}},
+		{"ttl", `{"value": true, "ttl": "-1h"}`, FieldError{Field: "ttl", Message: "must be a positive duration like 1h30m"}},
+		{"fallback", `{"value": true, "fallback": true}`, FieldError{Field: "fallback", Message: "requires expires_at or ttl"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.POST("/", PostHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			assert.Equal(t, []FieldError{tt.expected}, errorResponse.Details)
+		})
+	}
+}
+
+func TestPatchExpirySuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	fallback := sql.NullBool{Bool: true, Valid: true}
+	expiresAt := sql.NullTime{Time: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	patch := models.BooleanPatch{ExpiresAt: &expiresAt, Fallback: &fallback}
+	mockRepo.EXPECT().Patch(demoUUID, patch, models.AnyVersion).Return(models.Boolean{ID: demoUUID, Value: true, Version: 2, ExpiresAt: expiresAt, Fallback: fallback}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PATCH("/:id", PatchHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPatch, "/"+demoUUID.String(), strings.NewReader(`{"expires_at": "2100-01-01T00:00:00Z", "fallback": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "", "expires_at": "2100-01-01T00:00:00Z", "fallback": true}`, response.Body.String())
+}
+
+func TestMergePatch400(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected FieldError
+	}{
+		{"case", `{"Value": true}`, FieldError{Field: "Value", Message: "is not a field of boolean"}},
+		{"unknown", `{"value": true, "colour": "red"}`, FieldError{Field: "colour", Message: "is not a field of boolean"}},
+		{"both", `{"ttl": "1h", "expires_at": "2100-01-01T00:00:00Z"}`, FieldError{Field: "ttl", Message: "must not be given along with expires_at"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.PATCH("/:id", PatchHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPatch, "/"+uuid.New().String(), strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			assert.Equal(t, []FieldError{tt.expected}, errorResponse.Details)
+		})
+	}
+}
+
+func TestSchedulesSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	scheduleUUID := uuid.New()
+	at := time.Date(2030, 1, 5, 2, 0, 0, 0, time.UTC)
+	created := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().Schedules(demoUUID).Return([]models.Schedule{
+		{ID: scheduleUUID, BooleanID: demoUUID, Value: false, ApplyAt: at, Status: models.SchedulePending, CreatedAt: created},
+	}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/:id/schedules", SchedulesHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/"+demoUUID.String()+"/schedules", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "schedules": [
+		{"id": "`+scheduleUUID.String()+`", "value": false, "at": "2030-01-05T02:00:00Z", "status": "pending", "created_at": "2030-01-01T12:00:00Z"}
+	]}`, response.Body.String())
+}
+
+func TestCreateScheduleSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	scheduleUUID := uuid.New()
+	at := time.Date(2030, 1, 5, 2, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().CreateSchedule(demoUUID, models.Schedule{Value: false, ApplyAt: at}).
+		Return(models.Schedule{ID: scheduleUUID, BooleanID: demoUUID, Value: false, ApplyAt: at, Status: models.SchedulePending, CreatedAt: at.Add(-time.Hour)}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/schedules", CreateScheduleHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/schedules", strings.NewReader(`{"value": false, "at": "2030-01-05T02:00:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.JSONEq(t, `{"id": "`+scheduleUUID.String()+`", "value": false, "at": "2030-01-05T02:00:00Z", "status": "pending", "created_at": "2030-01-05T01:00:00Z"}`, response.Body.String())
+}
+
+func TestCreateSchedule400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/schedules", CreateScheduleHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+uuid.New().String()+"/schedules", strings.NewReader(`{"value": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+	assert.Equal(t, []FieldError{{Field: "at", Message: "failed on required rule"}}, errorResponse.Details)
+}
+
+func TestCancelSchedule409(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	scheduleUUID := uuid.New()
+	mockRepo.EXPECT().CancelSchedule(demoUUID, scheduleUUID).Return(models.ErrScheduleNotPending)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.DELETE("/:id/schedules/:schedule_id", CancelScheduleHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodDelete, "/"+demoUUID.String()+"/schedules/"+scheduleUUID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "SCHEDULE_NOT_PENDING")
+}
+
+func TestPostMetadataSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	createdAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	demoBoolean := models.Boolean{Value: true, Description: "Dark mode", Owner: "web", Tags: models.Tags{"ui", "beta"}}
+	stored := demoBoolean
+	stored.ID = demoUUID
+	stored.CreatedAt = createdAt
+	stored.UpdatedAt = createdAt
+	mockRepo.EXPECT().Create(demoBoolean).Return(stored, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/", PostHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"value": true, "description": "Dark mode", "owner": "web", "tags": ["ui", "beta"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "", "description": "Dark mode", "owner": "web", "tags": ["ui", "beta"],
+		"created_at": "2021-01-02T03:04:05Z", "updated_at": "2021-01-02T03:04:05Z"}`, response.Body.String())
+}
+
+func TestListByOwnerAndTagsSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	owner := "web"
+	mockRepo.EXPECT().List(models.ListOptions{Owner: &owner, Tags: []string{"ui", "beta"}, Sort: models.SortByCreated, Limit: models.DefaultListLimit}).
+		Return(models.Page{Booleans: []models.Boolean{{ID: demoUUID, Value: true, Owner: owner, Tags: models.Tags{"beta", "ui"}}}}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/", ListHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/?owner=web&tag=ui&tag=beta", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"booleans": [{"id": "`+demoUUID.String()+`", "value": true, "key": "", "owner": "web", "tags": ["beta", "ui"]}]}`, response.Body.String())
+}
+
+func TestJSONPatchMetadataSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().Get(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true, Version: 2, Owner: "web", Tags: models.Tags{"ui"}}, nil)
+	owner := ""
+	tags := models.Tags{"ui", "beta"}
+	patch := models.BooleanPatch{Owner: &owner, Tags: &tags}
+	mockRepo.EXPECT().Patch(demoUUID, patch, int64(2)).Return(models.Boolean{ID: demoUUID, Value: true, Version: 3, Tags: tags}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PATCH("/:id", PatchHandler)
+
+	// Make request
+	body := `[{"op": "remove", "path": "/owner"}, {"op": "add", "path": "/tags", "value": ["ui", "beta"]}]`
+	request, err := http.NewRequest(http.MethodPatch, "/"+demoUUID.String(), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json-patch+json")
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": "", "tags": ["ui", "beta"]}`, response.Body.String())
+}
+
+// Namespaces
+func TestNamespacedGetSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+	teamRepo := mocks.NewMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().InNamespace("team").Return(teamRepo)
+	teamRepo.EXPECT().Get(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/ns/:namespace/:id", Namespace(), GetHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/ns/team/"+demoUUID.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": true, "key": ""}`, response.Body.String())
+}
+
+func TestNamespaced400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockRepo(ctrl)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/ns/:namespace/:id", Namespace(), GetHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/ns/Team_A/"+uuid.New().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+	if assert.Len(t, errorResponse.Details, 1) {
+		assert.Equal(t, "namespace", errorResponse.Details[0].Field)
+	}
+}
+
+func TestNamespacedList404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+	missingRepo := mocks.NewMockRepo(ctrl)
+
+	mockRepo.EXPECT().InNamespace("missing").Return(missingRepo)
+	missingRepo.EXPECT().List(gomock.Any()).Return(models.Page{}, models.ErrNamespaceNotFound)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/ns/:namespace/", Namespace(), ListHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/ns/missing/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "NAMESPACE_NOT_FOUND")
+}
+
+func TestPostQuotaExceeded(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	mockRepo.EXPECT().Create(models.Boolean{Value: true}).Return(models.Boolean{}, models.ErrQuotaExceeded)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/", PostHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"value": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "QUOTA_EXCEEDED")
+}
+
+func TestListNamespacesSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockRepo(ctrl)
+
+	createdAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().Namespaces().Return([]models.Namespace{
+		{Name: models.DefaultNamespace, CreatedAt: createdAt, Booleans: 3},
+		{Name: "team", MaxBooleans: 10, CreatedAt: createdAt},
+	}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/namespaces", ListNamespacesHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/namespaces", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"namespaces": [
+		{"name": "default", "max_booleans": 0, "booleans": 3, "created_at": "2021-03-01T10:00:00Z"},
+		{"name": "team", "max_booleans": 10, "booleans": 0, "created_at": "2021-03-01T10:00:00Z"}
+	]}`, response.Body.String())
+}
+
+func TestGetNamespace404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockRepo(ctrl)
+
+	mockRepo.EXPECT().GetNamespace("team").Return(models.Namespace{}, models.ErrNamespaceNotFound)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/namespaces/:namespace", Namespace(), GetNamespaceHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/namespaces/team", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "NAMESPACE_NOT_FOUND")
+}
+
+func TestPutNamespaceSuccess(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		quota int64
+		// stored is quota of the namespace which exists already, or nil when it does not exist.
+		// It is looked up only when the body has no quota.
+		stored   *int64
+		created  bool
+		expected int
+	}{
+		{"created with default quota", "", models.DefaultMaxBooleans, nil, true, http.StatusCreated},
+		{"created with quota", `{"max_booleans": 5}`, 5, nil, true, http.StatusCreated},
+		{"changed to no quota", `{"max_booleans": 0}`, 0, nil, false, http.StatusOK},
+		{"kept without quota", "", 0, new(int64), false, http.StatusOK},
+		{"kept with empty body", `{}`, 0, new(int64), false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := mocks.NewMockRepo(ctrl)
+
+			createdAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+			if !strings.Contains(tt.body, "max_booleans") {
+				if tt.stored == nil {
+					mockRepo.EXPECT().GetNamespace("team").Return(models.Namespace{}, models.ErrNamespaceNotFound)
+				} else {
+					mockRepo.EXPECT().GetNamespace("team").Return(models.Namespace{Name: "team", MaxBooleans: *tt.stored, CreatedAt: createdAt}, nil)
+				}
+			}
+			mockRepo.EXPECT().PutNamespace(models.Namespace{Name: "team", MaxBooleans: tt.quota}).
+				Return(models.Namespace{Name: "team", MaxBooleans: tt.quota, CreatedAt: createdAt, Booleans: 2}, tt.created, nil)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.PUT("/namespaces/:namespace", Namespace(), PutNamespaceHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPut, "/namespaces/team", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, tt.expected, response.Code)
+			assert.JSONEq(t, fmt.Sprintf(`{"name": "team", "max_booleans": %d, "booleans": 2, "created_at": "2021-03-01T10:00:00Z"}`, tt.quota), response.Body.String())
+		})
+	}
+}
+
+func TestPutNamespace400(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		body  string
+		field string
+	}{
+		{"negative quota", "/namespaces/team", `{"max_booleans": -1}`, "max_booleans"},
+		{"quota not a number", "/namespaces/team", `{"max_booleans": "many"}`, "max_booleans"},
+		{"invalid name", "/namespaces/-team", `{"max_booleans": 1}`, "namespace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := mocks.NewMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.PUT("/namespaces/:namespace", Namespace(), PutNamespaceHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			if assert.Len(t, errorResponse.Details, 1) {
+				assert.Equal(t, tt.field, errorResponse.Details[0].Field)
+			}
+		})
+	}
+}
+
+// Environments
+func TestGetInEnvironmentSuccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		expected bool
+		etag     string
+		vary     string
+	}{
+		{"path", "/%s/environments/prod", "", false, `"2-prod"`, ""},
+		{"header", "/%s", "prod", false, `"2-prod"`, EnvironmentHeader},
+		{"path over header", "/%s/environments/dev", "prod", true, `"2-dev"`, ""},
+		{"environment without own value", "/%s/environments/staging", "", true, `"2-staging"`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			demoUUID := uuid.New()
+			mockRepo.EXPECT().Get(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true, Version: 2, Environments: models.EnvironmentValues{"prod": false}}, nil)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.GET("/:id", GetHandler)
+			server.GET("/:id/environments/:environment", GetHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tt.path, demoUUID), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				request.Header.Set(EnvironmentHeader, tt.header)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Equal(t, tt.etag, response.Header().Get("ETag"))
+			assert.Equal(t, tt.vary, response.Header().Get("Vary"))
+			body := map[string]interface{}{}
+			if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expected, body["value"])
+			assert.Equal(t, map[string]interface{}{"prod": false}, body["environments"])
+			assert.NotEmpty(t, body["environment"])
+		})
+	}
+}
+
+func TestGetInEnvironmentNotModified(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		ifNoneMatch string
+		expected    int
+	}{
+		{"same environment", "prod", `"2-prod"`, http.StatusNotModified},
+		{"other environment", "dev", `"2-prod"`, http.StatusOK},
+		{"whole boolean", "prod", `"2"`, http.StatusOK},
+		{"environment of whole boolean", "", `"2-prod"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			demoUUID := uuid.New()
+			mockRepo.EXPECT().Get(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true, Version: 2, Environments: models.EnvironmentValues{"prod": false}}, nil)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.GET("/:id", GetHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodGet, "/"+demoUUID.String(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.environment != "" {
+				request.Header.Set(EnvironmentHeader, tt.environment)
+			}
+			request.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, tt.expected, response.Code)
+			assert.Equal(t, EnvironmentHeader, response.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestGetInEnvironment400(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/keys/:key", GetByKeyHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/keys/name", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(EnvironmentHeader, "qa")
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+	if assert.Len(t, errorResponse.Details, 1) {
+		assert.Equal(t, "environment", errorResponse.Details[0].Field)
+	}
+}
+
+func TestCompareEnvironmentsSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	mockRepo.EXPECT().Get(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true, Version: 3, Environments: models.EnvironmentValues{"prod": false}}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/:id/environments", CompareEnvironmentsHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/"+demoUUID.String()+"/environments", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "version": 3, "value": true, "same": false, "environments": [
+		{"name": "dev", "value": true, "own": false},
+		{"name": "staging", "value": true, "own": false},
+		{"name": "prod", "value": false, "own": true}
+	]}`, response.Body.String())
+}
+
+func TestPromoteSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	demoUUID := uuid.New()
+	promoted := models.Boolean{ID: demoUUID, Value: false, Version: 4, Environments: models.EnvironmentValues{"staging": true, "prod": true}}
+	mockRepo.EXPECT().Promote(demoUUID, "staging", "", int64(3)).Return(promoted, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/:id/promote", PromoteHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/"+demoUUID.String()+"/promote", strings.NewReader(`{"from": "staging"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("If-Match", `"3"`)
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"4"`, response.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id": "`+demoUUID.String()+`", "value": false, "key": "", "environments": {"staging": true, "prod": true}}`, response.Body.String())
+}
+
+func TestPromote400(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"missing from", `{"to": "prod"}`, "from"},
+		{"unknown from", `{"from": "qa"}`, "from"},
+		{"unknown to", `{"from": "dev", "to": "qa"}`, "to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.POST("/:id/promote", PromoteHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPost, "/"+uuid.New().String()+"/promote", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "BAD_REQUEST")
+			if assert.Len(t, errorResponse.Details, 1) {
+				assert.Equal(t, tt.field, errorResponse.Details[0].Field)
+			}
+		})
+	}
+}
+
+func TestPatchEnvironmentsSuccess(t *testing.T) {
+	enabled, disabled := true, false
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    models.EnvironmentPatch
+	}{
+		{"merge", mergePatchContentType, `{"environments": {"prod": false, "dev": null}}`, models.EnvironmentPatch{"prod": &disabled, "dev": nil}},
+		{"merge null", mergePatchContentType, `{"environments": null}`, models.EnvironmentPatch{"dev": nil, "staging": nil, "prod": nil}},
+		{"json patch", jsonPatchContentType, `[{"op": "add", "path": "/environments/prod", "value": false}, {"op": "remove", "path": "/environments/dev"}]`, models.EnvironmentPatch{"prod": &disabled, "dev": nil}},
+		{"json patch replace all", jsonPatchContentType, `[{"op": "replace", "path": "/environments", "value": {"staging": true}}]`, models.EnvironmentPatch{"staging": &enabled, "dev": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			demoUUID := uuid.New()
+			mockRepo.EXPECT().Get(demoUUID).Return(models.Boolean{ID: demoUUID, Value: true, Version: 2, Environments: models.EnvironmentValues{"dev": true}}, nil).AnyTimes()
+			mockRepo.EXPECT().Patch(demoUUID, models.BooleanPatch{Environments: &tt.expected}, gomock.Any()).Return(models.Boolean{ID: demoUUID, Value: true, Version: 3}, nil)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.PATCH("/:id", PatchHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPatch, "/"+demoUUID.String(), strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request.Header.Set("Content-Type", tt.contentType)
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusOK, response.Code)
+		})
+	}
+}
+
+// API keys
+func TestAuthenticateSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	apiKey := models.APIKey{ID: uuid.New(), Name: "client", Scopes: models.Scopes{models.ScopeWrite}}
+	mockRepo.EXPECT().AuthenticateAPIKey("bas_key").Return(apiKey, nil).Times(2)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/", Authenticate(), RequireScope(models.ScopeRead), func(c *gin.Context) {
+		principal, ok := PrincipalOf(c)
+		assert.True(t, ok)
+		assert.Equal(t, Principal{Type: PrincipalAPIKey, ID: apiKey.ID.String(), Name: "client", Scopes: apiKey.Scopes}, principal)
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, header := range []struct{ name, value string }{
+		{APIKeyHeader, "bas_key"},
+		{"Authorization", "Bearer bas_key"},
+	} {
+		// Make request
+		request, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set(header.name, header.value)
+
+		response := httptest.NewRecorder()
+		server.ServeHTTP(response, request)
+
+		// Check response
+		assert.Equal(t, http.StatusNoContent, response.Code, header.name)
+	}
+}
+
+func TestAuthenticate401(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		{"missing key", "", ""},
+		{"unknown key", APIKeyHeader, "bas_unknown"},
+		{"not a bearer token", "Authorization", "Basic bas_unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+			mockRepo.EXPECT().AuthenticateAPIKey("bas_unknown").Return(models.APIKey{}, models.ErrAPIKeyNotFound).MaxTimes(1)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.GET("/", Authenticate(), ListHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				request.Header.Set(tt.header, tt.value)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+			assertErrorBody(t, response.Body.Bytes(), "UNAUTHORIZED")
+		})
+	}
+}
+
+func TestRequireScope403(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	mockRepo.EXPECT().AuthenticateAPIKey("bas_key").Return(models.APIKey{Name: "client", Scopes: models.Scopes{models.ScopeRead}}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/", Authenticate(), RequireScope(models.ScopeWrite), PostHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"value": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(APIKeyHeader, "bas_key")
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "FORBIDDEN")
+}
+
+func TestCreateAPIKeySuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	id := uuid.New()
+	at := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().CreateAPIKey(models.APIKey{Name: "client", Scopes: models.Scopes{models.ScopeRead}}).
+		Return(models.APIKey{ID: id, Name: "client", Prefix: "bas_01234567", Hash: "hash", Scopes: models.Scopes{models.ScopeRead}, CreatedAt: at}, "bas_0123456789", nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.POST("/api-keys", CreateAPIKeyHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/api-keys", strings.NewReader(`{"name": "client", "scopes": ["read"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.JSONEq(t, `{
+		"id": "`+id.String()+`", "name": "client", "prefix": "bas_01234567", "scopes": ["read"], "key": "bas_0123456789",
+		"created_at": "2020-10-01T12:00:00Z", "rotated_at": null, "revoked_at": null
+	}`, response.Body.String())
+}
+
+func TestRotateAPIKeyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"missing", models.ErrAPIKeyNotFound, http.StatusNotFound, "API_KEY_NOT_FOUND"},
+		{"revoked", models.ErrAPIKeyRevoked, http.StatusConflict, "API_KEY_REVOKED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			id := uuid.New()
+			mockRepo.EXPECT().RotateAPIKey(id).Return(models.APIKey{}, "", tt.err)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.POST("/api-keys/:id/rotate", RotateAPIKeyHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPost, "/api-keys/"+id.String()+"/rotate", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, tt.status, response.Code)
+			assertErrorBody(t, response.Body.Bytes(), tt.code)
+		})
+	}
+}
+
+// Tokens
+func TestAuthenticateTokenSuccess(t *testing.T) {
+	key := authtest.NewRSAKey(t, "key")
+	jwks, err := auth.NewJWKS(authtest.NewServer(t, key).URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTokenVerifier(&auth.Verifier{Keys: jwks, Issuer: authtest.Issuer, Audience: authtest.Audience})
+	defer SetTokenVerifier(nil)
+	if err := SetRoleScopes("flags-admin=admin, developer=write"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRoleScopes("")
+
+	// Preservice
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.DELETE("/", Authenticate(), RequireScope(models.ScopeWrite), func(c *gin.Context) {
+		principal, ok := PrincipalOf(c)
+		assert.True(t, ok)
+		assert.Equal(t, PrincipalToken, principal.Type)
+		assert.Equal(t, "alice", principal.ID)
+		assert.Equal(t, []string{"developer", "viewer"}, principal.Roles)
+		assert.Equal(t, models.Scopes{models.ScopeWrite}, principal.Scopes)
+		assert.Equal(t, authtest.Issuer, principal.Claims["iss"])
+		assert.Equal(t, "token:alice", principal.String())
+		c.Status(http.StatusNoContent)
+	})
+
+	// Make request
+	request, err := http.NewRequest(http.MethodDelete, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+authtest.Sign(t, key, "RS256", authtest.Claims("alice", "developer", "viewer")))
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNoContent, response.Code)
+}
+
+func TestAuthenticateToken401(t *testing.T) {
+	key := authtest.NewRSAKey(t, "key")
+	jwks, err := auth.NewJWKS(authtest.NewServer(t, key).URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTokenVerifier(&auth.Verifier{Keys: jwks, Audience: "another-service"})
+	defer SetTokenVerifier(nil)
+
+	// Preservice
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.GET("/", Authenticate(), ListHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+authtest.Sign(t, key, "RS256", authtest.Claims("alice", "read")))
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "UNAUTHORIZED")
+}
+
+func TestSetRoleScopes(t *testing.T) {
+	defer SetRoleScopes("")
+	for _, mapping := range []string{"developer", "developer=owner", "=write", "developer=write,"} {
+		assert.Error(t, SetRoleScopes(mapping), mapping)
+	}
+}
+
+// withPrincipal returns middleware which authenticates every request as principal.
+func withPrincipal(principal Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+func TestAuthorizeRoleBindingSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+	mockRepo.EXPECT().InNamespace("team").Return(mockRepo).AnyTimes()
+
+	mockRepo.EXPECT().RoleBindings("team").Return([]models.RoleBinding{
+		{Namespace: "team", Subject: "token:bob", Role: models.RoleAdmin},
+		{Namespace: "team", Subject: "role:developers", Role: models.RoleEditor},
+	}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	principal := Principal{Type: PrincipalToken, ID: "alice", Name: "alice", Roles: []string{"developers"}}
+	server.POST("/ns/:namespace/", Namespace(), withPrincipal(principal), Authorize(ActionWrite), func(c *gin.Context) {
+		value, _ := c.Get(roleKey)
+		assert.Equal(t, models.RoleEditor, value)
+		c.Status(http.StatusNoContent)
+	})
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPost, "/ns/team/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNoContent, response.Code)
+}
+
+func TestAuthorize403(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name       string
+		principal  Principal
+		method     string
+		accessList []string
+	}{
+		{"viewer changes", Principal{Type: PrincipalAPIKey, Name: "client", Scopes: models.Scopes{models.ScopeRead}}, http.MethodDelete, nil},
+		{"editor not in access list", Principal{Type: PrincipalAPIKey, Name: "client", Scopes: models.Scopes{models.ScopeWrite}}, http.MethodDelete, []string{"token:bob"}},
+		{"editor purges", Principal{Type: PrincipalAPIKey, Name: "client", Scopes: models.Scopes{models.ScopeWrite}}, http.MethodPost, nil},
+		{"no role", Principal{Type: PrincipalToken, ID: "alice", Name: "alice"}, http.MethodGet, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+			mockRepo.EXPECT().RoleBindings(models.DefaultNamespace).Return([]models.RoleBinding{}, nil)
+			mockRepo.EXPECT().AccessList(id).Return(tt.accessList, nil).MaxTimes(1)
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			server.GET("/:id", withPrincipal(tt.principal), Authorize(ActionRead), GetHandler)
+			server.DELETE("/:id", withPrincipal(tt.principal), Authorize(ActionWrite), DeleteHandler)
+			server.POST("/:id", withPrincipal(tt.principal), Authorize(ActionAdmin), PurgeHandler)
+
+			// Make request
+			request, err := http.NewRequest(tt.method, "/"+id.String(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, http.StatusForbidden, response.Code)
+			errorResponse := assertErrorBody(t, response.Body.Bytes(), "FORBIDDEN")
+			if assert.Len(t, errorResponse.Details, 1) {
+				assert.Equal(t, "principal", errorResponse.Details[0].Field)
+				assert.Contains(t, errorResponse.Details[0].Message, tt.principal.String())
+			}
+		})
+	}
+}
+
+func TestBulkAccessList403(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	id := uuid.New()
+	mockRepo.EXPECT().RoleBindings(models.DefaultNamespace).Return([]models.RoleBinding{}, nil).Times(2)
+	mockRepo.EXPECT().AccessList(id).Return([]string{"token:bob"}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	principal := Principal{Type: PrincipalAPIKey, Name: "client", Scopes: models.Scopes{models.ScopeWrite}}
+	server.POST("/bulk", withPrincipal(principal), Authorize(ActionWrite), BulkHandler)
+
+	// Make request
+	body := `{"operations": [{"op": "create", "value": true}, {"op": "update", "id": "` + id.String() + `", "value": false}]}`
+	request, err := http.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	errorResponse := assertErrorBody(t, response.Body.Bytes(), "FORBIDDEN")
+	if assert.Len(t, errorResponse.Details, 1) {
+		assert.Equal(t, "operations[1]", errorResponse.Details[0].Field)
+	}
+}
+
+func TestRestoreAccessList(t *testing.T) {
+	tests := []struct {
+		name       string
+		accessList []string
+		status     int
+	}{
+		{"editor not in access list", []string{"token:bob"}, http.StatusForbidden},
+		{"editor in access list", []string{"api_key:client"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := newMockRepo(ctrl)
+
+			id := uuid.New()
+			mockRepo.EXPECT().RoleBindings(models.DefaultNamespace).Return([]models.RoleBinding{}, nil)
+			mockRepo.EXPECT().AccessList(id).Return(nil, models.ErrNotFound)
+			mockRepo.EXPECT().DeletedAccessList(id).Return(tt.accessList, nil)
+			if tt.status == http.StatusOK {
+				mockRepo.EXPECT().Restore(id).Return(models.Boolean{ID: id, Value: true, Version: 3}, nil)
+			}
+
+			// Preservice
+			models.SetRepo(mockRepo)
+			gin.SetMode(gin.TestMode)
+			server := gin.Default()
+			principal := Principal{Type: PrincipalAPIKey, Name: "client", Scopes: models.Scopes{models.ScopeWrite}}
+			server.POST("/:id/restore", withPrincipal(principal), Authorize(ActionWrite), RestoreHandler)
+
+			// Make request
+			request, err := http.NewRequest(http.MethodPost, "/"+id.String()+"/restore", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := httptest.NewRecorder()
+			server.ServeHTTP(response, request)
+
+			// Check response
+			assert.Equal(t, tt.status, response.Code)
+		})
+	}
+}
+
+func TestEvaluatePolicySuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+	mockRepo.EXPECT().InNamespace("team").Return(mockRepo).AnyTimes()
+
+	id := uuid.New()
+	mockRepo.EXPECT().RoleBindings("team").Return([]models.RoleBinding{{Namespace: "team", Subject: "token:alice", Role: models.RoleEditor}}, nil)
+	mockRepo.EXPECT().AccessList(id).Return([]string{"role:oncall"}, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	principal := Principal{Type: PrincipalToken, ID: "alice", Name: "alice", Roles: []string{"oncall"}}
+	server.POST("/policy/evaluate", withPrincipal(principal), EvaluatePolicyHandler)
+
+	// Make request
+	body := `{"action": "write", "namespace": "team", "id": "` + id.String() + `"}`
+	request, err := http.NewRequest(http.MethodPost, "/policy/evaluate", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{
+		"subject": "token:alice", "action": "write", "namespace": "team", "id": "`+id.String()+`",
+		"allowed": true, "role": "editor",
+		"reason": "token:alice is editor in namespace team by role binding of token:alice, and access list of boolean `+id.String()+` lists role:oncall"
+	}`, response.Body.String())
+}
+
+func TestPutRoleBindingSuccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	at := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	mockRepo.EXPECT().PutRoleBinding(models.RoleBinding{Namespace: "team", Subject: "role:developers", Role: models.RoleEditor}).
+		Return(models.RoleBinding{Namespace: "team", Subject: "role:developers", Role: models.RoleEditor, CreatedAt: at, UpdatedAt: at}, true, nil)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.PUT("/namespaces/:namespace/roles", Namespace(), PutRoleBindingHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodPut, "/namespaces/team/roles", strings.NewReader(`{"subject": "role:developers", "role": "editor"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.JSONEq(t, `{"subject": "role:developers", "role": "editor", "created_at": "2020-10-01T12:00:00Z", "updated_at": "2020-10-01T12:00:00Z"}`, response.Body.String())
+}
+
+func TestDeleteRoleBinding404(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := newMockRepo(ctrl)
+
+	mockRepo.EXPECT().DeleteRoleBinding("team", "token:bob").Return(models.ErrRoleBindingNotFound)
+
+	// Preservice
+	models.SetRepo(mockRepo)
+	gin.SetMode(gin.TestMode)
+	server := gin.Default()
+	server.DELETE("/namespaces/:namespace/roles", Namespace(), DeleteRoleBindingHandler)
+
+	// Make request
+	request, err := http.NewRequest(http.MethodDelete, "/namespaces/team/roles?subject=token:bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	// Check response
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assertErrorBody(t, response.Body.Bytes(), "ROLE_BINDING_NOT_FOUND")
 }
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is header which carries id of a request, given by client or generated by server.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is key of request id in gin context.
+const requestIDKey = "request_id"
+
+// RequestID is a middleware which assigns id to every request and returns it in response header.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		GetRequestID(c)
+		c.Next()
+	}
+}
+
+// GetRequestID returns id of the current request. Id given by client in RequestIDHeader is kept,
+// otherwise a new one is generated on first call.
+func GetRequestID(c *gin.Context) string {
+	if id := c.GetString(requestIDKey); id != "" {
+		return id
+	}
+
+	id := c.GetHeader(RequestIDHeader)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(RequestIDHeader, id)
+
+	return id
+}
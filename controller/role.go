@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// roleBindingRequest is body of PUT request which grants role in a namespace to subject.
+type roleBindingRequest struct {
+	Subject string      `json:"subject" binding:"required"`
+	Role    models.Role `json:"role" binding:"required"`
+}
+
+// accessListRequest is body of PUT request for access list of a boolean. Empty list lets every editor
+// of the namespace change the boolean again.
+type accessListRequest struct {
+	Subjects []string `json:"subjects" binding:"required"`
+}
+
+// roleBindingBody returns JSON body of role binding b.
+func roleBindingBody(b models.RoleBinding) gin.H {
+	return gin.H{
+		"subject":    b.Subject,
+		"role":       b.Role,
+		"created_at": b.CreatedAt,
+		"updated_at": b.UpdatedAt,
+	}
+}
+
+// RoleBindingsHandler handles GET request for roles in a namespace by using model's RoleBindings method.
+// It returns role bindings in order of their subjects.
+func RoleBindingsHandler(c *gin.Context) {
+	bindings, databaseError := models.GetRepo().RoleBindings(namespaceOf(c))
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	bodies := make([]gin.H, 0, len(bindings))
+	for _, b := range bindings {
+		bodies = append(bodies, roleBindingBody(b))
+	}
+	c.JSON(http.StatusOK, gin.H{"namespace": namespaceOf(c), "roles": bodies})
+}
+
+// PutRoleBindingHandler handles PUT request for role of a subject in a namespace by using model's
+// PutRoleBinding method. It grants the role with 201 status, or replaces role of the subject with 200 status.
+func PutRoleBindingHandler(c *gin.Context) {
+	var request roleBindingRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	b, created, databaseError := models.GetRepo().PutRoleBinding(models.RoleBinding{Namespace: namespaceOf(c), Subject: request.Subject, Role: request.Role})
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, roleBindingBody(b))
+}
+
+// DeleteRoleBindingHandler handles DELETE request for role of a subject in a namespace by using model's
+// DeleteRoleBinding method. Subject is given in subject query parameter, as it may have any character.
+func DeleteRoleBindingHandler(c *gin.Context) {
+	subject := c.Query("subject")
+	if subject == "" {
+		Handle400(c, &FieldError{Field: "subject", Message: "failed on required rule"})
+		return
+	}
+
+	databaseError := models.GetRepo().DeleteRoleBinding(namespaceOf(c), subject)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
+
+// AccessListHandler handles GET request for access list of a boolean by using model's AccessList method.
+// It returns subjects which may change the boolean, and no subjects when every editor may change it.
+func AccessListHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	subjects, databaseError := namespacedRepo(c).AccessList(id)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "subjects": subjects})
+}
+
+// PutAccessListHandler handles PUT request for access list of a boolean by using model's SetAccessList method.
+// It replaces the whole list, and returns it in order of subjects.
+func PutAccessListHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	var request accessListRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	subjects, databaseError := namespacedRepo(c).SetAccessList(id, request.Subjects)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "subjects": subjects})
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// scheduleRequest is body of request which schedules a change, with value to set and time
+// in RFC 3339 format when it is set.
+type scheduleRequest struct {
+	Value *bool      `json:"value" binding:"required"`
+	At    *time.Time `json:"at" binding:"required"`
+}
+
+// scheduleBody returns JSON body of schedule s.
+func scheduleBody(s models.Schedule) gin.H {
+	return gin.H{
+		"id":         s.ID,
+		"value":      s.Value,
+		"at":         s.ApplyAt,
+		"status":     s.Status,
+		"created_at": s.CreatedAt,
+	}
+}
+
+// SchedulesHandler handles GET request for schedules of a boolean by using model's Schedules method.
+// It returns pending schedules in order they are applied.
+func SchedulesHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	schedules, databaseError := namespacedRepo(c).Schedules(id)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	bodies := make([]gin.H, 0, len(schedules))
+	for _, s := range schedules {
+		bodies = append(bodies, scheduleBody(s))
+	}
+	c.JSON(200, gin.H{"id": id, "schedules": bodies})
+}
+
+// CreateScheduleHandler handles POST request for schedules of a boolean by using model's CreateSchedule method.
+// It returns the new pending schedule with 201 status.
+func CreateScheduleHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+
+	var request scheduleRequest
+	bindError := c.ShouldBindJSON(&request)
+	if bindError != nil {
+		Handle400(c, bindError)
+		return
+	}
+
+	s, databaseError := auditedRepo(c).CreateSchedule(id, models.Schedule{Value: *request.Value, ApplyAt: *request.At})
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, scheduleBody(s))
+}
+
+// CancelScheduleHandler handles DELETE request for a schedule of a boolean by using model's CancelSchedule method.
+// Schedule which was already applied or canceled can not be canceled, and 409 is returned for it.
+func CancelScheduleHandler(c *gin.Context) {
+	id, parseError := parseID(c)
+	if parseError != nil {
+		Handle400(c, parseError)
+		return
+	}
+	scheduleID, err := uuid.Parse(c.Param("schedule_id"))
+	if err != nil {
+		Handle400(c, &FieldError{Field: "schedule_id", Message: "must be a valid uuid"})
+		return
+	}
+
+	databaseError := auditedRepo(c).CancelSchedule(id, scheduleID)
+	if databaseError != nil {
+		HandleError(c, databaseError)
+		return
+	}
+
+	c.Writer.WriteHeader(http.StatusNoContent)
+}
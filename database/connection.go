@@ -1,9 +1,13 @@
 package database
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -15,6 +19,16 @@ var (
 	dbUser string
 	dbPass string
 	dbName string
+	dbPath string
+)
+
+// Supported values of DB_DRIVER environment variable.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	// DriverMemory keeps booleans in memory of the process, and no database is opened for it.
+	DriverMemory = "memory"
 )
 
 // GetConnection is a function to provide new or existing database connection.
@@ -31,11 +45,71 @@ func GetConnection() (*gorm.DB, error) {
 	return db, nil
 }
 
+// SetConnection replaces the shared database connection, nil forces GetConnection to reconnect.
+func SetConnection(connection *gorm.DB) {
+	db = connection
+}
+
+// Driver returns name of the database driver selected by DB_DRIVER, mysql when it is not set.
+// It returns an error for a driver which is not supported.
+func Driver() (string, error) {
+	driver := os.Getenv("DB_DRIVER")
+	switch driver {
+	case "":
+		return DriverMySQL, nil
+	case DriverMySQL, DriverPostgres, DriverSQLite, DriverMemory:
+		return driver, nil
+	default:
+		return "", fmt.Errorf("DB_DRIVER=%q must be one of %s, %s, %s, %s", driver, DriverMySQL, DriverPostgres, DriverSQLite, DriverMemory)
+	}
+}
+
+// Open opens a new connection to the database of given driver using dsn.
+func Open(driver string, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteTxLock(dsn))
+	case DriverPostgres:
+		dialector = postgresDialector{postgres.Dialector{Config: &postgres.Config{DSN: dsn}}}
+	case DriverMySQL:
+		dialector = mysqlDialector{mysql.Dialector{Config: &mysql.Config{DSN: dsn}}}
+	default:
+		return nil, fmt.Errorf("driver %q has no database", driver)
+	}
+
+	return gorm.Open(dialector, &gorm.Config{})
+}
+
 // createNewConnection is a helper function for GetConnection
 func createNewConnection() (*gorm.DB, error) {
-	docker := os.Getenv("DOCKER")
+	driver, err := Driver()
+	if err != nil {
+		return nil, err
+	}
 
 	var dsn string
+	switch driver {
+	case DriverSQLite:
+		dsn = sqliteDSN()
+	case DriverPostgres:
+		dsn = postgresDSN()
+	default:
+		dsn = mysqlDSN()
+	}
+
+	connection, err := Open(driver, dsn)
+
+	if err != nil {
+		return nil, err
+	}
+	return connection, err
+}
+
+// loadServerConfig reads server credentials from environment inside docker, or uses local defaults.
+func loadServerConfig(defaultPort string, defaultUser string) {
+	docker := os.Getenv("DOCKER")
+
 	if docker == "yes" {
 		dbPort = os.Getenv("DB_PORT")
 		dbHost = os.Getenv("DB_HOST")
@@ -44,18 +118,45 @@ func createNewConnection() (*gorm.DB, error) {
 		dbName = os.Getenv("DB_NAME")
 
 	} else {
-		dbPort = "8084"
+		dbPort = defaultPort
 		dbHost = "127.0.0.1"
-		dbUser = "root"
+		dbUser = defaultUser
 		dbPass = "m"
 		dbName = "boolean"
 
 	}
-	dsn = dbUser + ":" + dbPass + "@tcp(" + dbHost + ":" + dbPort + ")/" + dbName + "?charset=utf8mb4&parseTime=True&loc=Local"
-	connection, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+}
 
-	if err != nil {
-		return nil, err
+// mysqlDSN builds connection string for MySQL.
+func mysqlDSN() string {
+	loadServerConfig("8084", "root")
+	return dbUser + ":" + dbPass + "@tcp(" + dbHost + ":" + dbPort + ")/" + dbName + "?charset=utf8mb4&parseTime=True&loc=Local"
+}
+
+// postgresDSN builds connection string for PostgreSQL.
+func postgresDSN() string {
+	loadServerConfig("5432", "postgres")
+	return "host=" + dbHost + " port=" + dbPort + " user=" + dbUser + " password=" + dbPass + " dbname=" + dbName + " sslmode=disable"
+}
+
+// sqliteDSN returns path of the SQLite database file, boolean.db in working directory by default.
+func sqliteDSN() string {
+	dbPath = os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "boolean.db"
 	}
-	return connection, err
+	return dbPath
+}
+
+// sqliteTxLock makes SQLite transactions take the write lock when they begin, unless dsn chooses otherwise.
+// Transactions which read before they write could otherwise fail at once, when two of them
+// try to upgrade their read locks together.
+func sqliteTxLock(dsn string) string {
+	if strings.Contains(dsn, "_txlock=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_txlock=immediate"
+	}
+	return dsn + "?_txlock=immediate"
 }
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteTxLock(t *testing.T) {
+	assert.Equal(t, "boolean.db?_txlock=immediate", sqliteTxLock("boolean.db"))
+	assert.Equal(t, "file:boolean.db?cache=shared&_txlock=immediate", sqliteTxLock("file:boolean.db?cache=shared"))
+	assert.Equal(t, "boolean.db?_txlock=deferred", sqliteTxLock("boolean.db?_txlock=deferred"))
+}
+
+func TestDriver(t *testing.T) {
+	previous, set := os.LookupEnv("DB_DRIVER")
+	t.Cleanup(func() {
+		if set {
+			os.Setenv("DB_DRIVER", previous)
+		} else {
+			os.Unsetenv("DB_DRIVER")
+		}
+	})
+
+	for value, expected := range map[string]string{"": DriverMySQL, "postgres": DriverPostgres, "memory": DriverMemory} {
+		os.Setenv("DB_DRIVER", value)
+		driver, err := Driver()
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, driver)
+	}
+
+	for _, value := range []string{"postgress", "MySQL", " sqlite"} {
+		os.Setenv("DB_DRIVER", value)
+		_, err := Driver()
+		assert.Error(t, err, value)
+	}
+}
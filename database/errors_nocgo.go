@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package database
+
+// SQLite driver is not built without cgo, so its errors never occur.
+
+func isSQLiteDuplicateKey(err error) bool {
+	return false
+}
+
+func isSQLiteUnavailable(err error) bool {
+	return false
+}
@@ -0,0 +1,30 @@
+//go:build cgo
+// +build cgo
+
+package database
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isSQLiteDuplicateKey reports whether err is a unique or primary key constraint violation from SQLite.
+func isSQLiteDuplicateKey(err error) bool {
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteError.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	return false
+}
+
+// isSQLiteUnavailable reports whether err means that SQLite database is locked, busy or can not be opened.
+func isSQLiteUnavailable(err error) bool {
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.Code == sqlite3.ErrBusy || sqliteError.Code == sqlite3.ErrLocked ||
+			sqliteError.Code == sqlite3.ErrCantOpen
+	}
+	return false
+}
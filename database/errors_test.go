@@ -0,0 +1,39 @@
+package database
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedRecord struct {
+	Name string `gorm:"primaryKey"`
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	connection, err := Open(DriverSQLite, filepath.Join(t.TempDir(), "errors.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.AutoMigrate(&namedRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, connection.Create(&namedRecord{Name: "name"}).Error)
+	err = connection.Create(&namedRecord{Name: "name"}).Error
+
+	assert.True(t, IsDuplicateKey(err))
+	assert.False(t, IsUnavailable(err))
+	assert.False(t, IsDuplicateKey(errors.New("some error")))
+}
+
+func TestIsUnavailable(t *testing.T) {
+	err := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	assert.True(t, IsUnavailable(err))
+	assert.False(t, IsDuplicateKey(err))
+	assert.False(t, IsUnavailable(errors.New("some error")))
+}
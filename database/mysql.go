@@ -0,0 +1,65 @@
+package database
+
+import (
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// mysqlCollation compares strings byte by byte, as SQLite and PostgreSQL do.
+const mysqlCollation = "utf8mb4_bin"
+
+// mysqlDialector is mysql dialector which stores strings with mysqlCollation. Default collation of MySQL
+// ignores case, so keys which differ only in case would collide there and nowhere else.
+type mysqlDialector struct {
+	mysql.Dialector
+}
+
+// Migrator returns mysql migrator bound to mysqlDialector, so migrations see the collation.
+func (dialector mysqlDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return mysql.Migrator{
+		Migrator: migrator.Migrator{Config: migrator.Config{
+			DB:        db,
+			Dialector: dialector,
+		}},
+		Dialector: dialector.Dialector,
+	}
+}
+
+// DataTypeOf adds mysqlCollation to strings, and maps everything else same as mysql dialector.
+func (dialector mysqlDialector) DataTypeOf(field *schema.Field) string {
+	dataType := dialector.Dialector.DataTypeOf(field)
+	if field.DataType == schema.String {
+		return dataType + " CHARACTER SET utf8mb4 COLLATE " + mysqlCollation
+	}
+	return dataType
+}
+
+// MigrateCollation changes given string columns of model to mysqlCollation on MySQL, when their table was
+// created with the default collation. Other engines already compare strings exactly.
+func MigrateCollation(db *gorm.DB, model interface{}, fields ...string) error {
+	if db.Dialector.Name() != DriverMySQL {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	for _, name := range fields {
+		var count int64
+		err := db.Raw("SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ? AND collation_name <> ?",
+			stmt.Schema.Table, stmt.Schema.LookUpField(name).DBName, mysqlCollation).Scan(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			continue
+		}
+		if err := db.Migrator().AlterColumn(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
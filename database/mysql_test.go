@@ -0,0 +1,27 @@
+package database
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm/schema"
+)
+
+type keyRecord struct {
+	Key   string `gorm:"primaryKey;size:255"`
+	Count int64
+}
+
+func TestMySQLBinaryCollation(t *testing.T) {
+	s, err := schema.Parse(&keyRecord{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialector := mysqlDialector{mysql.Dialector{Config: &mysql.Config{}}}
+
+	assert.Equal(t, "varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin", dialector.DataTypeOf(s.LookUpField("Key")))
+	assert.Equal(t, "bigint", dialector.DataTypeOf(s.LookUpField("Count")))
+}
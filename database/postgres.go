@@ -0,0 +1,36 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+// postgresDialector is postgres dialector which stores uuid.UUID fields in native uuid columns,
+// other engines keep them as strings.
+type postgresDialector struct {
+	postgres.Dialector
+}
+
+// Migrator returns postgres migrator bound to postgresDialector, so migrations see native uuid type.
+func (dialector postgresDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return postgres.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{
+		DB:                          db,
+		Dialector:                   dialector,
+		CreateIndexAfterCreateTable: true,
+	}}}
+}
+
+// DataTypeOf maps uuid.UUID to uuid, and everything else same as postgres dialector.
+func (dialector postgresDialector) DataTypeOf(field *schema.Field) string {
+	if field.FieldType == uuidType {
+		return "uuid"
+	}
+	return dialector.Dialector.DataTypeOf(field)
+}
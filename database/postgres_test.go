@@ -0,0 +1,28 @@
+package database
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm/schema"
+)
+
+type uuidRecord struct {
+	ID   uuid.UUID `gorm:"primaryKey"`
+	Name string
+}
+
+func TestPostgresUUIDColumn(t *testing.T) {
+	s, err := schema.Parse(&uuidRecord{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialector := postgresDialector{postgres.Dialector{Config: &postgres.Config{}}}
+
+	assert.Equal(t, "uuid", dialector.DataTypeOf(s.LookUpField("ID")))
+	assert.Equal(t, "text", dialector.DataTypeOf(s.LookUpField("Name")))
+}
@@ -3,12 +3,20 @@ module github.com/hrishi32/boolean-as-service
 go 1.15
 
 require (
-	github.com/gin-gonic/gin v1.6.3
+	github.com/fergusstrange/embedded-postgres v1.19.0
+	github.com/gin-gonic/gin v1.7.7
+	github.com/go-jose/go-jose/v3 v3.0.5
+	github.com/go-playground/validator/v10 v10.4.1
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/mock v1.4.4
 	github.com/google/uuid v1.1.2
+	github.com/jackc/pgconn v1.7.0
 	github.com/jinzhu/gorm v1.9.16
-	github.com/stretchr/testify v1.4.0
+	github.com/mattn/go-sqlite3 v1.14.3
+	github.com/stretchr/testify v1.7.0
 	gorm.io/driver/mysql v1.0.1
+	gorm.io/driver/postgres v1.0.2
+	gorm.io/driver/sqlite v1.1.3
 	gorm.io/driver/sqlserver v1.0.4
-	gorm.io/gorm v1.20.1
+	gorm.io/gorm v1.20.2
 )
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// DefaultExpiryInterval is how often expiry of booleans is stored, unless configured otherwise.
+const DefaultExpiryInterval = time.Minute
+
+// ExpiryInterval returns how often booleans whose expiry passed are reverted or deleted in background,
+// given by EXPIRY_INTERVAL environment variable, for example "30s".
+func ExpiryInterval() time.Duration {
+	return durationFromEnv("EXPIRY_INTERVAL", DefaultExpiryInterval)
+}
+
+// StartExpiry stores expiry of booleans in every namespace of repo once per interval, until the returned function is called.
+// Reads honour expiry on their own, so this only keeps storage and history up to date.
+func StartExpiry(repo models.Repo, interval time.Duration) (stop func()) {
+	return every(interval, func() {
+		eachNamespace(repo, func(namespace string, repo models.Repo) {
+			expired, err := repo.Expire(time.Now())
+			if err != nil {
+				log.Printf("expiry of booleans in namespace %q failed: %v", namespace, err)
+				return
+			}
+			if expired > 0 {
+				log.Printf("expired %d booleans in namespace %q", expired, namespace)
+			}
+		})
+	})
+}
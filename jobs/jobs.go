@@ -0,0 +1,64 @@
+// Package jobs runs background work of the service, which is not started by a request.
+package jobs
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// durationFromEnv reads duration from environment variable, in format of time.ParseDuration.
+// Fallback is used when variable is missing or not valid.
+func durationFromEnv(variable string, fallback time.Duration) time.Duration {
+	value := os.Getenv(variable)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("%s=%q is not a positive duration, using %v", variable, value, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// every runs job once per interval in background, until the returned function is called.
+// Stop waits for a job which is running, so no job runs after it returns.
+func every(interval time.Duration, job func()) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				job()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// eachNamespace runs job with repo of every namespace in turn, so work of one namespace
+// never touches booleans of another.
+func eachNamespace(repo models.Repo, job func(namespace string, repo models.Repo)) {
+	names, err := repo.NamespaceNames()
+	if err != nil {
+		log.Printf("listing namespaces failed: %v", err)
+		return
+	}
+	for _, name := range names {
+		job(name, repo.InNamespace(name))
+	}
+}
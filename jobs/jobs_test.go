@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hrishi32/boolean-as-service/mocks"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// mockNamespaces returns repo with namespaces of given names, and repo of each of those namespaces.
+func mockNamespaces(ctrl *gomock.Controller, names ...string) (*mocks.MockRepo, []*mocks.MockRepo) {
+	mockRepo := mocks.NewMockRepo(ctrl)
+	repos := []*mocks.MockRepo{}
+	for _, name := range names {
+		namespaced := mocks.NewMockRepo(ctrl)
+		mockRepo.EXPECT().InNamespace(name).Return(namespaced).AnyTimes()
+		repos = append(repos, namespaced)
+	}
+	mockRepo.EXPECT().NamespaceNames().Return(names, nil).AnyTimes()
+	return mockRepo, repos
+}
+
+func TestEachNamespace(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo, repos := mockNamespaces(ctrl, "default", "team")
+
+	visited := map[string]models.Repo{}
+	eachNamespace(mockRepo, func(namespace string, repo models.Repo) {
+		visited[namespace] = repo
+	})
+
+	assert.Equal(t, map[string]models.Repo{"default": repos[0], "team": repos[1]}, visited)
+}
+
+func TestEachNamespaceFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mocks.NewMockRepo(ctrl)
+	mockRepo.EXPECT().NamespaceNames().Return(nil, errors.New("connection refused"))
+
+	eachNamespace(mockRepo, func(namespace string, repo models.Repo) {
+		t.Errorf("expected no namespace, got %q", namespace)
+	})
+}
+
+func TestStartJobs(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(repo *mocks.MockRepo) *gomock.Call
+		start  func(repo models.Repo) (stop func())
+		// offset is how far from now the time given to repo is.
+		offset time.Duration
+	}{
+		{
+			name:   "purge",
+			expect: func(repo *mocks.MockRepo) *gomock.Call { return repo.EXPECT().Purge(gomock.Any()) },
+			start:  func(repo models.Repo) func() { return StartPurge(repo, time.Hour, time.Millisecond) },
+			offset: -time.Hour,
+		},
+		{
+			name:   "expiry",
+			expect: func(repo *mocks.MockRepo) *gomock.Call { return repo.EXPECT().Expire(gomock.Any()) },
+			start:  func(repo models.Repo) func() { return StartExpiry(repo, time.Millisecond) },
+		},
+		{
+			name:   "schedules",
+			expect: func(repo *mocks.MockRepo) *gomock.Call { return repo.EXPECT().ApplySchedules(gomock.Any()) },
+			start:  func(repo models.Repo) func() { return StartSchedules(repo, time.Millisecond) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo, repos := mockNamespaces(ctrl, "default", "team")
+
+			given := make(chan time.Time, 1)
+			for _, repo := range repos {
+				tt.expect(repo).DoAndReturn(func(at time.Time) (int64, error) {
+					select {
+					case given <- at:
+					default:
+					}
+					return 1, nil
+				}).MinTimes(1)
+			}
+
+			stop := tt.start(mockRepo)
+			select {
+			case at := <-given:
+				assert.WithinDuration(t, time.Now().Add(tt.offset), at, time.Second)
+			case <-time.After(time.Second):
+				t.Errorf("expected %s to run", tt.name)
+			}
+			stop()
+		})
+	}
+}
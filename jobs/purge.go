@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// Defaults of purge configuration.
+const (
+	DefaultPurgeRetention = 30 * 24 * time.Hour
+	DefaultPurgeInterval  = time.Hour
+)
+
+// PurgeRetention returns how long deleted booleans are kept before they are purged,
+// given by PURGE_RETENTION environment variable, for example "720h".
+func PurgeRetention() time.Duration {
+	return durationFromEnv("PURGE_RETENTION", DefaultPurgeRetention)
+}
+
+// PurgeInterval returns how often deleted booleans are purged in background,
+// given by PURGE_INTERVAL environment variable, for example "1h".
+func PurgeInterval() time.Duration {
+	return durationFromEnv("PURGE_INTERVAL", DefaultPurgeInterval)
+}
+
+// StartPurge purges booleans deleted longer than retention ago from every namespace of repo once per interval,
+// until the returned function is called.
+func StartPurge(repo models.Repo, retention time.Duration, interval time.Duration) (stop func()) {
+	return every(interval, func() {
+		eachNamespace(repo, func(namespace string, repo models.Repo) {
+			purged, err := repo.Purge(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("purge of deleted booleans in namespace %q failed: %v", namespace, err)
+				return
+			}
+			if purged > 0 {
+				log.Printf("purged %d deleted booleans in namespace %q", purged, namespace)
+			}
+		})
+	})
+}
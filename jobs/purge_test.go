@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", time.Hour},
+		{"90m", 90 * time.Minute},
+		{"soon", time.Hour},
+		{"-1h", time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			old, set := os.LookupEnv("PURGE_INTERVAL")
+			os.Setenv("PURGE_INTERVAL", tt.value)
+			t.Cleanup(func() {
+				if set {
+					os.Setenv("PURGE_INTERVAL", old)
+				} else {
+					os.Unsetenv("PURGE_INTERVAL")
+				}
+			})
+			assert.Equal(t, tt.expected, PurgeInterval())
+		})
+	}
+}
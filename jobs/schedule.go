@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// DefaultScheduleInterval is how often due schedules are applied, unless configured otherwise.
+const DefaultScheduleInterval = 10 * time.Second
+
+// ScheduleInterval returns how often schedules whose time passed are applied in background,
+// given by SCHEDULE_INTERVAL environment variable, for example "1s".
+func ScheduleInterval() time.Duration {
+	return durationFromEnv("SCHEDULE_INTERVAL", DefaultScheduleInterval)
+}
+
+// StartSchedules applies due schedules of booleans in every namespace of repo once per interval, until the returned function is called.
+// Schedules are stored in repo, so ones which came due while the service was down are applied on the first run.
+func StartSchedules(repo models.Repo, interval time.Duration) (stop func()) {
+	return every(interval, func() {
+		eachNamespace(repo, func(namespace string, repo models.Repo) {
+			applied, err := repo.ApplySchedules(time.Now())
+			if err != nil {
+				log.Printf("applying schedules in namespace %q failed: %v", namespace, err)
+				return
+			}
+			if applied > 0 {
+				log.Printf("applied %d schedules in namespace %q", applied, namespace)
+			}
+		})
+	})
+}
@@ -1,17 +1,54 @@
 package main
 
 import (
+	"log"
+	"os"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/hrishi32/boolean-as-service/auth"
+	"github.com/hrishi32/boolean-as-service/controller"
+	"github.com/hrishi32/boolean-as-service/database"
+	"github.com/hrishi32/boolean-as-service/jobs"
 	"github.com/hrishi32/boolean-as-service/models"
 	"github.com/hrishi32/boolean-as-service/routes"
 )
 
 func main() {
 	server := gin.Default()
-	defaultRepo := models.RepoImplement{}
-	models.SetRepo(&defaultRepo)
-	models.Migrate()
+	if names := os.Getenv("ENVIRONMENTS"); names != "" {
+		if err := models.SetEnvironments(strings.Split(names, ",")); err != nil {
+			log.Fatalf("ENVIRONMENTS=%q is not valid: %v", names, err)
+		}
+	}
+	if err := controller.SetAdminKey(os.Getenv("ADMIN_API_KEY")); err != nil {
+		log.Fatalf("ADMIN_API_KEY is not valid: %v", err)
+	}
+	verifier, err := auth.VerifierFromEnv()
+	if err != nil {
+		log.Fatalf("tokens can not be verified: %v", err)
+	}
+	controller.SetTokenVerifier(verifier)
+	if err := controller.SetRoleScopes(os.Getenv("JWT_ROLE_SCOPES")); err != nil {
+		log.Fatalf("JWT_ROLE_SCOPES is not valid: %v", err)
+	}
+	driver, err := database.Driver()
+	if err != nil {
+		log.Fatalf("database driver is not valid: %v", err)
+	}
+	if driver == models.MemoryDriver {
+		models.SetRepo(models.NewMemoryRepo())
+	} else {
+		defaultRepo := models.RepoImplement{}
+		models.SetRepo(&defaultRepo)
+		if err := models.Migrate(); err != nil {
+			log.Fatalf("database can not be migrated: %v", err)
+		}
+	}
 	routes.Init(server)
+	jobs.StartPurge(models.GetRepo(), jobs.PurgeRetention(), jobs.PurgeInterval())
+	jobs.StartExpiry(models.GetRepo(), jobs.ExpiryInterval())
+	jobs.StartSchedules(models.GetRepo(), jobs.ScheduleInterval())
 
 	server.Run(":8000")
 }
@@ -9,6 +9,7 @@ import (
 	uuid "github.com/google/uuid"
 	models "github.com/hrishi32/boolean-as-service/models"
 	reflect "reflect"
+	time "time"
 )
 
 // MockRepo is a mock of Repo interface
@@ -34,6 +35,20 @@ func (m *MockRepo) EXPECT() *MockRepoMockRecorder {
 	return m.recorder
 }
 
+// InNamespace mocks base method
+func (m *MockRepo) InNamespace(namespace string) models.Repo {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InNamespace", namespace)
+	ret0, _ := ret[0].(models.Repo)
+	return ret0
+}
+
+// InNamespace indicates an expected call of InNamespace
+func (mr *MockRepoMockRecorder) InNamespace(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InNamespace", reflect.TypeOf((*MockRepo)(nil).InNamespace), namespace)
+}
+
 // Get mocks base method
 func (m *MockRepo) Get(arg0 uuid.UUID) (models.Boolean, error) {
 	m.ctrl.T.Helper()
@@ -49,11 +64,26 @@ func (mr *MockRepoMockRecorder) Get(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepo)(nil).Get), arg0)
 }
 
+// GetMany mocks base method
+func (m *MockRepo) GetMany(ids []uuid.UUID, keys []string) ([]models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMany", ids, keys)
+	ret0, _ := ret[0].([]models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMany indicates an expected call of GetMany
+func (mr *MockRepoMockRecorder) GetMany(ids, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMany", reflect.TypeOf((*MockRepo)(nil).GetMany), ids, keys)
+}
+
 // Create mocks base method
-func (m *MockRepo) Create(arg0 models.Boolean) (uuid.UUID, error) {
+func (m *MockRepo) Create(arg0 models.Boolean) (models.Boolean, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", arg0)
-	ret0, _ := ret[0].(uuid.UUID)
+	ret0, _ := ret[0].(models.Boolean)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -65,29 +95,528 @@ func (mr *MockRepoMockRecorder) Create(arg0 interface{}) *gomock.Call {
 }
 
 // Update mocks base method
-func (m *MockRepo) Update(arg0 uuid.UUID, arg1 models.Boolean) error {
+func (m *MockRepo) Update(id uuid.UUID, b models.Boolean, version int64) (models.Boolean, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Update", id, b, version)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Update indicates an expected call of Update
-func (mr *MockRepoMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) Update(id, b, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepo)(nil).Update), id, b, version)
+}
+
+// Patch mocks base method
+func (m *MockRepo) Patch(id uuid.UUID, patch models.BooleanPatch, version int64) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", id, patch, version)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Patch indicates an expected call of Patch
+func (mr *MockRepoMockRecorder) Patch(id, patch, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockRepo)(nil).Patch), id, patch, version)
+}
+
+// Toggle mocks base method
+func (m *MockRepo) Toggle(arg0 uuid.UUID) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Toggle", arg0)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Toggle indicates an expected call of Toggle
+func (mr *MockRepoMockRecorder) Toggle(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Toggle", reflect.TypeOf((*MockRepo)(nil).Toggle), arg0)
+}
+
+// CompareAndSwap mocks base method
+func (m *MockRepo) CompareAndSwap(id uuid.UUID, expected, new bool) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSwap", id, expected, new)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSwap indicates an expected call of CompareAndSwap
+func (mr *MockRepoMockRecorder) CompareAndSwap(id, expected, new interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSwap", reflect.TypeOf((*MockRepo)(nil).CompareAndSwap), id, expected, new)
+}
+
+// List mocks base method
+func (m *MockRepo) List(options models.ListOptions) (models.Page, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", options)
+	ret0, _ := ret[0].(models.Page)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List
+func (mr *MockRepoMockRecorder) List(options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRepo)(nil).List), options)
+}
+
+// GetByKey mocks base method
+func (m *MockRepo) GetByKey(key string) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByKey", key)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByKey indicates an expected call of GetByKey
+func (mr *MockRepoMockRecorder) GetByKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByKey", reflect.TypeOf((*MockRepo)(nil).GetByKey), key)
+}
+
+// UpsertByKey mocks base method
+func (m *MockRepo) UpsertByKey(key string, b models.Boolean, version int64) (models.Boolean, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertByKey", key, b, version)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UpsertByKey indicates an expected call of UpsertByKey
+func (mr *MockRepoMockRecorder) UpsertByKey(key, b, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepo)(nil).Update), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertByKey", reflect.TypeOf((*MockRepo)(nil).UpsertByKey), key, b, version)
 }
 
 // Delete mocks base method
-func (m *MockRepo) Delete(arg0 uuid.UUID) error {
+func (m *MockRepo) Delete(id uuid.UUID, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret := m.ctrl.Call(m, "Delete", id, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockRepoMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+func (mr *MockRepoMockRecorder) Delete(id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepo)(nil).Delete), id, version)
+}
+
+// Bulk mocks base method
+func (m *MockRepo) Bulk(operations []models.Operation, atomic bool) ([]models.OperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bulk", operations, atomic)
+	ret0, _ := ret[0].([]models.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Bulk indicates an expected call of Bulk
+func (mr *MockRepoMockRecorder) Bulk(operations, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bulk", reflect.TypeOf((*MockRepo)(nil).Bulk), operations, atomic)
+}
+
+// History mocks base method
+func (m *MockRepo) History(id uuid.UUID, options models.HistoryOptions) (models.HistoryPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", id, options)
+	ret0, _ := ret[0].(models.HistoryPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History
+func (mr *MockRepoMockRecorder) History(id, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockRepo)(nil).History), id, options)
+}
+
+// GetAt mocks base method
+func (m *MockRepo) GetAt(id uuid.UUID, at time.Time) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAt", id, at)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAt indicates an expected call of GetAt
+func (mr *MockRepoMockRecorder) GetAt(id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAt", reflect.TypeOf((*MockRepo)(nil).GetAt), id, at)
+}
+
+// Rollback mocks base method
+func (m *MockRepo) Rollback(id uuid.UUID, to, version int64) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback", id, to, version)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rollback indicates an expected call of Rollback
+func (mr *MockRepoMockRecorder) Rollback(id, to, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockRepo)(nil).Rollback), id, to, version)
+}
+
+// Promote mocks base method
+func (m *MockRepo) Promote(id uuid.UUID, from, to string, version int64) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Promote", id, from, to, version)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Promote indicates an expected call of Promote
+func (mr *MockRepoMockRecorder) Promote(id, from, to, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Promote", reflect.TypeOf((*MockRepo)(nil).Promote), id, from, to, version)
+}
+
+// Restore mocks base method
+func (m *MockRepo) Restore(id uuid.UUID) (models.Boolean, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", id)
+	ret0, _ := ret[0].(models.Boolean)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore
+func (mr *MockRepoMockRecorder) Restore(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRepo)(nil).Restore), id)
+}
+
+// Purge mocks base method
+func (m *MockRepo) Purge(before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge
+func (mr *MockRepoMockRecorder) Purge(before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockRepo)(nil).Purge), before)
+}
+
+// Expire mocks base method
+func (m *MockRepo) Expire(at time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", at)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Expire indicates an expected call of Expire
+func (mr *MockRepoMockRecorder) Expire(at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockRepo)(nil).Expire), at)
+}
+
+// Schedules mocks base method
+func (m *MockRepo) Schedules(id uuid.UUID) ([]models.Schedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Schedules", id)
+	ret0, _ := ret[0].([]models.Schedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Schedules indicates an expected call of Schedules
+func (mr *MockRepoMockRecorder) Schedules(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Schedules", reflect.TypeOf((*MockRepo)(nil).Schedules), id)
+}
+
+// CreateSchedule mocks base method
+func (m *MockRepo) CreateSchedule(id uuid.UUID, s models.Schedule) (models.Schedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSchedule", id, s)
+	ret0, _ := ret[0].(models.Schedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSchedule indicates an expected call of CreateSchedule
+func (mr *MockRepoMockRecorder) CreateSchedule(id, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSchedule", reflect.TypeOf((*MockRepo)(nil).CreateSchedule), id, s)
+}
+
+// CancelSchedule mocks base method
+func (m *MockRepo) CancelSchedule(id, scheduleID uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSchedule", id, scheduleID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelSchedule indicates an expected call of CancelSchedule
+func (mr *MockRepoMockRecorder) CancelSchedule(id, scheduleID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSchedule", reflect.TypeOf((*MockRepo)(nil).CancelSchedule), id, scheduleID)
+}
+
+// ApplySchedules mocks base method
+func (m *MockRepo) ApplySchedules(at time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplySchedules", at)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplySchedules indicates an expected call of ApplySchedules
+func (mr *MockRepoMockRecorder) ApplySchedules(at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplySchedules", reflect.TypeOf((*MockRepo)(nil).ApplySchedules), at)
+}
+
+// Namespaces mocks base method
+func (m *MockRepo) Namespaces() ([]models.Namespace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Namespaces")
+	ret0, _ := ret[0].([]models.Namespace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Namespaces indicates an expected call of Namespaces
+func (mr *MockRepoMockRecorder) Namespaces() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Namespaces", reflect.TypeOf((*MockRepo)(nil).Namespaces))
+}
+
+// NamespaceNames mocks base method
+func (m *MockRepo) NamespaceNames() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamespaceNames")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamespaceNames indicates an expected call of NamespaceNames
+func (mr *MockRepoMockRecorder) NamespaceNames() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamespaceNames", reflect.TypeOf((*MockRepo)(nil).NamespaceNames))
+}
+
+// GetNamespace mocks base method
+func (m *MockRepo) GetNamespace(name string) (models.Namespace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNamespace", name)
+	ret0, _ := ret[0].(models.Namespace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNamespace indicates an expected call of GetNamespace
+func (mr *MockRepoMockRecorder) GetNamespace(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNamespace", reflect.TypeOf((*MockRepo)(nil).GetNamespace), name)
+}
+
+// PutNamespace mocks base method
+func (m *MockRepo) PutNamespace(ns models.Namespace) (models.Namespace, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutNamespace", ns)
+	ret0, _ := ret[0].(models.Namespace)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PutNamespace indicates an expected call of PutNamespace
+func (mr *MockRepoMockRecorder) PutNamespace(ns interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutNamespace", reflect.TypeOf((*MockRepo)(nil).PutNamespace), ns)
+}
+
+// APIKeys mocks base method
+func (m *MockRepo) APIKeys() ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "APIKeys")
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// APIKeys indicates an expected call of APIKeys
+func (mr *MockRepoMockRecorder) APIKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "APIKeys", reflect.TypeOf((*MockRepo)(nil).APIKeys))
+}
+
+// CreateAPIKey mocks base method
+func (m *MockRepo) CreateAPIKey(k models.APIKey) (models.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", k)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey
+func (mr *MockRepoMockRecorder) CreateAPIKey(k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockRepo)(nil).CreateAPIKey), k)
+}
+
+// RotateAPIKey mocks base method
+func (m *MockRepo) RotateAPIKey(id uuid.UUID) (models.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateAPIKey", id)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RotateAPIKey indicates an expected call of RotateAPIKey
+func (mr *MockRepoMockRecorder) RotateAPIKey(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateAPIKey", reflect.TypeOf((*MockRepo)(nil).RotateAPIKey), id)
+}
+
+// RevokeAPIKey mocks base method
+func (m *MockRepo) RevokeAPIKey(id uuid.UUID) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", id)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey
+func (mr *MockRepoMockRecorder) RevokeAPIKey(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepo)(nil).RevokeAPIKey), id)
+}
+
+// AuthenticateAPIKey mocks base method
+func (m *MockRepo) AuthenticateAPIKey(secret string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIKey", secret)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateAPIKey indicates an expected call of AuthenticateAPIKey
+func (mr *MockRepoMockRecorder) AuthenticateAPIKey(secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIKey", reflect.TypeOf((*MockRepo)(nil).AuthenticateAPIKey), secret)
+}
+
+// RoleBindings mocks base method
+func (m *MockRepo) RoleBindings(namespace string) ([]models.RoleBinding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RoleBindings", namespace)
+	ret0, _ := ret[0].([]models.RoleBinding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RoleBindings indicates an expected call of RoleBindings
+func (mr *MockRepoMockRecorder) RoleBindings(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RoleBindings", reflect.TypeOf((*MockRepo)(nil).RoleBindings), namespace)
+}
+
+// PutRoleBinding mocks base method
+func (m *MockRepo) PutRoleBinding(b models.RoleBinding) (models.RoleBinding, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutRoleBinding", b)
+	ret0, _ := ret[0].(models.RoleBinding)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// PutRoleBinding indicates an expected call of PutRoleBinding
+func (mr *MockRepoMockRecorder) PutRoleBinding(b interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutRoleBinding", reflect.TypeOf((*MockRepo)(nil).PutRoleBinding), b)
+}
+
+// DeleteRoleBinding mocks base method
+func (m *MockRepo) DeleteRoleBinding(namespace, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRoleBinding", namespace, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRoleBinding indicates an expected call of DeleteRoleBinding
+func (mr *MockRepoMockRecorder) DeleteRoleBinding(namespace, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoleBinding", reflect.TypeOf((*MockRepo)(nil).DeleteRoleBinding), namespace, subject)
+}
+
+// AccessList mocks base method
+func (m *MockRepo) AccessList(id uuid.UUID) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccessList", id)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccessList indicates an expected call of AccessList
+func (mr *MockRepoMockRecorder) AccessList(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccessList", reflect.TypeOf((*MockRepo)(nil).AccessList), id)
+}
+
+// DeletedAccessList mocks base method
+func (m *MockRepo) DeletedAccessList(id uuid.UUID) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletedAccessList", id)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletedAccessList indicates an expected call of DeletedAccessList
+func (mr *MockRepoMockRecorder) DeletedAccessList(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletedAccessList", reflect.TypeOf((*MockRepo)(nil).DeletedAccessList), id)
+}
+
+// SetAccessList mocks base method
+func (m *MockRepo) SetAccessList(id uuid.UUID, subjects []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccessList", id, subjects)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAccessList indicates an expected call of SetAccessList
+func (mr *MockRepoMockRecorder) SetAccessList(id, subjects interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepo)(nil).Delete), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccessList", reflect.TypeOf((*MockRepo)(nil).SetAccessList), id, subjects)
 }
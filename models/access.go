@@ -0,0 +1,418 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// Kinds of subjects, which are the part of a subject before the colon. A subject is who role bindings
+// and access lists are about, like token:alice, api_key:deploy or role:developers. Roles are roles
+// given by the identity provider in tokens, so every principal with the role matches the subject.
+const (
+	SubjectAPIKey = "api_key"
+	SubjectToken  = "token"
+	SubjectRole   = "role"
+)
+
+// MaxSubjectLength is maximum number of characters in a subject.
+const MaxSubjectLength = 255
+
+// MaxAccessListLength is maximum number of subjects in access list of a boolean.
+const MaxAccessListLength = 100
+
+// Role is what a subject may do with booleans of a namespace. Every role may do everything roles before it may.
+type Role string
+
+// Roles in a namespace, from the narrowest one.
+const (
+	// RoleViewer reads booleans of the namespace.
+	RoleViewer Role = "viewer"
+	// RoleEditor also creates, changes and deletes booleans which it is allowed to change by their access lists.
+	RoleEditor Role = "editor"
+	// RoleAdmin also changes every boolean, purges booleans, and manages roles and access lists of the namespace.
+	RoleAdmin Role = "admin"
+)
+
+// rank returns how much the role allows, and zero for an unknown role.
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Valid reports whether r is one of known roles.
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// Allow reports whether the role allows everything required role allows.
+func (r Role) Allow(required Role) bool {
+	return r.Valid() && r.rank() >= required.rank()
+}
+
+// Role returns role which the scope grants in every namespace.
+func (s Scope) Role() Role {
+	switch s {
+	case ScopeRead:
+		return RoleViewer
+	case ScopeWrite:
+		return RoleEditor
+	case ScopeAdmin:
+		return RoleAdmin
+	default:
+		return ""
+	}
+}
+
+// ValidateSubject returns ErrValidation when subject is not a kind and a name separated by a colon.
+func ValidateSubject(subject string) error {
+	parts := strings.SplitN(subject, ":", 2)
+	if len(parts) != 2 || parts[1] == "" || utf8.RuneCountInString(subject) > MaxSubjectLength {
+		return fmt.Errorf("%w: subject must be a kind and a name separated by a colon, at most %d characters long", ErrValidation, MaxSubjectLength)
+	}
+	switch parts[0] {
+	case SubjectAPIKey, SubjectToken, SubjectRole:
+		return nil
+	default:
+		return fmt.Errorf("%w: kind of subject %q must be one of %s, %s, %s", ErrValidation, subject, SubjectAPIKey, SubjectToken, SubjectRole)
+	}
+}
+
+// validateRole returns ErrValidation when role is not one of known roles.
+func validateRole(role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("%w: role %q must be one of %s, %s, %s", ErrValidation, role, RoleViewer, RoleEditor, RoleAdmin)
+	}
+	return nil
+}
+
+// RoleBinding grants Role in Namespace to Subject. A subject has at most one role in a namespace.
+type RoleBinding struct {
+	Namespace string `gorm:"primaryKey;size:63"`
+	Subject   string `gorm:"primaryKey;size:255"`
+	Role      Role   `gorm:"size:16;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AccessEntry lets Subject change boolean with BooleanID. A boolean without entries is changed
+// by every editor of its namespace, and a boolean with entries only by editors it lists and admins.
+type AccessEntry struct {
+	BooleanID uuid.UUID `gorm:"primaryKey"`
+	Subject   string    `gorm:"primaryKey;size:255"`
+}
+
+// prepareAccessList validates subjects of an access list, and returns them without repeated subjects,
+// in order of their names.
+func prepareAccessList(subjects []string) ([]string, error) {
+	unique := map[string]bool{}
+	for _, subject := range subjects {
+		if err := ValidateSubject(subject); err != nil {
+			return nil, err
+		}
+		unique[subject] = true
+	}
+	if len(unique) > MaxAccessListLength {
+		return nil, fmt.Errorf("%w: access list can have at most %d subjects", ErrValidation, MaxAccessListLength)
+	}
+
+	list := make([]string, 0, len(unique))
+	for subject := range unique {
+		list = append(list, subject)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// RoleBindings returns role bindings of namespace from database, in order of their subjects.
+func (*RepoImplement) RoleBindings(namespace string) ([]RoleBinding, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+	if err := namespaceExists(db, namespace); err != nil {
+		return nil, storageError(err)
+	}
+
+	bindings := []RoleBinding{}
+	if err := db.Order("subject").Find(&bindings, "namespace = ?", namespace).Error; err != nil {
+		return nil, storageError(err)
+	}
+	return bindings, nil
+}
+
+// PutRoleBinding grants role of b to its subject in its namespace, instead of any role the subject had there.
+// Created is true when the subject had no role in the namespace.
+func (*RepoImplement) PutRoleBinding(b RoleBinding) (binding RoleBinding, created bool, err error) {
+	if err := ValidateSubject(b.Subject); err != nil {
+		return RoleBinding{}, false, err
+	}
+	if err := validateRole(b.Role); err != nil {
+		return RoleBinding{}, false, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return RoleBinding{}, false, connectionError(err)
+	}
+
+	put := func(tx *gorm.DB) error {
+		if err := namespaceExists(tx, b.Namespace); err != nil {
+			return err
+		}
+		var existing []RoleBinding
+		if err := lockedQuery(tx).Limit(1).Find(&existing, "namespace = ? AND subject = ?", b.Namespace, b.Subject).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			binding = RoleBinding{Namespace: b.Namespace, Subject: b.Subject, Role: b.Role, CreatedAt: now(), UpdatedAt: now()}
+			created = true
+			return tx.Create(&binding).Error
+		}
+
+		binding, created = existing[0], false
+		binding.Role = b.Role
+		binding.UpdatedAt = now()
+		return tx.Model(&RoleBinding{}).Where("namespace = ? AND subject = ?", b.Namespace, b.Subject).
+			UpdateColumns(map[string]interface{}{"role": binding.Role, "updated_at": binding.UpdatedAt}).Error
+	}
+
+	// When two requests bind the same subject, the one which loses changes the binding created by the other.
+	err = db.Transaction(put)
+	if database.IsDuplicateKey(err) {
+		err = db.Transaction(put)
+	}
+	if err != nil {
+		return RoleBinding{}, false, storageError(err)
+	}
+	return binding, created, nil
+}
+
+// DeleteRoleBinding takes role of subject in namespace away, and returns ErrRoleBindingNotFound
+// when the subject has no role there.
+func (*RepoImplement) DeleteRoleBinding(namespace string, subject string) error {
+	db, err := database.GetConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+	if err := namespaceExists(db, namespace); err != nil {
+		return storageError(err)
+	}
+
+	result := db.Delete(&RoleBinding{}, "namespace = ? AND subject = ?", namespace, subject)
+	if result.Error != nil {
+		return storageError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoleBindingNotFound
+	}
+	return nil
+}
+
+// AccessList returns subjects which may change boolean with id from database, in order of their names.
+// It is empty when every editor of the namespace may change the boolean.
+func (r *RepoImplement) AccessList(id uuid.UUID) ([]string, error) {
+	if _, err := r.Get(id); err != nil {
+		return nil, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	subjects := []string{}
+	if err := db.Model(&AccessEntry{}).Where("boolean_id = ?", id).Order("subject").Pluck("subject", &subjects).Error; err != nil {
+		return nil, storageError(err)
+	}
+	return subjects, nil
+}
+
+// DeletedAccessList returns subjects which may change deleted boolean with id from database, in order of their names.
+// It returns ErrNotFound when the boolean is not deleted.
+func (r *RepoImplement) DeletedAccessList(id uuid.UUID) ([]string, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	var deleted []Boolean
+	err = db.Unscoped().Limit(1).Find(&deleted, "id = ? AND namespace = ? AND deleted_at IS NOT NULL", id, r.namespaceName()).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if len(deleted) == 0 {
+		return nil, ErrNotFound
+	}
+
+	subjects := []string{}
+	if err := db.Model(&AccessEntry{}).Where("boolean_id = ?", id).Order("subject").Pluck("subject", &subjects).Error; err != nil {
+		return nil, storageError(err)
+	}
+	return subjects, nil
+}
+
+// SetAccessList replaces subjects which may change boolean with id, and returns them in order of their names.
+// Empty list lets every editor of the namespace change the boolean again.
+func (r *RepoImplement) SetAccessList(id uuid.UUID, subjects []string) ([]string, error) {
+	subjects, err := prepareAccessList(subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockBoolean(tx, r.namespaceName(), id, AnyVersion); err != nil {
+			return err
+		}
+		if err := tx.Delete(&AccessEntry{}, "boolean_id = ?", id).Error; err != nil {
+			return err
+		}
+		if len(subjects) == 0 {
+			return nil
+		}
+		entries := make([]AccessEntry, len(subjects))
+		for i, subject := range subjects {
+			entries[i] = AccessEntry{BooleanID: id, Subject: subject}
+		}
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return subjects, nil
+}
+
+// namespacedSubject is a subject within a namespace.
+type namespacedSubject struct {
+	namespace string
+	subject   string
+}
+
+// RoleBindings returns role bindings of namespace from memory, in order of their subjects.
+func (r *MemoryRepo) RoleBindings(namespace string) ([]RoleBinding, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, ok := r.namespaces[namespace]; !ok {
+		return nil, ErrNamespaceNotFound
+	}
+	bindings := []RoleBinding{}
+	for key, binding := range r.roleBindings {
+		if key.namespace == namespace {
+			bindings = append(bindings, binding)
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Subject < bindings[j].Subject
+	})
+	return bindings, nil
+}
+
+// PutRoleBinding grants role of b to its subject in its namespace, instead of any role the subject had there.
+// Created is true when the subject had no role in the namespace.
+func (r *MemoryRepo) PutRoleBinding(b RoleBinding) (RoleBinding, bool, error) {
+	if err := ValidateSubject(b.Subject); err != nil {
+		return RoleBinding{}, false, err
+	}
+	if err := validateRole(b.Role); err != nil {
+		return RoleBinding{}, false, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.namespaces[b.Namespace]; !ok {
+		return RoleBinding{}, false, ErrNamespaceNotFound
+	}
+	key := namespacedSubject{b.Namespace, b.Subject}
+	binding, ok := r.roleBindings[key]
+	if !ok {
+		binding = RoleBinding{Namespace: b.Namespace, Subject: b.Subject, CreatedAt: now()}
+	}
+	binding.Role = b.Role
+	binding.UpdatedAt = now()
+	r.roleBindings[key] = binding
+	return binding, !ok, nil
+}
+
+// DeleteRoleBinding takes role of subject in namespace away, and returns ErrRoleBindingNotFound
+// when the subject has no role there.
+func (r *MemoryRepo) DeleteRoleBinding(namespace string, subject string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.namespaces[namespace]; !ok {
+		return ErrNamespaceNotFound
+	}
+	key := namespacedSubject{namespace, subject}
+	if _, ok := r.roleBindings[key]; !ok {
+		return ErrRoleBindingNotFound
+	}
+	delete(r.roleBindings, key)
+	return nil
+}
+
+// AccessList returns subjects which may change boolean with id from memory, in order of their names.
+// It is empty when every editor of the namespace may change the boolean.
+func (r *MemoryRepo) AccessList(id uuid.UUID) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, ok := r.visible(id); !ok {
+		return nil, ErrNotFound
+	}
+	return append([]string{}, r.accessLists[id]...), nil
+}
+
+// DeletedAccessList returns subjects which may change deleted boolean with id from memory, in order of their names.
+// It returns ErrNotFound when the boolean is not deleted.
+func (r *MemoryRepo) DeletedAccessList(id uuid.UUID) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if b, ok := r.deleted[id]; !ok || b.Namespace != r.namespaceName() {
+		return nil, ErrNotFound
+	}
+	return append([]string{}, r.accessLists[id]...), nil
+}
+
+// SetAccessList replaces subjects which may change boolean with id, and returns them in order of their names.
+// Empty list lets every editor of the namespace change the boolean again.
+func (r *MemoryRepo) SetAccessList(id uuid.UUID, subjects []string) ([]string, error) {
+	subjects, err := prepareAccessList(subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.current(id); !ok {
+		return nil, ErrNotFound
+	}
+	if len(subjects) == 0 {
+		delete(r.accessLists, id)
+	} else {
+		r.accessLists[id] = subjects
+	}
+	return append([]string{}, subjects...), nil
+}
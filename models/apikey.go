@@ -0,0 +1,409 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix starts every API key, so keys are told apart from other secrets.
+const APIKeyPrefix = "bas_"
+
+// apiKeyBytes is number of random bytes in an API key.
+const apiKeyBytes = 32
+
+// shownPrefixLength is number of leading characters of an API key which are stored as they are,
+// so a key can be recognized in a list of keys.
+const shownPrefixLength = len(APIKeyPrefix) + 8
+
+// MaxAPIKeyNameLength is maximum number of characters in name of an API key.
+const MaxAPIKeyNameLength = 255
+
+// AdminAPIKeyName is name of the admin key, which is not stored. Stored API keys can not have it,
+// so history and role bindings tell them apart from the admin key.
+const AdminAPIKeyName = "admin"
+
+// Scope is permission an API key grants. Every scope grants everything scopes before it grant.
+type Scope string
+
+// Scopes of API keys, from the narrowest one.
+const (
+	// ScopeRead reads booleans and namespaces.
+	ScopeRead Scope = "read"
+	// ScopeWrite also creates, changes and deletes booleans.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin also purges booleans, manages namespaces and API keys.
+	ScopeAdmin Scope = "admin"
+)
+
+// rank returns how much the scope grants, and zero for an unknown scope.
+func (s Scope) rank() int {
+	switch s {
+	case ScopeRead:
+		return 1
+	case ScopeWrite:
+		return 2
+	case ScopeAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Valid reports whether s is one of known scopes.
+func (s Scope) Valid() bool {
+	return s.rank() > 0
+}
+
+// Scopes are scopes of an API key, kept in order they were given.
+// They are stored in a single column as ",read,write,".
+type Scopes []Scope
+
+// GormDataType stores scopes in a text column.
+func (Scopes) GormDataType() string {
+	return "string"
+}
+
+// Value stores scopes in database.
+func (s Scopes) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(s))
+	for _, scope := range s {
+		names = append(names, string(scope))
+	}
+	return "," + strings.Join(names, ",") + ",", nil
+}
+
+// Scan reads scopes stored in database.
+func (s *Scopes) Scan(src interface{}) error {
+	var stored string
+	switch src := src.(type) {
+	case nil:
+	case string:
+		stored = src
+	case []byte:
+		stored = string(src)
+	default:
+		return fmt.Errorf("can not read scopes from %T", src)
+	}
+
+	*s = nil
+	if stored = strings.Trim(stored, ","); stored != "" {
+		for _, name := range strings.Split(stored, ",") {
+			*s = append(*s, Scope(name))
+		}
+	}
+	return nil
+}
+
+// Allow reports whether any of the scopes grants required scope.
+func (s Scopes) Allow(required Scope) bool {
+	for _, scope := range s {
+		if scope.rank() >= required.rank() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateScopes returns ErrValidation when scopes are missing or any of them is unknown.
+func validateScopes(scopes Scopes) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: API key must have at least one scope", ErrValidation)
+	}
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return fmt.Errorf("%w: scope %q must be one of %s, %s, %s", ErrValidation, scope, ScopeRead, ScopeWrite, ScopeAdmin)
+		}
+	}
+	return nil
+}
+
+// APIKey authenticates requests of a client. Only hash of the key is stored, so the key itself
+// is known only when it is created or rotated. Prefix is the start of the key, which is enough
+// to recognize it. Revoked key does not authenticate anymore, and it is kept to be listed.
+// API keys are not in any namespace.
+type APIKey struct {
+	ID        uuid.UUID `gorm:"primaryKey"`
+	Name      string    `gorm:"size:255;not null"`
+	Prefix    string    `gorm:"size:16;not null"`
+	Hash      string    `gorm:"size:64;not null;uniqueIndex"`
+	Scopes    Scopes    `gorm:"size:100;not null"`
+	CreatedAt time.Time
+	RotatedAt sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+// Revoked reports whether the key was revoked.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt.Valid
+}
+
+// HashAPIKey returns hash of API key which is stored instead of the key. Keys are long and random,
+// so a fast hash is enough to keep them from being read out of the database.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey generates a new random API key.
+func newAPIKey() (string, error) {
+	random := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// withSecret returns k with hash and prefix of a newly generated key, and the key itself.
+func (k APIKey) withSecret() (APIKey, string, error) {
+	secret, err := newAPIKey()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	k.Prefix = secret[:shownPrefixLength]
+	k.Hash = HashAPIKey(secret)
+	return k, secret, nil
+}
+
+// prepareAPIKey validates new API key, and returns it ready to be stored along with its secret.
+func prepareAPIKey(k APIKey) (APIKey, string, error) {
+	if k.Name == "" || utf8.RuneCountInString(k.Name) > MaxAPIKeyNameLength {
+		return APIKey{}, "", fmt.Errorf("%w: name of API key must be from 1 to %d characters long", ErrValidation, MaxAPIKeyNameLength)
+	}
+	if k.Name == AdminAPIKeyName {
+		return APIKey{}, "", fmt.Errorf("%w: name %q is reserved for the admin key", ErrValidation, AdminAPIKeyName)
+	}
+	if err := validateScopes(k.Scopes); err != nil {
+		return APIKey{}, "", err
+	}
+
+	k.ID = uuid.New()
+	k.CreatedAt = now()
+	k.RotatedAt = sql.NullTime{}
+	k.RevokedAt = sql.NullTime{}
+	return k.withSecret()
+}
+
+// APIKeys returns every API key in database, revoked ones included, in order they were created.
+func (*RepoImplement) APIKeys() ([]APIKey, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	keys := []APIKey{}
+	if err := db.Order("created_at").Order("id").Find(&keys).Error; err != nil {
+		return nil, storageError(err)
+	}
+	return keys, nil
+}
+
+// CreateAPIKey stores a new API key with name and scopes of k, and returns it along with the key itself,
+// which is not stored.
+func (*RepoImplement) CreateAPIKey(k APIKey) (APIKey, string, error) {
+	k, secret, err := prepareAPIKey(k)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return APIKey{}, "", connectionError(err)
+	}
+	if err := db.Create(&k).Error; err != nil {
+		return APIKey{}, "", storageError(err)
+	}
+	return k, secret, nil
+}
+
+// RotateAPIKey replaces API key with id by a new one with the same name and scopes, and returns it along
+// with the key itself. The old key stops authenticating at once, and ErrAPIKeyRevoked is returned
+// for a revoked key.
+func (*RepoImplement) RotateAPIKey(id uuid.UUID) (APIKey, string, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return APIKey{}, "", connectionError(err)
+	}
+
+	var (
+		key    APIKey
+		secret string
+	)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockAPIKey(tx, id)
+		if err != nil {
+			return err
+		}
+		if old.Revoked() {
+			return ErrAPIKeyRevoked
+		}
+		if key, secret, err = old.withSecret(); err != nil {
+			return err
+		}
+		key.RotatedAt = sql.NullTime{Time: now(), Valid: true}
+		return tx.Model(&APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"prefix":     key.Prefix,
+			"hash":       key.Hash,
+			"rotated_at": key.RotatedAt,
+		}).Error
+	})
+	if err != nil {
+		return APIKey{}, "", storageError(err)
+	}
+	return key, secret, nil
+}
+
+// RevokeAPIKey revokes API key with id, so it does not authenticate anymore, and returns it.
+// Revoking a revoked key keeps time it was first revoked at.
+func (*RepoImplement) RevokeAPIKey(id uuid.UUID) (APIKey, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return APIKey{}, connectionError(err)
+	}
+
+	var key APIKey
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if key, err = lockAPIKey(tx, id); err != nil || key.Revoked() {
+			return err
+		}
+		key.RevokedAt = sql.NullTime{Time: now(), Valid: true}
+		return tx.Model(&APIKey{}).Where("id = ?", id).Update("revoked_at", key.RevokedAt).Error
+	})
+	if err != nil {
+		return APIKey{}, storageError(err)
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns API key whose hash matches the given key, and ErrAPIKeyNotFound
+// when there is no such key or it was revoked.
+func (*RepoImplement) AuthenticateAPIKey(secret string) (APIKey, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return APIKey{}, connectionError(err)
+	}
+
+	var keys []APIKey
+	if err := db.Limit(1).Find(&keys, "hash = ? AND revoked_at IS NULL", HashAPIKey(secret)).Error; err != nil {
+		return APIKey{}, storageError(err)
+	}
+	if len(keys) == 0 {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return keys[0], nil
+}
+
+// lockAPIKey reads API key with id and locks it in tx until it ends, or returns ErrAPIKeyNotFound.
+func lockAPIKey(tx *gorm.DB, id uuid.UUID) (APIKey, error) {
+	var keys []APIKey
+	if err := lockedQuery(tx).Limit(1).Find(&keys, "id = ?", id).Error; err != nil {
+		return APIKey{}, err
+	}
+	if len(keys) == 0 {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return keys[0], nil
+}
+
+// APIKeys returns every API key in memory, revoked ones included, in order they were created.
+func (r *MemoryRepo) APIKeys() ([]APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]APIKey, 0, len(r.apiKeys))
+	for _, key := range r.apiKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID.String() < keys[j].ID.String()
+	})
+	return keys, nil
+}
+
+// CreateAPIKey stores a new API key with name and scopes of k, and returns it along with the key itself,
+// which is not stored.
+func (r *MemoryRepo) CreateAPIKey(k APIKey) (APIKey, string, error) {
+	k, secret, err := prepareAPIKey(k)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.apiKeys[k.ID] = k
+	return k, secret, nil
+}
+
+// RotateAPIKey replaces API key with id by a new one with the same name and scopes, and returns it along
+// with the key itself. The old key stops authenticating at once, and ErrAPIKeyRevoked is returned
+// for a revoked key.
+func (r *MemoryRepo) RotateAPIKey(id uuid.UUID) (APIKey, string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old, ok := r.apiKeys[id]
+	if !ok {
+		return APIKey{}, "", ErrAPIKeyNotFound
+	}
+	if old.Revoked() {
+		return APIKey{}, "", ErrAPIKeyRevoked
+	}
+	key, secret, err := old.withSecret()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key.RotatedAt = sql.NullTime{Time: now(), Valid: true}
+	r.apiKeys[id] = key
+	return key, secret, nil
+}
+
+// RevokeAPIKey revokes API key with id, so it does not authenticate anymore, and returns it.
+// Revoking a revoked key keeps time it was first revoked at.
+func (r *MemoryRepo) RevokeAPIKey(id uuid.UUID) (APIKey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	if !key.Revoked() {
+		key.RevokedAt = sql.NullTime{Time: now(), Valid: true}
+		r.apiKeys[id] = key
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns API key whose hash matches the given key, and ErrAPIKeyNotFound
+// when there is no such key or it was revoked.
+func (r *MemoryRepo) AuthenticateAPIKey(secret string) (APIKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	hash := HashAPIKey(secret)
+	for _, key := range r.apiKeys {
+		if key.Hash == hash && !key.Revoked() {
+			return key, nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotFound
+}
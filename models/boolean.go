@@ -1,99 +1,635 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/hrishi32/boolean-as-service/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // RepoImplement is a struct for implementation of Repo interface
-type RepoImplement struct{}
+// Changes are recorded in history with its audit, which is set by WithAudit.
+// It reads and changes only booleans of its namespace, which is set by InNamespace.
+type RepoImplement struct {
+	audit     Audit
+	namespace string
+}
 
-// Boolean is a struct to define basic structure of boolean object
+// Boolean is a struct to define basic structure of boolean object.
+// Indexes on Namespace followed by CreatedAt or Key, and then ID, serve the sort orders of List.
 type Boolean struct {
-	ID    uuid.UUID `gorm:"primaryKey;column:id"`
+	ID uuid.UUID `gorm:"primaryKey;column:id;index:idx_booleans_namespace_created_at_id,priority:3;index:idx_booleans_namespace_key_id,priority:3"`
+	// Namespace is set by the repo, and a boolean stays in it for good.
+	Namespace string `gorm:"size:63;not null;default:default;index:idx_booleans_namespace_created_at_id,priority:1;index:idx_booleans_namespace_key_id,priority:1" json:"-"`
+	// Value is value of the boolean in every environment without its own value in Environments.
 	Value bool
-	Key   string
+	Key   string `gorm:"size:255;index:idx_booleans_namespace_key_id,priority:2"`
+	// Version starts at FirstVersion and grows by one with every change.
+	Version      int64             `gorm:"not null;default:1"`
+	CreatedAt    time.Time         `gorm:"index:idx_booleans_namespace_created_at_id,priority:2"`
+	UpdatedAt    time.Time         `json:"-"`
+	Description  string            `gorm:"size:1000"`
+	Owner        string            `gorm:"size:255;index"`
+	Tags         Tags              `gorm:"size:1400"`
+	Environments EnvironmentValues `gorm:"size:1000"`
+	// ExpiresAt is when the boolean gets its Fallback value, or is deleted when it has no fallback.
+	ExpiresAt sql.NullTime `gorm:"index" json:"-"`
+	Fallback  sql.NullBool `json:"-"`
+	// DeletedAt is set until the boolean is purged, and every query leaves it out.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// MaxKeyLength is maximum number of characters in a key.
+const MaxKeyLength = 255
+
+// MaxGetMany is maximum number of ids and keys together in a single call of GetMany.
+const MaxGetMany = 100
+
+// FirstVersion is version of a newly created boolean.
+const FirstVersion int64 = 1
+
+// AnyVersion given to Update, Patch or Delete applies the change regardless of current version.
+const AnyVersion int64 = 0
+
+// BooleanPatch is a partial update of boolean, fields which are nil stay unchanged.
+// Environments changes only values of environments it has.
+// ExpiresAt and Fallback which are not valid remove expiry and fallback of boolean.
+type BooleanPatch struct {
+	Value        *bool
+	Key          *string
+	Description  *string
+	Owner        *string
+	Tags         *Tags
+	Environments *EnvironmentPatch
+	ExpiresAt    *sql.NullTime
+	Fallback     *sql.NullBool
+}
+
+// Apply returns b with changes of the patch.
+func (p BooleanPatch) Apply(b Boolean) Boolean {
+	if p.Value != nil {
+		b.Value = *p.Value
+	}
+	if p.Key != nil {
+		b.Key = *p.Key
+	}
+	if p.Description != nil {
+		b.Description = *p.Description
+	}
+	if p.Owner != nil {
+		b.Owner = *p.Owner
+	}
+	if p.Tags != nil {
+		b.Tags = p.Tags.normalized()
+	}
+	if p.Environments != nil {
+		b.Environments = p.Environments.apply(b.Environments)
+	}
+	if p.ExpiresAt != nil {
+		b.ExpiresAt = storedExpiry(*p.ExpiresAt)
+	}
+	if p.Fallback != nil {
+		b.Fallback = *p.Fallback
+	}
+	return b
+}
+
+// validateKey returns ErrValidation when key can not be stored.
+func validateKey(key string) error {
+	if utf8.RuneCountInString(key) > MaxKeyLength {
+		return fmt.Errorf("%w: key must be at most %d characters long", ErrValidation, MaxKeyLength)
+	}
+	return nil
+}
+
+// validate returns ErrValidation when patch can not be stored over boolean stored. Same as when boolean
+// is created, fallback is given only along with expiry, or to a boolean which has one.
+func (p BooleanPatch) validate(stored Boolean) error {
+	if p.Key != nil {
+		if err := validateKey(*p.Key); err != nil {
+			return err
+		}
+	}
+	if p.Description != nil {
+		if err := validateDescription(*p.Description); err != nil {
+			return err
+		}
+	}
+	if p.Owner != nil {
+		if err := validateOwner(*p.Owner); err != nil {
+			return err
+		}
+	}
+	if p.Tags != nil {
+		if err := validateTags(*p.Tags); err != nil {
+			return err
+		}
+	}
+	if p.Environments != nil {
+		if err := p.Environments.validate(); err != nil {
+			return err
+		}
+	}
+	expiresAt := stored.ExpiresAt
+	if p.ExpiresAt != nil {
+		if err := validateExpiry(*p.ExpiresAt); err != nil {
+			return err
+		}
+		expiresAt = *p.ExpiresAt
+	}
+	if p.Fallback != nil && p.Fallback.Valid && !expiresAt.Valid {
+		return fmt.Errorf("%w: fallback requires expires_at or ttl", ErrValidation)
+	}
+	return nil
+}
+
+// now returns creation time of a boolean, in UTC and with precision every database keeps.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
 }
 
-// Migrate is a custom function for AutoMigration
-func Migrate() {
-	db, connectionError := database.GetConnection()
-	if connectionError == nil {
-		b := Boolean{ID: uuid.New(), Value: true, Key: "SomeKey"}
-		db.AutoMigrate(&b)
+// Migrate creates and updates tables of every model, and fills columns which booleans stored by earlier
+// versions do not have. It returns the first error, and it is safe to run again once the error is fixed.
+//
+// boolean_keys stored before namespaces existed has no namespace column, as keys were unique among all
+// booleans. Migrate drops it, and reserves key of every boolean again in its namespace.
+func Migrate() error {
+	db, err := database.GetConnection()
+	if err != nil {
+		return err
+	}
+
+	migrator := db.Migrator()
+	if migrator.HasTable(&BooleanKey{}) && !migrator.HasColumn(&BooleanKey{}, "namespace") {
+		if err := migrator.DropTable(&BooleanKey{}); err != nil {
+			return fmt.Errorf("boolean_keys without namespace can not be dropped: %w", err)
+		}
+	}
+	keysExisted := migrator.HasTable(&BooleanKey{})
+
+	if err := db.AutoMigrate(&Namespace{}, &Boolean{}, &BooleanKey{}, &HistoryEntry{}, &Schedule{}, &APIKey{}, &RoleBinding{}, &AccessEntry{}); err != nil {
+		return err
+	}
+	// Keys and tags differ even when they differ only in case, which tables created by earlier versions on MySQL ignored.
+	if err := database.MigrateCollation(db, &Boolean{}, "Key", "Tags"); err != nil {
+		return fmt.Errorf("collation of keys and tags can not be changed: %w", err)
+	}
+	if err := database.MigrateCollation(db, &BooleanKey{}, "Key"); err != nil {
+		return fmt.Errorf("collation of keys can not be changed: %w", err)
+	}
+	if err := createDefaultNamespace(db); err != nil {
+		return fmt.Errorf("default namespace can not be created: %w", err)
+	}
+
+	fills := []struct {
+		model  interface{}
+		column string
+		value  interface{}
+	}{
+		// Booleans stored before creation time was kept are listed as created now.
+		{&Boolean{}, "created_at", now()},
+		// Booleans stored before update time was kept were last changed when they were created as far as is known,
+		// and have no metadata.
+		{&Boolean{}, "updated_at", gorm.Expr("created_at")},
+		{&Boolean{}, "description", ""},
+		{&Boolean{}, "owner", ""},
+		{&Boolean{}, "tags", Tags{}},
+		// Booleans stored before environments existed have the same value in every environment.
+		{&Boolean{}, "environments", EnvironmentValues{}},
+		// History recorded before requests were authenticated has no principal.
+		{&HistoryEntry{}, "principal", ""},
+	}
+	for _, fill := range fills {
+		if err := db.Model(fill.model).Where(fill.column+" IS NULL").UpdateColumn(fill.column, fill.value).Error; err != nil {
+			return fmt.Errorf("%s can not be filled: %w", fill.column, err)
+		}
 	}
 
+	if !keysExisted {
+		if err := reserveExistingKeys(db); err != nil {
+			return fmt.Errorf("existing keys can not be reserved: %w", err)
+		}
+	}
+	return nil
 }
 
 // Get receives a boolean object from database using id.
-func (*RepoImplement) Get(id uuid.UUID) (Boolean, error) {
-	db, connectionError := database.GetConnection()
+func (r *RepoImplement) Get(id uuid.UUID) (Boolean, error) {
+	db, err := database.GetConnection()
 
-	if connectionError != nil {
-		return Boolean{}, connectionError
+	if err != nil {
+		return Boolean{}, connectionError(err)
 	}
 	var boolean Boolean
-	if err := db.First(&boolean, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
-		notFoundError := errors.New("Record not found")
-		return Boolean{}, notFoundError
+	if err := db.First(&boolean, "id = ? AND namespace = ?", id, r.namespaceName()).Error; err != nil {
+		return Boolean{}, storageError(err)
 	}
 
+	// Expiry applies as soon as it passes, even before it is stored.
+	boolean, ok := boolean.atTime(now())
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
 	return boolean, nil
 }
 
-// Create inserts a new boolean object in the database
-func (*RepoImplement) Create(b Boolean) (uuid.UUID, error) {
-	id := uuid.New()
-	b.ID = id
-	db, connectionError := database.GetConnection()
-	if connectionError != nil {
-		return uuid.UUID{}, connectionError
+// GetMany receives booleans with any of given ids or keys from database in a single query.
+// Booleans which are not found are left out, and order of the result is not defined.
+func (r *RepoImplement) GetMany(ids []uuid.UUID, keys []string) ([]Boolean, error) {
+	booleans := []Boolean{}
+	if len(ids) == 0 && len(keys) == 0 {
+		return booleans, nil
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	idValues := make([]interface{}, len(ids))
+	for i, id := range ids {
+		idValues[i] = id
+	}
+	keyValues := make([]interface{}, len(keys))
+	for i, key := range keys {
+		keyValues[i] = key
+	}
+
+	err = db.Joins("LEFT JOIN boolean_keys ON boolean_keys.boolean_id = booleans.id").
+		Where(clause.Eq{Column: clause.Column{Table: "booleans", Name: "namespace"}, Value: r.namespaceName()}).
+		Where(clause.Or(
+			clause.IN{Column: clause.Column{Table: "booleans", Name: "id"}, Values: idValues},
+			clause.IN{Column: clause.Column{Table: "boolean_keys", Name: "key"}, Values: keyValues},
+		)).
+		Find(&booleans).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	found := booleans[:0]
+	for _, b := range booleans {
+		if b, ok := b.atTime(now()); ok {
+			found = append(found, b)
+		}
+	}
+	return found, nil
+}
+
+// Create inserts a new boolean object in the database, and returns it with newly assigned id.
+func (r *RepoImplement) Create(b Boolean) (Boolean, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
 	}
-	result := db.Create(&b)
 
-	if result.Error != nil {
-		return uuid.UUID{}, result.Error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		b, err = createBoolean(tx, r.audit, r.namespaceName(), b)
+		return err
+	})
+
+	if err != nil {
+		return Boolean{}, storageError(err)
 	}
 
-	return id, nil
+	return b, nil
 }
 
-// Update modifies the existing boolean in the database.
-func (r *RepoImplement) Update(id uuid.UUID, newBoolean Boolean) error {
-	db, connectionError := database.GetConnection()
+// Update replaces the existing boolean in the database, when it still has given version, and returns the updated boolean.
+func (r *RepoImplement) Update(id uuid.UUID, newBoolean Boolean, version int64) (Boolean, error) {
+	newBoolean, err := prepareBoolean(newBoolean)
+	if err != nil {
+		return Boolean{}, err
+	}
+
+	db, err := database.GetConnection()
 
-	if connectionError != nil {
-		return connectionError
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockBoolean(tx, r.namespaceName(), id, version)
+		if err != nil {
+			return err
+		}
+		boolean, err = changeBoolean(tx, r.audit, HistoryUpdate, old, newBoolean)
+		return err
+	})
+	if err != nil {
+		return Boolean{}, storageError(err)
 	}
 
-	_, err := r.Get(id)
+	return boolean, nil
+}
+
+// Patch changes only fields given in patch when boolean still has given version, and returns the updated boolean.
+func (r *RepoImplement) Patch(id uuid.UUID, patch BooleanPatch, version int64) (Boolean, error) {
+	db, err := database.GetConnection()
 	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		boolean, err = patchBoolean(tx, r.audit, r.namespaceName(), id, patch, version)
 		return err
+	})
+	if err != nil {
+		return Boolean{}, storageError(err)
 	}
-	newBoolean.ID = id
 
-	db.Save(&newBoolean)
+	return boolean, nil
+}
 
-	return nil
+// Toggle flips value of the boolean, and returns the updated boolean.
+func (r *RepoImplement) Toggle(id uuid.UUID) (Boolean, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockBoolean(tx, r.namespaceName(), id, AnyVersion)
+		if err != nil {
+			return err
+		}
+		toggled := old
+		toggled.Value = !old.Value
+		boolean, err = changeBoolean(tx, r.audit, HistoryUpdate, old, toggled)
+		return err
+	})
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	return boolean, nil
+}
+
+// CompareAndSwap sets value to new, only when current value is expected.
+// On mismatch, it returns the current boolean with ErrValueMismatch.
+func (r *RepoImplement) CompareAndSwap(id uuid.UUID, expected bool, new bool) (Boolean, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		boolean, err = lockBoolean(tx, r.namespaceName(), id, AnyVersion)
+		if err != nil {
+			return err
+		}
+		if boolean.Value != expected {
+			return ErrValueMismatch
+		}
+		if expected == new {
+			return nil
+		}
+
+		swapped := boolean
+		swapped.Value = new
+		boolean, err = changeBoolean(tx, r.audit, HistoryUpdate, boolean, swapped)
+		return err
+	})
+	if errors.Is(err, ErrValueMismatch) {
+		return boolean, err
+	}
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	return boolean, nil
+}
+
+// List returns a page of booleans matching the filters of options, in their sort order.
+// Both sort orders are served by an index, and the page starts right after options.After.
+func (r *RepoImplement) List(options ListOptions) (Page, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Page{}, connectionError(err)
+	}
+	if err := namespaceExists(db, r.namespaceName()); err != nil {
+		return Page{}, storageError(err)
+	}
+
+	id := clause.Column{Name: "id"}
+	key := clause.Column{Name: "key"}
+	at := now()
+	tx := notExpired(db.Model(&Boolean{}), at).Where(clause.Eq{Column: clause.Column{Name: "namespace"}, Value: r.namespaceName()})
+
+	if options.Value != nil {
+		tx = withValue(tx, *options.Value, at)
+	}
+	if options.Key != nil {
+		tx = tx.Where(clause.Eq{Column: key, Value: *options.Key})
+	}
+	if options.KeyPrefix != "" {
+		tx = tx.Where(matchText(tx, key, options.KeyPrefix, ""))
+	}
+	if options.Owner != nil {
+		tx = tx.Where(clause.Eq{Column: clause.Column{Name: "owner"}, Value: *options.Owner})
+	}
+	for _, tag := range options.Tags {
+		// Tags are stored between commas, see Tags.Value.
+		tx = tx.Where(matchText(tx, clause.Column{Name: "tags"}, "", ","+tag+",", ""))
+	}
+
+	column, position := clause.Column{Name: "created_at"}, interface{}(nil)
+	if options.Sort == SortByKey {
+		column = key
+	}
+	if options.After != nil {
+		position = options.After.CreatedAt
+		if options.Sort == SortByKey {
+			position = options.After.Key
+		}
+
+		// Rows after (position, id), written out so the index on both columns can be used.
+		if options.Descending {
+			tx = tx.Where(clause.Or(
+				clause.Lt{Column: column, Value: position},
+				clause.And(clause.Eq{Column: column, Value: position}, clause.Lt{Column: id, Value: options.After.ID}),
+			))
+		} else {
+			tx = tx.Where(clause.Or(
+				clause.Gt{Column: column, Value: position},
+				clause.And(clause.Eq{Column: column, Value: position}, clause.Gt{Column: id, Value: options.After.ID}),
+			))
+		}
+	}
+
+	// One more boolean than needed tells whether there is a next page.
+	limit := options.limit()
+	var booleans []Boolean
+	err = tx.Order(clause.OrderByColumn{Column: column, Desc: options.Descending}).
+		Order(clause.OrderByColumn{Column: id, Desc: options.Descending}).
+		Limit(limit + 1).
+		Find(&booleans).Error
+	if err != nil {
+		return Page{}, storageError(err)
+	}
+
+	for i, b := range booleans {
+		booleans[i], _ = b.atTime(at)
+	}
+
+	page := Page{Booleans: booleans}
+	if len(booleans) > limit {
+		page.Booleans = booleans[:limit]
+		page.Next = cursorOf(page.Booleans[limit-1])
+	}
+	return page, nil
+}
+
+// Delete marks the boolean as deleted using id, when it still has given version, and releases its key.
+func (r *RepoImplement) Delete(id uuid.UUID, version int64) error {
+	db, err := database.GetConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return deleteBoolean(tx, r.audit, r.namespaceName(), id, version)
+	})
+
+	return storageError(err)
+}
+
+// createBoolean inserts b with a new id into namespace in tx, when quota of the namespace allows,
+// and returns the stored boolean.
+func createBoolean(tx *gorm.DB, audit Audit, namespace string, b Boolean) (Boolean, error) {
+	b, err := prepareBoolean(b)
+	if err != nil {
+		return Boolean{}, err
+	}
+	if err := reserveQuota(tx, namespace); err != nil {
+		return Boolean{}, err
+	}
 
+	b.ID = uuid.New()
+	b.Namespace = namespace
+	b.Version = FirstVersion
+	b.CreatedAt = now()
+	b.UpdatedAt = b.CreatedAt
+	if err := tx.Create(&b).Error; err != nil {
+		return Boolean{}, err
+	}
+	if err := reserveKey(tx, namespace, b.ID, b.Key); err != nil {
+		return Boolean{}, err
+	}
+
+	return b, recordChange(tx, audit, HistoryCreate, nil, &b)
 }
 
-// Delete removes the boolean from database using id.
-func (r *RepoImplement) Delete(id uuid.UUID) error {
-	db, connectionError := database.GetConnection()
-	if connectionError != nil {
-		return connectionError
+// patchBoolean applies patch in tx to boolean of namespace with id, when it still has given version.
+func patchBoolean(tx *gorm.DB, audit Audit, namespace string, id uuid.UUID, patch BooleanPatch, version int64) (Boolean, error) {
+	old, err := lockBoolean(tx, namespace, id, version)
+	if err != nil {
+		return Boolean{}, err
+	}
+	if err := patch.validate(old); err != nil {
+		return Boolean{}, err
+	}
+	if patch == (BooleanPatch{}) {
+		return old, nil
 	}
-	b, err := r.Get(id)
 
+	return changeBoolean(tx, audit, HistoryUpdate, old, patch.Apply(old))
+}
+
+// deleteBoolean marks boolean of namespace with id as deleted in tx, when it still has given version.
+func deleteBoolean(tx *gorm.DB, audit Audit, namespace string, id uuid.UUID, version int64) error {
+	old, err := lockBoolean(tx, namespace, id, version)
 	if err != nil {
 		return err
 	}
+	return removeBoolean(tx, audit, HistoryDelete, old)
+}
+
+// removeBoolean marks boolean locked in tx as deleted, releases its key, and records the change as action.
+func removeBoolean(tx *gorm.DB, audit Audit, action HistoryAction, old Boolean) error {
+	// Time is set here rather than by gorm, so it is in UTC same as the one given to Purge.
+	if err := tx.Model(&Boolean{}).Where("id = ?", old.ID).UpdateColumn("deleted_at", now()).Error; err != nil {
+		return err
+	}
+	if err := releaseKey(tx, old.ID); err != nil {
+		return err
+	}
+	return recordChange(tx, audit, action, &old, nil)
+}
 
-	db.Delete(&b)
+// lockBoolean reads boolean of namespace with id in tx, checking its version unless it is AnyVersion.
+// The row stays locked until tx ends, so history records exactly the state which is changed.
+// Expiry which passed is stored first, though it is undone along with tx when the change fails.
+func lockBoolean(tx *gorm.DB, namespace string, id uuid.UUID, version int64) (Boolean, error) {
+	var boolean Boolean
+	if err := lockedQuery(tx).First(&boolean, "id = ? AND namespace = ?", id, namespace).Error; err != nil {
+		return Boolean{}, err
+	}
 
-	return nil
+	boolean, ok, err := expireBoolean(tx, boolean, now())
+	if err != nil {
+		return Boolean{}, err
+	}
+	if !ok {
+		return Boolean{}, gorm.ErrRecordNotFound
+	}
+	if version != AnyVersion && boolean.Version != version {
+		return Boolean{}, ErrVersionMismatch
+	}
+	return boolean, nil
+}
+
+// lockedQuery locks rows read by tx until it ends. SQLite has no row locks, but its transactions
+// already write one at a time.
+func lockedQuery(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == database.DriverSQLite {
+		return tx
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// changeBoolean stores value, key, description, owner, tags, environments, expiry and fallback of b over old
+// boolean locked in tx as its next version, moves reservation of its key when key changed, and records the change
+// as action. Id, namespace and creation time stay as they were. It returns the stored boolean.
+func changeBoolean(tx *gorm.DB, audit Audit, action HistoryAction, old Boolean, b Boolean) (Boolean, error) {
+	b.ID = old.ID
+	b.Namespace = old.Namespace
+	b.Version = old.Version + 1
+	b.CreatedAt = old.CreatedAt
+	b.UpdatedAt = now()
+	err := tx.Model(&Boolean{}).Where("id = ?", b.ID).Updates(map[string]interface{}{
+		"value":        b.Value,
+		"key":          b.Key,
+		"version":      b.Version,
+		"updated_at":   b.UpdatedAt,
+		"description":  b.Description,
+		"owner":        b.Owner,
+		"tags":         b.Tags,
+		"environments": b.Environments,
+		"expires_at":   b.ExpiresAt,
+		"fallback":     b.Fallback,
+	}).Error
+	if err != nil {
+		return Boolean{}, err
+	}
+
+	if b.Key != old.Key {
+		if err := releaseKey(tx, b.ID); err != nil {
+			return Boolean{}, err
+		}
+		if err := reserveKey(tx, b.Namespace, b.ID, b.Key); err != nil {
+			return Boolean{}, err
+		}
+	}
+
+	return b, recordChange(tx, audit, action, &old, &b)
 }
@@ -0,0 +1,281 @@
+package models_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"github.com/hrishi32/boolean-as-service/models"
+	"github.com/hrishi32/boolean-as-service/models/repotest"
+	"gorm.io/gorm"
+)
+
+// postgresPort is port of embedded PostgreSQL, which stands in for a local server when TEST_POSTGRES_DSN is not set.
+const postgresPort = 54329
+
+// startPostgres starts embedded PostgreSQL until the test ends, and returns its DSN. The test fails when it
+// can not start, as its binaries are downloaded on first run and PostgreSQL refuses to run as root,
+// unless TEST_POSTGRES_SKIP is set.
+func startPostgres(t *testing.T) string {
+	if os.Getenv("TEST_POSTGRES_SKIP") != "" {
+		t.Skip("TEST_POSTGRES_SKIP is set")
+	}
+	server := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(postgresPort).
+		RuntimePath(t.TempDir()).
+		Logger(ioutil.Discard))
+	if err := server.Start(); err != nil {
+		t.Fatalf("embedded PostgreSQL can not start, set TEST_POSTGRES_DSN to use a local server or TEST_POSTGRES_SKIP to skip: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := server.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+	return fmt.Sprintf("host=127.0.0.1 port=%d user=postgres password=postgres dbname=postgres sslmode=disable", postgresPort)
+}
+
+// closeOnCleanup closes connection when the test ends.
+func closeOnCleanup(t *testing.T, connection *gorm.DB) {
+	t.Cleanup(func() {
+		if db, err := connection.DB(); err == nil {
+			db.Close()
+		}
+	})
+}
+
+// throwaway creates a schema on PostgreSQL, or a database on MySQL, which is dropped when the test ends,
+// and returns DSN which uses it. Tests never touch tables which already are on the server of dsn.
+// SQLite dsn is returned as it is, as it is a fresh file already.
+func throwaway(t *testing.T, driver string, dsn string) string {
+	if driver == database.DriverSQLite {
+		return dsn
+	}
+
+	connection, err := database.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeOnCleanup(t, connection)
+
+	name := "repotest_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	kind := "SCHEMA"
+	if driver == database.DriverMySQL {
+		kind = "DATABASE"
+	}
+	if err := connection.Exec(fmt.Sprintf("CREATE %s %s", kind, name)).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		drop := fmt.Sprintf("DROP %s %s", kind, name)
+		if driver == database.DriverPostgres {
+			drop += " CASCADE"
+		}
+		if err := connection.Exec(drop).Error; err != nil {
+			t.Error(err)
+		}
+	})
+
+	if driver == database.DriverMySQL {
+		config, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.DBName = name
+		return config.FormatDSN()
+	}
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		return dsn + separator + "search_path=" + name
+	}
+	return dsn + " search_path=" + name
+}
+
+// useDatabase points RepoImplement to a fresh connection of given driver, with empty migrated tables
+// in a throwaway schema or database.
+func useDatabase(t *testing.T, driver string, dsn string) models.Repo {
+	connection, err := database.Open(driver, throwaway(t, driver, dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeOnCleanup(t, connection)
+
+	database.SetConnection(connection)
+	t.Cleanup(func() { database.SetConnection(nil) })
+
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return &models.RepoImplement{}
+}
+
+// TestRepoImplement runs conformance suite against SQLite and PostgreSQL, and against MySQL when TEST_MYSQL_DSN
+// points to a local server. PostgreSQL is embedded unless TEST_POSTGRES_DSN points to a local server.
+func TestRepoImplement(t *testing.T) {
+	t.Run(database.DriverSQLite, func(t *testing.T) {
+		repotest.Run(t, func(t *testing.T) models.Repo {
+			return useDatabase(t, database.DriverSQLite, filepath.Join(t.TempDir(), "boolean.db"))
+		})
+	})
+
+	t.Run(database.DriverPostgres, func(t *testing.T) {
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			dsn = startPostgres(t)
+		}
+		repotest.Run(t, func(t *testing.T) models.Repo {
+			return useDatabase(t, database.DriverPostgres, dsn)
+		})
+	})
+
+	t.Run(database.DriverMySQL, func(t *testing.T) {
+		dsn := os.Getenv("TEST_MYSQL_DSN")
+		if dsn == "" {
+			t.Skip("no local server configured")
+		}
+		repotest.Run(t, func(t *testing.T) models.Repo {
+			return useDatabase(t, database.DriverMySQL, dsn)
+		})
+	})
+}
+
+// firstBoolean is boolean as it was first stored, before versions, timestamps, metadata and namespaces were kept.
+type firstBoolean struct {
+	ID    uuid.UUID `gorm:"primaryKey;column:id"`
+	Value bool
+	Key   string
+}
+
+func (firstBoolean) TableName() string {
+	return "booleans"
+}
+
+// firstBooleanKey is reservation of a key as it was stored before namespaces, when keys were unique among all booleans.
+type firstBooleanKey struct {
+	Key       string    `gorm:"primaryKey;size:255"`
+	BooleanID uuid.UUID `gorm:"not null;uniqueIndex"`
+}
+
+func (firstBooleanKey) TableName() string {
+	return "boolean_keys"
+}
+
+// TestMigrateExistingBooleans checks that booleans stored before timestamps, metadata and namespaces were kept
+// are readable after migration in the default namespace, and were last changed when they were created as far as is known.
+func TestMigrateExistingBooleans(t *testing.T) {
+	connection, err := database.Open(database.DriverSQLite, filepath.Join(t.TempDir(), "boolean.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.SetConnection(connection)
+	t.Cleanup(func() { database.SetConnection(nil) })
+
+	old := firstBoolean{ID: uuid.New(), Value: true, Key: "old"}
+	if err := connection.AutoMigrate(&firstBoolean{}, &firstBooleanKey{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.Create(&old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := connection.Create(&firstBooleanKey{Key: old.Key, BooleanID: old.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &models.RepoImplement{}
+	b, err := r.GetByKey("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := models.Boolean{ID: old.ID, Namespace: models.DefaultNamespace, Value: true, Key: "old", Version: 1, CreatedAt: b.CreatedAt, UpdatedAt: b.CreatedAt}
+	if b.CreatedAt.IsZero() || !reflect.DeepEqual(expected, b) {
+		t.Errorf("expected %+v, got %+v", expected, b)
+	}
+
+	owner := ""
+	page, err := r.List(models.ListOptions{Owner: &owner})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Booleans) != 1 || page.Booleans[0].ID != old.ID {
+		t.Errorf("expected migrated boolean to be listed without owner, got %+v", page.Booleans)
+	}
+
+	// Key is reserved again in the default namespace, and other namespaces may use it.
+	if _, err := r.Create(models.Boolean{Value: false, Key: "old"}); !errors.Is(err, models.ErrDuplicateKey) {
+		t.Errorf("expected models.ErrDuplicateKey, got %v", err)
+	}
+	if _, _, err := r.PutNamespace(models.Namespace{Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.InNamespace("other").Create(models.Boolean{Value: false, Key: "old"}); err != nil {
+		t.Errorf("expected key to be free in another namespace, got %v", err)
+	}
+}
+
+// TestMigrateAgain checks that migration of a database which is already migrated keeps its booleans and namespaces,
+// as it runs on every start.
+func TestMigrateAgain(t *testing.T) {
+	r := useDatabase(t, database.DriverSQLite, filepath.Join(t.TempDir(), "boolean.db"))
+	created, err := r.Create(models.Boolean{Value: true, Key: "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Migrate(); err != nil {
+		t.Fatalf("expected migration to run again, got %v", err)
+	}
+
+	b, err := r.GetByKey("kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(created, b) {
+		t.Errorf("expected %+v, got %+v", created, b)
+	}
+	names, err := r.NamespaceNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{models.DefaultNamespace}, names) {
+		t.Errorf("expected only the default namespace, got %q", names)
+	}
+}
+
+func TestSetEnvironments(t *testing.T) {
+	defer models.SetEnvironments(models.DefaultEnvironments)
+
+	for _, names := range [][]string{nil, {"dev", "dev"}, {"Prod"}, {"-qa"}} {
+		if err := models.SetEnvironments(names); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("expected models.ErrValidation for %q, got %v", names, err)
+		}
+	}
+	if !reflect.DeepEqual(models.DefaultEnvironments, models.Environments()) {
+		t.Errorf("expected environments to stay %q, got %q", models.DefaultEnvironments, models.Environments())
+	}
+
+	if err := models.SetEnvironments([]string{"qa", "live"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.ValidateEnvironment("live"); err != nil {
+		t.Errorf("expected live to be an environment, got %v", err)
+	}
+	if err := models.ValidateEnvironment("prod"); !errors.Is(err, models.ErrValidation) {
+		t.Errorf("expected models.ErrValidation for prod, got %v", err)
+	}
+}
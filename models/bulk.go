@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// MaxBulkOperations is maximum number of operations in a single call of Bulk.
+const MaxBulkOperations = 1000
+
+// OperationType is kind of change made by a single operation of Bulk.
+type OperationType string
+
+// Operation types supported by Bulk.
+const (
+	OperationCreate OperationType = "create"
+	OperationUpdate OperationType = "update"
+	OperationDelete OperationType = "delete"
+)
+
+// Operation is a single change made by Bulk. Create stores Boolean, update applies Patch
+// to boolean with ID, and delete removes boolean with ID. Update and delete check Version
+// same as Patch and Delete do.
+type Operation struct {
+	Type    OperationType
+	ID      uuid.UUID
+	Boolean Boolean
+	Patch   BooleanPatch
+	Version int64
+}
+
+// OperationResult is outcome of a single operation of Bulk. Boolean is the created or updated boolean,
+// and only its ID is set after delete. Err is one of Repo errors when operation failed.
+type OperationResult struct {
+	Boolean Boolean
+	Err     error
+}
+
+// OperationError is returned by Bulk in all-or-nothing mode, when one of operations failed
+// and none of them was applied. It wraps error of the failed operation.
+type OperationError struct {
+	Index int
+	Err   error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns error of the failed operation.
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// unknownOperation returns ErrValidation for operation of type which Bulk does not support.
+func unknownOperation(operation Operation) error {
+	return fmt.Errorf("%w: unknown operation %q", ErrValidation, operation.Type)
+}
+
+// Bulk applies operations in order in a single transaction. When atomic is true, either all
+// of them are applied or none, and failure is returned as OperationError. Otherwise each failed
+// operation is undone alone with a savepoint, and its error is in its result.
+func (r *RepoImplement) Bulk(operations []Operation, atomic bool) ([]OperationResult, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	results := make([]OperationResult, len(operations))
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i, operation := range operations {
+			if atomic {
+				results[i] = applyOperation(tx, r.audit, r.namespaceName(), operation)
+				if results[i].Err != nil {
+					return &OperationError{Index: i, Err: results[i].Err}
+				}
+				continue
+			}
+
+			tx.Transaction(func(tx *gorm.DB) error {
+				results[i] = applyOperation(tx, r.audit, r.namespaceName(), operation)
+				return results[i].Err
+			})
+		}
+		return nil
+	})
+
+	var operationError *OperationError
+	if errors.As(err, &operationError) {
+		return nil, operationError
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return results, nil
+}
+
+// applyOperation makes a single operation of Bulk on booleans of namespace in tx.
+func applyOperation(tx *gorm.DB, audit Audit, namespace string, operation Operation) OperationResult {
+	var b Boolean
+	var err error
+	switch operation.Type {
+	case OperationCreate:
+		b, err = createBoolean(tx, audit, namespace, operation.Boolean)
+	case OperationUpdate:
+		b, err = patchBoolean(tx, audit, namespace, operation.ID, operation.Patch, operation.Version)
+	case OperationDelete:
+		b, err = Boolean{ID: operation.ID}, deleteBoolean(tx, audit, namespace, operation.ID, operation.Version)
+	default:
+		err = unknownOperation(operation)
+	}
+
+	if err != nil {
+		return OperationResult{Err: storageError(err)}
+	}
+	return OperationResult{Boolean: b}
+}
+
+// Bulk applies operations in order while holding the mutex. When atomic is true, either all
+// of them are applied or none, and failure is returned as OperationError. Otherwise error
+// of each failed operation is in its result.
+func (r *MemoryRepo) Bulk(operations []Operation, atomic bool) ([]OperationResult, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Failed operation changes nothing, so only atomic mode has to restore earlier operations.
+	booleans, deleted, keys, recorded := r.booleans, r.deleted, r.keys, len(r.history)
+	if atomic {
+		r.booleans = make(map[uuid.UUID]Boolean, len(booleans))
+		for id, b := range booleans {
+			r.booleans[id] = b
+		}
+		r.deleted = make(map[uuid.UUID]Boolean, len(deleted))
+		for id, b := range deleted {
+			r.deleted[id] = b
+		}
+		r.keys = make(map[namespacedKey]uuid.UUID, len(keys))
+		for key, id := range keys {
+			r.keys[key] = id
+		}
+	}
+
+	results := make([]OperationResult, len(operations))
+	for i, operation := range operations {
+		var b Boolean
+		var err error
+		switch operation.Type {
+		case OperationCreate:
+			b, err = r.create(operation.Boolean)
+		case OperationUpdate:
+			b, err = r.patch(operation.ID, operation.Patch, operation.Version)
+		case OperationDelete:
+			b, err = Boolean{ID: operation.ID}, r.delete(operation.ID, operation.Version)
+		default:
+			err = unknownOperation(operation)
+		}
+
+		if err != nil && atomic {
+			r.booleans, r.deleted, r.keys, r.history = booleans, deleted, keys, r.history[:recorded]
+			return nil, &OperationError{Index: i, Err: err}
+		}
+		if err != nil {
+			results[i] = OperationResult{Err: err}
+			continue
+		}
+		results[i] = OperationResult{Boolean: b}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// Restore brings back deleted boolean with id as a new version, and reserves its key again.
+// Expiry which already passed is cleared along with its fallback, as it would delete the boolean again.
+func (r *RepoImplement) Restore(id uuid.UUID) (Boolean, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockedQuery(tx.Unscoped()).First(&boolean, "id = ? AND namespace = ?", id, r.namespaceName()).Error; err != nil {
+			return err
+		}
+		if !boolean.DeletedAt.Valid {
+			return ErrNotDeleted
+		}
+		if err := reserveQuota(tx, boolean.Namespace); err != nil {
+			return err
+		}
+
+		boolean.Version++
+		boolean.UpdatedAt = now()
+		boolean.DeletedAt = gorm.DeletedAt{}
+		changes := map[string]interface{}{
+			"deleted_at": nil,
+			"version":    boolean.Version,
+			"updated_at": boolean.UpdatedAt,
+		}
+		if boolean.expired(boolean.UpdatedAt) {
+			boolean.ExpiresAt = sql.NullTime{}
+			boolean.Fallback = sql.NullBool{}
+			changes["expires_at"] = nil
+			changes["fallback"] = nil
+		}
+		err := tx.Unscoped().Model(&Boolean{}).Where("id = ?", id).Updates(changes).Error
+		if err != nil {
+			return err
+		}
+		if err := reserveKey(tx, boolean.Namespace, id, boolean.Key); err != nil {
+			return err
+		}
+		return recordChange(tx, r.audit, HistoryRestore, nil, &boolean)
+	})
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	return boolean, nil
+}
+
+// Purge removes booleans of the namespace deleted before given time from database for good, and returns
+// how many were removed. Their history is kept, and their access lists are removed along with them.
+func (r *RepoImplement) Purge(before time.Time) (int64, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return 0, connectionError(err)
+	}
+
+	var purged int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		ids := tx.Unscoped().Model(&Boolean{}).Select("id").Where("namespace = ? AND deleted_at < ?", r.namespaceName(), before.UTC())
+		if err := tx.Delete(&AccessEntry{}, "boolean_id IN (?)", ids).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("namespace = ? AND deleted_at < ?", r.namespaceName(), before.UTC()).Delete(&Boolean{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	return purged, nil
+}
+
+// Restore brings back deleted boolean with id as a new version, and reserves its key again.
+// Expiry which already passed is cleared along with its fallback, as it would delete the boolean again.
+func (r *MemoryRepo) Restore(id uuid.UUID) (Boolean, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, ok := r.deleted[id]
+	if !ok || b.Namespace != r.namespaceName() {
+		if _, ok := r.stored(id); ok {
+			return Boolean{}, ErrNotDeleted
+		}
+		return Boolean{}, ErrNotFound
+	}
+	if err := r.reserveQuota(); err != nil {
+		return Boolean{}, err
+	}
+	if err := r.moveKey(id, "", b.Key); err != nil {
+		return Boolean{}, err
+	}
+
+	b.Version++
+	b.UpdatedAt = now()
+	b.DeletedAt = gorm.DeletedAt{}
+	if b.expired(b.UpdatedAt) {
+		b.ExpiresAt = sql.NullTime{}
+		b.Fallback = sql.NullBool{}
+	}
+	delete(r.deleted, id)
+	r.booleans[id] = b
+	r.record(HistoryRestore, nil, &b)
+
+	return b, nil
+}
+
+// Purge forgets booleans of the namespace deleted before given time, and returns how many were forgotten.
+// Their history is kept, and their access lists are forgotten along with them.
+func (r *MemoryRepo) Purge(before time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var purged int64
+	for id, b := range r.deleted {
+		if b.Namespace == r.namespaceName() && b.DeletedAt.Time.Before(before) {
+			delete(r.deleted, id)
+			delete(r.accessLists, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,280 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// MaxEnvironments is maximum number of environments a service can have.
+const MaxEnvironments = 20
+
+// MaxEnvironmentLength is maximum number of characters in name of an environment.
+const MaxEnvironmentLength = 32
+
+// environmentPattern matches names of environments, same as names of namespaces.
+var environmentPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// DefaultEnvironments are environments of a service which is not given any, in order values are promoted through them.
+var DefaultEnvironments = []string{"dev", "staging", "prod"}
+
+var environments = DefaultEnvironments
+
+// Environments returns names of environments, in order values are promoted through them.
+func Environments() []string {
+	return environments
+}
+
+// SetEnvironments sets names of environments, in order values are promoted through them.
+// It returns ErrValidation when any name is not valid or is given twice.
+func SetEnvironments(names []string) error {
+	if len(names) == 0 || len(names) > MaxEnvironments {
+		return fmt.Errorf("%w: there must be from 1 to %d environments", ErrValidation, MaxEnvironments)
+	}
+	for i, name := range names {
+		if len(name) > MaxEnvironmentLength || !environmentPattern.MatchString(name) {
+			return fmt.Errorf("%w: environment %q must be at most %d lowercase letters, digits and dashes, and must not start or end with a dash", ErrValidation, name, MaxEnvironmentLength)
+		}
+		for _, previous := range names[:i] {
+			if previous == name {
+				return fmt.Errorf("%w: environment %q is given twice", ErrValidation, name)
+			}
+		}
+	}
+	environments = append([]string(nil), names...)
+	return nil
+}
+
+// ValidateEnvironment returns ErrValidation when name is not one of Environments.
+func ValidateEnvironment(name string) error {
+	for _, environment := range environments {
+		if environment == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: environment %q must be one of %s", ErrValidation, name, strings.Join(environments, ", "))
+}
+
+// nextEnvironment returns environment which values of environment are promoted to.
+func nextEnvironment(environment string) (string, error) {
+	for i, name := range environments[:len(environments)-1] {
+		if name == environment {
+			return environments[i+1], nil
+		}
+	}
+	return "", fmt.Errorf("%w: environment %q has no next environment to promote to", ErrValidation, environment)
+}
+
+// EnvironmentValues are values a boolean has in environments, which differ from its own value.
+// They are stored in a single column as ",dev=true,prod=false,", in order of names.
+type EnvironmentValues map[string]bool
+
+// GormDataType stores environment values in a text column.
+func (EnvironmentValues) GormDataType() string {
+	return "string"
+}
+
+// Value stores environment values in database.
+func (v EnvironmentValues) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return "", nil
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var stored strings.Builder
+	for _, name := range names {
+		stored.WriteString("," + name + "=" + strconv.FormatBool(v[name]))
+	}
+	return stored.String() + ",", nil
+}
+
+// Scan reads environment values stored in database.
+func (v *EnvironmentValues) Scan(src interface{}) error {
+	var stored string
+	switch src := src.(type) {
+	case nil:
+	case string:
+		stored = src
+	case []byte:
+		stored = string(src)
+	default:
+		return fmt.Errorf("can not read environment values from %T", src)
+	}
+
+	*v = nil
+	if stored = strings.Trim(stored, ","); stored == "" {
+		return nil
+	}
+	*v = EnvironmentValues{}
+	for _, pair := range strings.Split(stored, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("can not read environment value from %q", pair)
+		}
+		value, err := strconv.ParseBool(parts[1])
+		if err != nil {
+			return fmt.Errorf("can not read environment value from %q", pair)
+		}
+		(*v)[parts[0]] = value
+	}
+	return nil
+}
+
+// normalized returns a copy of v, which is nil when there are no values.
+func (v EnvironmentValues) normalized() EnvironmentValues {
+	if len(v) == 0 {
+		return nil
+	}
+	copied := make(EnvironmentValues, len(v))
+	for name, value := range v {
+		copied[name] = value
+	}
+	return copied
+}
+
+// validateEnvironmentValues returns ErrValidation when any of values is of an unknown environment.
+func validateEnvironmentValues(values EnvironmentValues) error {
+	for name := range values {
+		if err := ValidateEnvironment(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnvironmentPatch changes values of a boolean in environments. Environment which is nil gets
+// own value of the boolean again, and environments which are not given stay unchanged.
+type EnvironmentPatch map[string]*bool
+
+// apply returns values with changes of the patch.
+func (p EnvironmentPatch) apply(values EnvironmentValues) EnvironmentValues {
+	values = values.normalized()
+	if values == nil {
+		values = EnvironmentValues{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(values, name)
+		} else {
+			values[name] = *value
+		}
+	}
+	return values.normalized()
+}
+
+// validate returns ErrValidation when any environment of the patch is unknown.
+func (p EnvironmentPatch) validate() error {
+	for name := range p {
+		if err := ValidateEnvironment(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// In returns b as it is in environment, with value of that environment when it has one.
+func (b Boolean) In(environment string) Boolean {
+	if value, ok := b.Environments[environment]; ok {
+		b.Value = value
+	}
+	return b
+}
+
+// prepareEnvironments validates promotion from environment, and returns environment it promotes to,
+// which is the next one when to is empty.
+func prepareEnvironments(from string, to string) (string, error) {
+	if err := ValidateEnvironment(from); err != nil {
+		return "", err
+	}
+	if to == "" {
+		return nextEnvironment(from)
+	}
+	if err := ValidateEnvironment(to); err != nil {
+		return "", err
+	}
+	if to == from {
+		return "", fmt.Errorf("%w: environment can not be promoted to itself", ErrValidation)
+	}
+	return to, nil
+}
+
+// promoted returns b with value it has in environment from given to environment to,
+// and false when environment to already has that value of its own.
+func (b Boolean) promoted(from string, to string) (Boolean, bool) {
+	value := b.In(from).Value
+	if own, ok := b.Environments[to]; ok && own == value {
+		return b, false
+	}
+	b.Environments = EnvironmentPatch{to: &value}.apply(b.Environments)
+	return b, true
+}
+
+// Promote gives environment to the value which boolean with id has in environment from, as a single change
+// recorded in history, when boolean still has given version. To is the environment after from when it is empty.
+// Boolean is returned unchanged when environment to already has that value of its own.
+func (r *RepoImplement) Promote(id uuid.UUID, from string, to string, version int64) (Boolean, error) {
+	to, err := prepareEnvironments(from, to)
+	if err != nil {
+		return Boolean{}, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockBoolean(tx, r.namespaceName(), id, version)
+		if err != nil {
+			return err
+		}
+		promoted, changed := old.promoted(from, to)
+		if !changed {
+			boolean = old
+			return nil
+		}
+		boolean, err = changeBoolean(tx, r.audit, HistoryPromote, old, promoted)
+		return err
+	})
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	return boolean, nil
+}
+
+// Promote gives environment to the value which boolean with id has in environment from, as a single change
+// recorded in history, when boolean still has given version. To is the environment after from when it is empty.
+// Boolean is returned unchanged when environment to already has that value of its own.
+func (r *MemoryRepo) Promote(id uuid.UUID, from string, to string, version int64) (Boolean, error) {
+	to, err := prepareEnvironments(from, to)
+	if err != nil {
+		return Boolean{}, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old, err := r.lookup(id, version)
+	if err != nil {
+		return Boolean{}, err
+	}
+	promoted, changed := old.promoted(from, to)
+	if !changed {
+		return old, nil
+	}
+	return r.change(HistoryPromote, old, promoted), nil
+}
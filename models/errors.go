@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// Errors returned by every Repo implementation. They may be wrapped with more details,
+// so compare them using errors.Is.
+var (
+	// ErrNotFound means boolean with requested id does not exist.
+	ErrNotFound = errors.New("Record not found")
+	// ErrNamespaceNotFound means namespace of the repo does not exist. It is also an ErrNotFound.
+	ErrNamespaceNotFound = fmt.Errorf("%w: namespace does not exist", ErrNotFound)
+	// ErrConflict means change can not be applied over the current state of storage.
+	ErrConflict = errors.New("Record conflicts with existing data")
+	// ErrValidation means given boolean can not be stored as it is.
+	ErrValidation = errors.New("Record is not valid")
+	// ErrUnavailable means storage can not be reached at the moment.
+	ErrUnavailable = errors.New("Storage is unavailable")
+	// ErrVersionMismatch means boolean was changed since the expected version. It is also an ErrConflict.
+	ErrVersionMismatch = fmt.Errorf("%w: version does not match", ErrConflict)
+	// ErrValueMismatch means boolean does not have the expected value. It is also an ErrConflict.
+	ErrValueMismatch = fmt.Errorf("%w: value does not match", ErrConflict)
+	// ErrDuplicateKey means key is already used by another boolean. It is also an ErrConflict.
+	ErrDuplicateKey = fmt.Errorf("%w: key is already used by another boolean", ErrConflict)
+	// ErrNotDeleted means boolean can not be restored, as it is not deleted. It is also an ErrConflict.
+	ErrNotDeleted = fmt.Errorf("%w: boolean is not deleted", ErrConflict)
+	// ErrQuotaExceeded means namespace already has as many booleans as its quota allows. It is also an ErrConflict.
+	ErrQuotaExceeded = fmt.Errorf("%w: namespace has no room for another boolean", ErrConflict)
+	// ErrScheduleNotPending means schedule can not be canceled, as it was already applied or canceled.
+	// It is also an ErrConflict.
+	ErrScheduleNotPending = fmt.Errorf("%w: schedule is not pending", ErrConflict)
+	// ErrAPIKeyNotFound means API key with requested id does not exist, or given key does not authenticate.
+	// It is also an ErrNotFound.
+	ErrAPIKeyNotFound = fmt.Errorf("%w: API key does not exist", ErrNotFound)
+	// ErrAPIKeyRevoked means API key can not be rotated, as it was revoked. It is also an ErrConflict.
+	ErrAPIKeyRevoked = fmt.Errorf("%w: API key is revoked", ErrConflict)
+	// ErrRoleBindingNotFound means subject has no role in the namespace. It is also an ErrNotFound.
+	ErrRoleBindingNotFound = fmt.Errorf("%w: role binding does not exist", ErrNotFound)
+	// ErrVersionNotFound means history of boolean does not have requested version. It is also an ErrNotFound.
+	ErrVersionNotFound = fmt.Errorf("%w: version is not in history", ErrNotFound)
+)
+
+// storageError translates error from database into one of Repo errors.
+// Errors which do not match any of them are returned unchanged.
+func storageError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case database.IsDuplicateKey(err):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	case database.IsUnavailable(err):
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+// connectionError wraps error of database.GetConnection, which always means storage is unavailable.
+func connectionError(err error) error {
+	return fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
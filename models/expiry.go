@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// ExpiryActor is actor recorded in history for changes made by expiry of a boolean.
+const ExpiryActor = "expiry"
+
+// expiryAudit records changes made by expiry, which no request makes.
+var expiryAudit = Audit{Actor: ExpiryActor}
+
+// validateExpiry returns ErrValidation when boolean can not be given expiry time t.
+func validateExpiry(t sql.NullTime) error {
+	if t.Valid && !t.Time.After(time.Now()) {
+		return fmt.Errorf("%w: expiry must be in the future", ErrValidation)
+	}
+	return nil
+}
+
+// storedExpiry returns t in UTC and with precision every database keeps, so it compares
+// same as the time it is stored with.
+func storedExpiry(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = t.Time.UTC().Truncate(time.Microsecond)
+	}
+	return t
+}
+
+// prepareBoolean validates b before it is stored, and returns it with expiry, tags and environment values in stored form.
+func prepareBoolean(b Boolean) (Boolean, error) {
+	if err := validateKey(b.Key); err != nil {
+		return Boolean{}, err
+	}
+	if err := validateMetadata(b); err != nil {
+		return Boolean{}, err
+	}
+	if err := validateEnvironmentValues(b.Environments); err != nil {
+		return Boolean{}, err
+	}
+	if err := validateExpiry(b.ExpiresAt); err != nil {
+		return Boolean{}, err
+	}
+	b.ExpiresAt = storedExpiry(b.ExpiresAt)
+	b.Tags = b.Tags.normalized()
+	b.Environments = b.Environments.normalized()
+	return b, nil
+}
+
+// expired reports whether expiry of b has passed at given time.
+func (b Boolean) expired(at time.Time) bool {
+	return b.ExpiresAt.Valid && !b.ExpiresAt.Time.After(at)
+}
+
+// atTime returns b as it is at given time. Once its expiry passed, b has its fallback value
+// as a new version and no expiry, or does not exist when it has no fallback.
+func (b Boolean) atTime(at time.Time) (Boolean, bool) {
+	if !b.expired(at) {
+		return b, true
+	}
+	if !b.Fallback.Valid {
+		return Boolean{}, false
+	}
+
+	b.Value = b.Fallback.Bool
+	b.Version++
+	b.UpdatedAt = b.ExpiresAt.Time
+	b.ExpiresAt = sql.NullTime{}
+	b.Fallback = sql.NullBool{}
+	return b, true
+}
+
+// expireBoolean stores expiry of boolean locked in tx when it passed by given time. It returns the boolean
+// as it is then, and false when it had no fallback, so expiry deleted it.
+func expireBoolean(tx *gorm.DB, b Boolean, at time.Time) (Boolean, bool, error) {
+	if !b.expired(at) {
+		return b, true, nil
+	}
+
+	reverted, ok := b.atTime(at)
+	if ok {
+		reverted, err := changeBoolean(tx, expiryAudit, HistoryExpire, b, reverted)
+		return reverted, err == nil, err
+	}
+	return Boolean{}, false, removeBoolean(tx, expiryAudit, HistoryExpire, b)
+}
+
+// notExpired limits query to booleans which exist at given time, with the expired ones which have a fallback.
+func notExpired(tx *gorm.DB, at time.Time) *gorm.DB {
+	return tx.Where("expires_at IS NULL OR expires_at > ? OR fallback IS NOT NULL", at)
+}
+
+// withValue limits query to booleans which have value at given time, taking fallback of the expired ones.
+func withValue(tx *gorm.DB, value bool, at time.Time) *gorm.DB {
+	return tx.Where("(value = ? AND (expires_at IS NULL OR expires_at > ?)) OR (expires_at <= ? AND fallback = ?)", value, at, at, value)
+}
+
+// Expire stores expiry of every boolean of the namespace whose expiry passed by given time, each in its own
+// transaction, and returns how many booleans expired.
+func (r *RepoImplement) Expire(at time.Time) (int64, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return 0, connectionError(err)
+	}
+
+	var ids []uuid.UUID
+	err = db.Model(&Boolean{}).Where("namespace = ? AND expires_at <= ?", r.namespaceName(), at.UTC()).Pluck("id", &ids).Error
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	var expired int64
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Boolean may have been changed or deleted since it was found.
+			var booleans []Boolean
+			if err := lockedQuery(tx).Limit(1).Find(&booleans, "id = ?", id).Error; err != nil || len(booleans) == 0 {
+				return err
+			}
+			if booleans[0].expired(at) {
+				expired++
+			}
+			_, _, err := expireBoolean(tx, booleans[0], at)
+			return err
+		})
+		if err != nil {
+			return expired, storageError(err)
+		}
+	}
+
+	return expired, nil
+}
+
+// Expire stores expiry of every boolean of the namespace whose expiry passed by given time, and returns
+// how many booleans expired.
+func (r *MemoryRepo) Expire(at time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var expired int64
+	for _, b := range r.booleans {
+		if b.Namespace == r.namespaceName() && b.expired(at) {
+			r.expire(b)
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// expire stores expiry of b, which reverts it to its fallback, or deletes it when it has none.
+// It returns b as it is after expiry. Caller must hold the mutex.
+func (r *MemoryRepo) expire(b Boolean) (Boolean, bool) {
+	expiry := r.withAudit(expiryAudit)
+
+	reverted, ok := b.atTime(b.ExpiresAt.Time)
+	if ok {
+		return expiry.change(HistoryExpire, b, reverted), true
+	}
+
+	r.moveKey(b.ID, b.Key, "")
+	delete(r.booleans, b.ID)
+	expiry.record(HistoryExpire, &b, nil)
+	b.DeletedAt = gorm.DeletedAt{Time: now(), Valid: true}
+	r.deleted[b.ID] = b
+	return Boolean{}, false
+}
@@ -0,0 +1,400 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits on number of entries returned by History in a single page.
+const (
+	DefaultHistoryLimit = 20
+	MaxHistoryLimit     = 100
+)
+
+// HistoryAction is kind of change recorded in history of a boolean.
+type HistoryAction string
+
+// Actions recorded in history.
+const (
+	HistoryCreate   HistoryAction = "create"
+	HistoryUpdate   HistoryAction = "update"
+	HistoryDelete   HistoryAction = "delete"
+	HistoryRollback HistoryAction = "rollback"
+	HistoryRestore  HistoryAction = "restore"
+	HistoryExpire   HistoryAction = "expire"
+	HistoryPromote  HistoryAction = "promote"
+)
+
+// Audit tells who makes changes, so they can be recorded in history.
+// Actor is who the client names, and Principal is who authenticated the change.
+type Audit struct {
+	Actor     string
+	Principal string
+	RequestID string
+}
+
+// Auditable is implemented by repos which record every change in history. WithAudit returns
+// a repo over the same storage, which records changes made through it with given audit.
+type Auditable interface {
+	WithAudit(audit Audit) Repo
+}
+
+// HistoryEntry is an immutable record of a single change of a boolean, written along with the change.
+// It is in namespace of the boolean, so it is kept apart from other namespaces after the boolean is purged.
+// Old fields are nil for create and restore, and new fields are nil for delete. Version is version of boolean
+// after the change, or the deleted version. ID grows with every entry, so it orders history.
+type HistoryEntry struct {
+	ID        uint64        `gorm:"primaryKey;index:idx_boolean_history_boolean_id_id,priority:2"`
+	Namespace string        `gorm:"size:63;not null;default:default"`
+	BooleanID uuid.UUID     `gorm:"not null;index:idx_boolean_history_boolean_id_id,priority:1"`
+	Version   int64         `gorm:"not null"`
+	Action    HistoryAction `gorm:"size:16;not null"`
+	OldValue  *bool
+	NewValue  *bool
+	OldKey    *string `gorm:"size:255"`
+	NewKey    *string `gorm:"size:255"`
+	// OldEnvironments and NewEnvironments are nil along with old and new value, and for entries
+	// recorded before environments existed.
+	OldEnvironments EnvironmentValues `gorm:"size:1000"`
+	NewEnvironments EnvironmentValues `gorm:"size:1000"`
+	Actor           string            `gorm:"size:255"`
+	// Principal is empty for changes made by background jobs, and for entries recorded before
+	// requests were authenticated.
+	Principal string `gorm:"size:255"`
+	RequestID string `gorm:"size:255"`
+	CreatedAt time.Time
+}
+
+// TableName keeps history of all booleans in a single table.
+func (HistoryEntry) TableName() string {
+	return "boolean_history"
+}
+
+// HistoryOptions selects a page of history, which lists newest entries first.
+type HistoryOptions struct {
+	// Before continues history with entries older than entry with this ID, when it is not zero.
+	Before uint64
+	// Limit is maximum number of entries in a page, DefaultHistoryLimit when it is not positive.
+	Limit int
+}
+
+// HistoryPage is a single page of History result. Next is ID to give as Before for the next page,
+// and it is zero on the last page.
+type HistoryPage struct {
+	Entries []HistoryEntry
+	Next    uint64
+}
+
+// limit returns number of entries in a page.
+func (o HistoryOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultHistoryLimit
+	}
+	return o.Limit
+}
+
+// state returns boolean as it was after the change, and false when the change deleted it.
+func (e HistoryEntry) state() (Boolean, bool) {
+	if e.NewValue == nil {
+		return Boolean{}, false
+	}
+	return Boolean{ID: e.BooleanID, Value: *e.NewValue, Key: *e.NewKey, Environments: e.NewEnvironments, Version: e.Version}, true
+}
+
+// previousState returns boolean as it was before the change, and false when the change created it.
+func (e HistoryEntry) previousState() (Boolean, bool) {
+	if e.OldValue == nil {
+		return Boolean{}, false
+	}
+	version := e.Version
+	if e.NewValue != nil {
+		version--
+	}
+	return Boolean{ID: e.BooleanID, Value: *e.OldValue, Key: *e.OldKey, Environments: e.OldEnvironments, Version: version}, true
+}
+
+// stateAt returns boolean as it was at a moment, from the latest entry made until then and the first
+// entry made after it, and from current boolean. Each of them is nil when there is none.
+// Booleans stored before history was kept are known from their first recorded change,
+// or from their current state when they were not changed since.
+func stateAt(at time.Time, latest *HistoryEntry, next *HistoryEntry, current *Boolean) (Boolean, bool) {
+	if latest != nil {
+		return latest.state()
+	}
+	if current != nil && current.CreatedAt.After(at) {
+		return Boolean{}, false
+	}
+	if next != nil {
+		return next.previousState()
+	}
+	if current != nil {
+		return *current, true
+	}
+	return Boolean{}, false
+}
+
+// stateOfVersion returns boolean as it was at given version, from entries which made or replaced it.
+func stateOfVersion(entries []HistoryEntry, version int64) (Boolean, bool) {
+	for _, entry := range entries {
+		if b, ok := entry.state(); ok && b.Version == version {
+			return b, true
+		}
+		if b, ok := entry.previousState(); ok && b.Version == version {
+			return b, true
+		}
+	}
+	return Boolean{}, false
+}
+
+// newHistoryEntry returns entry of a change from old to new boolean, either of which is nil
+// when boolean is created or deleted.
+func newHistoryEntry(audit Audit, action HistoryAction, old *Boolean, new *Boolean) HistoryEntry {
+	entry := HistoryEntry{Action: action, Actor: audit.Actor, Principal: audit.Principal, RequestID: audit.RequestID, CreatedAt: now()}
+	if old != nil {
+		value, key := old.Value, old.Key
+		entry.Namespace, entry.BooleanID, entry.Version = old.Namespace, old.ID, old.Version
+		entry.OldValue, entry.OldKey, entry.OldEnvironments = &value, &key, old.Environments
+	}
+	if new != nil {
+		value, key := new.Value, new.Key
+		entry.Namespace, entry.BooleanID, entry.Version = new.Namespace, new.ID, new.Version
+		entry.NewValue, entry.NewKey, entry.NewEnvironments = &value, &key, new.Environments
+	}
+	return entry
+}
+
+// recordChange writes entry of a change from old to new boolean in tx.
+func recordChange(tx *gorm.DB, audit Audit, action HistoryAction, old *Boolean, new *Boolean) error {
+	entry := newHistoryEntry(audit, action, old, new)
+	return tx.Create(&entry).Error
+}
+
+// WithAudit returns a repo over the same namespace, which records changes made through it with given audit.
+func (r *RepoImplement) WithAudit(audit Audit) Repo {
+	return &RepoImplement{audit: audit, namespace: r.namespace}
+}
+
+// GetAt receives boolean with id as it was at given time, from its history in database.
+func (r *RepoImplement) GetAt(id uuid.UUID, at time.Time) (Boolean, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	at = at.UTC()
+	var latest, next []HistoryEntry
+	err = db.Where("namespace = ? AND boolean_id = ? AND created_at <= ?", r.namespaceName(), id, at).Order("id DESC").Limit(1).Find(&latest).Error
+	if err == nil && len(latest) == 0 {
+		err = db.Where("namespace = ? AND boolean_id = ? AND created_at > ?", r.namespaceName(), id, at).Order("id").Limit(1).Find(&next).Error
+	}
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	var booleans []Boolean
+	if err := db.Limit(1).Find(&booleans, "id = ? AND namespace = ?", id, r.namespaceName()).Error; err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	b, ok := stateAt(at, first(latest), first(next), firstBoolean(booleans))
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+	return b, nil
+}
+
+// Rollback restores value, key and environment values which boolean with id had at version to, when it still has given version.
+// Rollback is a new change, so it gets a new version and is recorded in history.
+func (r *RepoImplement) Rollback(id uuid.UUID, to int64, version int64) (Boolean, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockBoolean(tx, r.namespaceName(), id, version)
+		if err != nil {
+			return err
+		}
+
+		// Version is made by one entry, and replaced by the next one.
+		var entries []HistoryEntry
+		err = tx.Where("boolean_id = ?", id).
+			Where(clause.IN{Column: clause.Column{Name: "version"}, Values: []interface{}{to, to + 1}}).
+			Find(&entries).Error
+		if err != nil {
+			return err
+		}
+
+		current := HistoryEntry{BooleanID: id, Version: old.Version, NewValue: &old.Value, NewKey: &old.Key, NewEnvironments: old.Environments}
+		target, ok := stateOfVersion(append(entries, current), to)
+		if !ok {
+			return ErrVersionNotFound
+		}
+		restored := old
+		restored.Value, restored.Key, restored.Environments = target.Value, target.Key, target.Environments
+		boolean, err = changeBoolean(tx, r.audit, HistoryRollback, old, restored)
+		return err
+	})
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	return boolean, nil
+}
+
+// first returns the first of entries, or nil when there is none.
+func first(entries []HistoryEntry) *HistoryEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+	return &entries[0]
+}
+
+// firstBoolean returns the first of booleans, or nil when there is none.
+func firstBoolean(booleans []Boolean) *Boolean {
+	if len(booleans) == 0 {
+		return nil
+	}
+	return &booleans[0]
+}
+
+// History returns a page of changes of boolean with id, newest first. History is kept after
+// boolean is deleted. ErrNotFound is returned only when boolean has neither history nor exists.
+func (r *RepoImplement) History(id uuid.UUID, options HistoryOptions) (HistoryPage, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return HistoryPage{}, connectionError(err)
+	}
+
+	tx := db.Where("namespace = ? AND boolean_id = ?", r.namespaceName(), id)
+	if options.Before != 0 {
+		tx = tx.Where("id < ?", options.Before)
+	}
+
+	// One more entry than needed tells whether there is a next page.
+	limit := options.limit()
+	var entries []HistoryEntry
+	if err := tx.Order("id DESC").Limit(limit + 1).Find(&entries).Error; err != nil {
+		return HistoryPage{}, storageError(err)
+	}
+
+	// Booleans stored before history was kept exist without any entry.
+	if len(entries) == 0 && options.Before == 0 {
+		if err := db.First(&Boolean{}, "id = ? AND namespace = ?", id, r.namespaceName()).Error; err != nil {
+			return HistoryPage{}, storageError(err)
+		}
+	}
+
+	page := HistoryPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.Next = page.Entries[limit-1].ID
+	}
+	return page, nil
+}
+
+// History returns a page of changes of boolean with id, newest first. History is kept after
+// boolean is deleted. ErrNotFound is returned only when boolean has neither history nor exists.
+func (r *MemoryRepo) History(id uuid.UUID, options HistoryOptions) (HistoryPage, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	entries := []HistoryEntry{}
+	limit := options.limit()
+	for i := len(r.history) - 1; i >= 0 && len(entries) <= limit; i-- {
+		entry := r.history[i]
+		if entry.Namespace == r.namespaceName() && entry.BooleanID == id && (options.Before == 0 || entry.ID < options.Before) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if _, ok := r.stored(id); !ok && len(entries) == 0 && options.Before == 0 {
+		return HistoryPage{}, ErrNotFound
+	}
+
+	page := HistoryPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.Next = page.Entries[limit-1].ID
+	}
+	return page, nil
+}
+
+// GetAt receives boolean with id as it was at given time, from its history in memory.
+func (r *MemoryRepo) GetAt(id uuid.UUID, at time.Time) (Boolean, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var latest, next *HistoryEntry
+	for i := range r.history {
+		entry := &r.history[i]
+		if entry.Namespace != r.namespaceName() || entry.BooleanID != id {
+			continue
+		}
+		if entry.CreatedAt.After(at) {
+			next = entry
+			break
+		}
+		latest = entry
+	}
+
+	var current *Boolean
+	if b, ok := r.stored(id); ok {
+		current = &b
+	}
+
+	b, ok := stateAt(at, latest, next, current)
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+	return b, nil
+}
+
+// Rollback restores value, key and environment values which boolean with id had at version to, when it still has given version.
+// Rollback is a new change, so it gets a new version and is recorded in history.
+func (r *MemoryRepo) Rollback(id uuid.UUID, to int64, version int64) (Boolean, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old, err := r.lookup(id, version)
+	if err != nil {
+		return Boolean{}, err
+	}
+
+	entries := []HistoryEntry{{BooleanID: id, Version: old.Version, NewValue: &old.Value, NewKey: &old.Key, NewEnvironments: old.Environments}}
+	for _, entry := range r.history {
+		if entry.BooleanID == id && (entry.Version == to || entry.Version == to+1) {
+			entries = append(entries, entry)
+		}
+	}
+	target, ok := stateOfVersion(entries, to)
+	if !ok {
+		return Boolean{}, ErrVersionNotFound
+	}
+
+	if err := r.moveKey(id, old.Key, target.Key); err != nil {
+		return Boolean{}, err
+	}
+	b := old
+	b.Value, b.Key, b.Environments = target.Value, target.Key, target.Environments
+
+	return r.change(HistoryRollback, old, b), nil
+}
+
+// WithAudit returns a repo over the same memory, which records changes made through it with given audit.
+func (r *MemoryRepo) WithAudit(audit Audit) Repo {
+	return r.withAudit(audit)
+}
+
+// record appends entry of a change from old to new boolean. Caller must hold the mutex.
+func (r *MemoryRepo) record(action HistoryAction, old *Boolean, new *Boolean) {
+	entry := newHistoryEntry(r.audit, action, old, new)
+	entry.ID = uint64(len(r.history)) + 1
+	r.history = append(r.history, entry)
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BooleanKey reserves a key in a namespace for a single boolean, so a key always finds at most one boolean
+// of the namespace. Booleans without key do not reserve any.
+type BooleanKey struct {
+	Namespace string    `gorm:"primaryKey;size:63"`
+	Key       string    `gorm:"primaryKey;size:255"`
+	BooleanID uuid.UUID `gorm:"not null;uniqueIndex"`
+}
+
+// validateLookupKey returns ErrValidation when key can not find a boolean.
+func validateLookupKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key must not be empty", ErrValidation)
+	}
+	return validateKey(key)
+}
+
+// reserveKey reserves key in namespace for boolean with id, and returns ErrDuplicateKey when another boolean
+// of the namespace has it.
+func reserveKey(tx *gorm.DB, namespace string, id uuid.UUID, key string) error {
+	if key == "" {
+		return nil
+	}
+	if err := expireKeyOwner(tx, namespace, key); err != nil {
+		return err
+	}
+	err := tx.Create(&BooleanKey{Namespace: namespace, Key: key, BooleanID: id}).Error
+	if database.IsDuplicateKey(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+// expireKeyOwner stores expiry of boolean of namespace which has key when it passed, so key of a boolean
+// deleted by its expiry is free again.
+func expireKeyOwner(tx *gorm.DB, namespace string, key string) error {
+	var owners []Boolean
+	err := lockedQuery(tx).Joins("JOIN boolean_keys ON boolean_keys.boolean_id = booleans.id").
+		Where(keyIn(namespace, key)).
+		Where("booleans.expires_at <= ?", now()).
+		Limit(1).Find(&owners).Error
+	if err != nil || len(owners) == 0 {
+		return err
+	}
+	_, _, err = expireBoolean(tx, owners[0], now())
+	return err
+}
+
+// releaseKey releases the key reserved for boolean with id, if there is one.
+func releaseKey(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Where("boolean_id = ?", id).Delete(&BooleanKey{}).Error
+}
+
+// reserveExistingKeys reserves keys of booleans stored before keys were unique.
+// When several booleans share a key, only one of them gets it, the oldest one when creation times differ.
+func reserveExistingKeys(db *gorm.DB) error {
+	var booleans []Boolean
+	if err := db.Where(clause.Neq{Column: clause.Column{Name: "key"}, Value: ""}).Order("created_at").Find(&booleans).Error; err != nil {
+		return err
+	}
+	for _, b := range booleans {
+		if err := reserveKey(db, b.Namespace, b.ID, b.Key); err != nil && !errors.Is(err, ErrDuplicateKey) {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyIn matches reservation of key in namespace, in a query which joins boolean_keys.
+func keyIn(namespace string, key string) clause.Expression {
+	return clause.And(
+		clause.Eq{Column: clause.Column{Table: "boolean_keys", Name: "namespace"}, Value: namespace},
+		clause.Eq{Column: clause.Column{Table: "boolean_keys", Name: "key"}, Value: key},
+	)
+}
+
+// GetByKey receives a boolean object from database using its key.
+func (r *RepoImplement) GetByKey(key string) (Boolean, error) {
+	if err := validateLookupKey(key); err != nil {
+		return Boolean{}, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, connectionError(err)
+	}
+
+	var boolean Boolean
+	err = db.Joins("JOIN boolean_keys ON boolean_keys.boolean_id = booleans.id").
+		Where(keyIn(r.namespaceName(), key)).
+		First(&boolean).Error
+	if err != nil {
+		return Boolean{}, storageError(err)
+	}
+
+	boolean, ok := boolean.atTime(now())
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+	return boolean, nil
+}
+
+// UpsertByKey replaces value of the boolean with key when it still has given version,
+// or creates a new boolean with the key when there is none. Created is true for a new boolean.
+func (r *RepoImplement) UpsertByKey(key string, b Boolean, version int64) (boolean Boolean, created bool, err error) {
+	if err := validateLookupKey(key); err != nil {
+		return Boolean{}, false, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return Boolean{}, false, connectionError(err)
+	}
+
+	upsert := func(tx *gorm.DB) error {
+		created = false
+		var reserved BooleanKey
+		err := tx.Where(&BooleanKey{Namespace: r.namespaceName(), Key: key}).Limit(1).Find(&reserved).Error
+		if err != nil {
+			return err
+		}
+
+		if reserved.Key == "" {
+			if version != AnyVersion {
+				return ErrNotFound
+			}
+			boolean, err = createBoolean(tx, r.audit, r.namespaceName(), Boolean{Value: b.Value, Key: key})
+			created = err == nil
+			return err
+		}
+
+		old, err := lockBoolean(tx, r.namespaceName(), reserved.BooleanID, version)
+		if errors.Is(err, gorm.ErrRecordNotFound) && version == AnyVersion {
+			// Boolean was deleted by its expiry, which released the key.
+			boolean, err = createBoolean(tx, r.audit, r.namespaceName(), Boolean{Value: b.Value, Key: key})
+			created = err == nil
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		updated := old
+		updated.Value = b.Value
+		boolean, err = changeBoolean(tx, r.audit, HistoryUpdate, old, updated)
+		return err
+	}
+
+	// When two upserts create the same key, the one which loses updates boolean created by the other.
+	err = db.Transaction(upsert)
+	if errors.Is(err, ErrDuplicateKey) {
+		err = db.Transaction(upsert)
+	}
+	if err != nil {
+		return Boolean{}, false, storageError(err)
+	}
+
+	return boolean, created, nil
+}
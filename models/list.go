@@ -0,0 +1,146 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits on number of booleans returned by List in a single page.
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// SortOrder is a field by which List orders booleans. Booleans with equal field are ordered by id.
+type SortOrder string
+
+// Sort orders supported by List.
+const (
+	SortByCreated SortOrder = "created_at"
+	SortByKey     SortOrder = "key"
+)
+
+// ListOptions selects booleans returned by List and their order.
+// Zero value lists all booleans by creation time, oldest first.
+type ListOptions struct {
+	// Value lists only booleans with this value, when it is not nil.
+	Value *bool
+	// Key lists only booleans with exactly this key, when it is not nil.
+	Key *string
+	// KeyPrefix lists only booleans with key starting with it, when it is not empty.
+	KeyPrefix string
+	// Owner lists only booleans with exactly this owner, when it is not nil.
+	Owner *string
+	// Tags lists only booleans which have every one of these tags.
+	Tags []string
+
+	Sort       SortOrder
+	Descending bool
+
+	// After continues listing after the position of a previous page, when it is not nil.
+	// It must come from a listing with the same sort order.
+	After *Cursor
+	// Limit is maximum number of booleans in a page, DefaultListLimit when it is not positive.
+	Limit int
+}
+
+// Cursor is position of a boolean in a listing, which the next page continues after.
+type Cursor struct {
+	CreatedAt time.Time
+	Key       string
+	ID        uuid.UUID
+}
+
+// Page is a single page of List result. Next is nil on the last page.
+type Page struct {
+	Booleans []Boolean
+	Next     *Cursor
+}
+
+// cursorOf returns position of b in a listing.
+func cursorOf(b Boolean) *Cursor {
+	return &Cursor{CreatedAt: b.CreatedAt, Key: b.Key, ID: b.ID}
+}
+
+// limit returns number of booleans in a page.
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultListLimit
+	}
+	return o.Limit
+}
+
+// matches reports whether b passes the filters of o.
+func (o ListOptions) matches(b Boolean) bool {
+	if o.Value != nil && b.Value != *o.Value {
+		return false
+	}
+	if o.Key != nil && b.Key != *o.Key {
+		return false
+	}
+	if o.Owner != nil && b.Owner != *o.Owner {
+		return false
+	}
+	for _, tag := range o.Tags {
+		if !b.Tags.Has(tag) {
+			return false
+		}
+	}
+	return strings.HasPrefix(b.Key, o.KeyPrefix)
+}
+
+// compare orders position of b against cursor c in the sort order of o,
+// and returns a negative number when b comes first.
+func (o ListOptions) compare(b Boolean, c Cursor) int {
+	result := 0
+	switch o.Sort {
+	case SortByKey:
+		result = strings.Compare(b.Key, c.Key)
+	default:
+		if b.CreatedAt.Before(c.CreatedAt) {
+			result = -1
+		} else if b.CreatedAt.After(c.CreatedAt) {
+			result = 1
+		}
+	}
+	if result == 0 {
+		result = bytes.Compare(b.ID[:], c.ID[:])
+	}
+
+	if o.Descending {
+		return -result
+	}
+	return result
+}
+
+// escapeLike escapes wildcards of LIKE pattern with "!", which is given as ESCAPE character of the query.
+func escapeLike(pattern string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(pattern)
+}
+
+// escapeGlob escapes wildcards of GLOB pattern by putting each of them in brackets.
+func escapeGlob(pattern string) string {
+	return strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]").Replace(pattern)
+}
+
+// matchText returns condition which holds when column is parts with any text between them, so "" around
+// a part matches any text before or after it. It is case sensitive on every engine, and SQLite, where LIKE
+// ignores case, matches with GLOB.
+func matchText(tx *gorm.DB, column clause.Column, parts ...string) clause.Expr {
+	if tx.Dialector.Name() == database.DriverSQLite {
+		for i, part := range parts {
+			parts[i] = escapeGlob(part)
+		}
+		return clause.Expr{SQL: "? GLOB ?", Vars: []interface{}{column, strings.Join(parts, "*")}}
+	}
+	for i, part := range parts {
+		parts[i] = escapeLike(part)
+	}
+	return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, strings.Join(parts, "%")}}
+}
@@ -0,0 +1,408 @@
+package models
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// MemoryDriver is value of DB_DRIVER environment variable which selects MemoryRepo.
+const MemoryDriver = database.DriverMemory
+
+// MemoryRepo is an implementation of Repo interface which keeps booleans in memory.
+// It is safe for concurrent use, and everything stored is lost when process exits.
+// Changes are recorded in history with its audit, which is set by WithAudit.
+// It reads and changes only booleans of its namespace, which is set by InNamespace.
+type MemoryRepo struct {
+	*memoryStore
+	audit     Audit
+	namespace string
+}
+
+// memoryStore is memory shared by MemoryRepo and repos returned by its WithAudit and InNamespace.
+// Deleted booleans are moved from booleans to deleted until they are purged.
+type memoryStore struct {
+	mutex      sync.RWMutex
+	namespaces map[string]Namespace
+	booleans   map[uuid.UUID]Boolean
+	deleted    map[uuid.UUID]Boolean
+	keys       map[namespacedKey]uuid.UUID
+	history    []HistoryEntry
+	schedules  map[uuid.UUID]Schedule
+	apiKeys    map[uuid.UUID]APIKey
+	// roleBindings and accessLists are kept apart from booleans, and access lists are removed along with
+	// purged booleans.
+	roleBindings map[namespacedSubject]RoleBinding
+	accessLists  map[uuid.UUID][]string
+}
+
+// namespacedKey is a key of a boolean within its namespace.
+type namespacedKey struct {
+	namespace string
+	key       string
+}
+
+// NewMemoryRepo creates an empty MemoryRepo, which has only DefaultNamespace without quota.
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{memoryStore: &memoryStore{
+		namespaces: map[string]Namespace{DefaultNamespace: {Name: DefaultNamespace, CreatedAt: now()}},
+		booleans:   map[uuid.UUID]Boolean{},
+		deleted:    map[uuid.UUID]Boolean{},
+		keys:       map[namespacedKey]uuid.UUID{},
+		schedules:  map[uuid.UUID]Schedule{},
+		apiKeys:    map[uuid.UUID]APIKey{},
+
+		roleBindings: map[namespacedSubject]RoleBinding{},
+		accessLists:  map[uuid.UUID][]string{},
+	}}
+}
+
+// Get receives a boolean object from memory using id.
+func (r *MemoryRepo) Get(id uuid.UUID) (Boolean, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	b, ok := r.visible(id)
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+
+	return b, nil
+}
+
+// GetMany receives booleans with any of given ids or keys from memory.
+// Booleans which are not found are left out, and order of the result is not defined.
+func (r *MemoryRepo) GetMany(ids []uuid.UUID, keys []string) ([]Boolean, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	found := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		found[id] = true
+	}
+	for _, key := range keys {
+		if id, ok := r.keys[r.keyOf(key)]; ok {
+			found[id] = true
+		}
+	}
+
+	booleans := make([]Boolean, 0, len(found))
+	for id := range found {
+		if b, ok := r.visible(id); ok {
+			booleans = append(booleans, b)
+		}
+	}
+
+	return booleans, nil
+}
+
+// Create stores a new boolean object, and returns it with newly assigned id.
+func (r *MemoryRepo) Create(b Boolean) (Boolean, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.create(b)
+}
+
+// Update replaces the existing boolean with newBoolean, when it still has given version, and returns the updated boolean.
+func (r *MemoryRepo) Update(id uuid.UUID, newBoolean Boolean, version int64) (Boolean, error) {
+	newBoolean, err := prepareBoolean(newBoolean)
+	if err != nil {
+		return Boolean{}, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, err := r.lookup(id, version)
+	if err != nil {
+		return Boolean{}, err
+	}
+	if err := r.moveKey(id, b.Key, newBoolean.Key); err != nil {
+		return Boolean{}, err
+	}
+
+	return r.change(HistoryUpdate, b, newBoolean), nil
+}
+
+// Patch changes only fields given in patch when boolean still has given version, and returns the updated boolean.
+func (r *MemoryRepo) Patch(id uuid.UUID, patch BooleanPatch, version int64) (Boolean, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.patch(id, patch, version)
+}
+
+// Toggle flips value of the boolean, and returns the updated boolean.
+func (r *MemoryRepo) Toggle(id uuid.UUID) (Boolean, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	old, err := r.lookup(id, AnyVersion)
+	if err != nil {
+		return Boolean{}, err
+	}
+	b := old
+	b.Value = !b.Value
+
+	return r.change(HistoryUpdate, old, b), nil
+}
+
+// CompareAndSwap sets value to new, only when current value is expected.
+// On mismatch, it returns the current boolean with ErrValueMismatch.
+func (r *MemoryRepo) CompareAndSwap(id uuid.UUID, expected bool, new bool) (Boolean, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b, err := r.lookup(id, AnyVersion)
+	if err != nil {
+		return Boolean{}, err
+	}
+	if b.Value != expected {
+		return b, ErrValueMismatch
+	}
+	if expected != new {
+		old := b
+		b.Value = new
+		b = r.change(HistoryUpdate, old, b)
+	}
+
+	return b, nil
+}
+
+// List returns a page of booleans matching the filters of options, in their sort order.
+// It goes through all booleans, so it is meant for tests and small data sets.
+func (r *MemoryRepo) List(options ListOptions) (Page, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, ok := r.namespaces[r.namespaceName()]; !ok {
+		return Page{}, ErrNamespaceNotFound
+	}
+
+	booleans := []Boolean{}
+	for id := range r.booleans {
+		b, ok := r.visible(id)
+		if !ok || !options.matches(b) {
+			continue
+		}
+		if options.After != nil && options.compare(b, *options.After) <= 0 {
+			continue
+		}
+		booleans = append(booleans, b)
+	}
+	sort.Slice(booleans, func(i, j int) bool {
+		return options.compare(booleans[i], *cursorOf(booleans[j])) < 0
+	})
+
+	page := Page{Booleans: booleans}
+	if limit := options.limit(); len(booleans) > limit {
+		page.Booleans = booleans[:limit]
+		page.Next = cursorOf(page.Booleans[limit-1])
+	}
+	return page, nil
+}
+
+// Delete marks the boolean as deleted using id, when it still has given version, and releases its key.
+func (r *MemoryRepo) Delete(id uuid.UUID, version int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.delete(id, version)
+}
+
+// create stores b with newly assigned id in namespace of the repo, when its quota allows.
+// Caller must hold the mutex.
+func (r *MemoryRepo) create(b Boolean) (Boolean, error) {
+	b, err := prepareBoolean(b)
+	if err != nil {
+		return Boolean{}, err
+	}
+	if err := r.reserveQuota(); err != nil {
+		return Boolean{}, err
+	}
+
+	b.ID = uuid.New()
+	b.Namespace = r.namespaceName()
+	b.Version = FirstVersion
+	b.CreatedAt = now()
+	b.UpdatedAt = b.CreatedAt
+	if err := r.moveKey(b.ID, "", b.Key); err != nil {
+		return Boolean{}, err
+	}
+	r.booleans[b.ID] = b
+	r.record(HistoryCreate, nil, &b)
+
+	return b, nil
+}
+
+// patch applies patch to boolean with id, when it still has given version. Caller must hold the mutex.
+func (r *MemoryRepo) patch(id uuid.UUID, patch BooleanPatch, version int64) (Boolean, error) {
+	old, err := r.lookup(id, version)
+	if err != nil {
+		return Boolean{}, err
+	}
+	if err := patch.validate(old); err != nil {
+		return Boolean{}, err
+	}
+	if patch == (BooleanPatch{}) {
+		return old, nil
+	}
+	if patch.Key != nil {
+		if err := r.moveKey(id, old.Key, *patch.Key); err != nil {
+			return Boolean{}, err
+		}
+	}
+	return r.change(HistoryUpdate, old, patch.Apply(old)), nil
+}
+
+// delete marks boolean with id as deleted, when it still has given version. Caller must hold the mutex.
+func (r *MemoryRepo) delete(id uuid.UUID, version int64) error {
+	b, err := r.lookup(id, version)
+	if err != nil {
+		return err
+	}
+	r.moveKey(id, b.Key, "")
+	delete(r.booleans, id)
+	r.record(HistoryDelete, &b, nil)
+	b.DeletedAt = gorm.DeletedAt{Time: now(), Valid: true}
+	r.deleted[id] = b
+
+	return nil
+}
+
+// GetByKey receives a boolean object from memory using its key.
+func (r *MemoryRepo) GetByKey(key string) (Boolean, error) {
+	if err := validateLookupKey(key); err != nil {
+		return Boolean{}, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	id, ok := r.keys[r.keyOf(key)]
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+	b, ok := r.visible(id)
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+
+	return b, nil
+}
+
+// UpsertByKey replaces value of the boolean with key when it still has given version,
+// or creates a new boolean with the key when there is none. Created is true for a new boolean.
+func (r *MemoryRepo) UpsertByKey(key string, b Boolean, version int64) (Boolean, bool, error) {
+	if err := validateLookupKey(key); err != nil {
+		return Boolean{}, false, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id, ok := r.keys[r.keyOf(key)]
+	if ok {
+		// Key of a boolean deleted by its expiry is free again.
+		_, ok = r.current(id)
+	}
+	if !ok {
+		if version != AnyVersion {
+			return Boolean{}, false, ErrNotFound
+		}
+		b, err := r.create(Boolean{Value: b.Value, Key: key})
+		return b, err == nil, err
+	}
+
+	old, err := r.lookup(id, version)
+	if err != nil {
+		return Boolean{}, false, err
+	}
+	current := old
+	current.Value = b.Value
+
+	return r.change(HistoryUpdate, old, current), false, nil
+}
+
+// change stores b in place of old as its next version, and records it in history with action.
+// Caller must hold the mutex, and move key of the boolean when it changes.
+func (r *MemoryRepo) change(action HistoryAction, old Boolean, b Boolean) Boolean {
+	b.ID = old.ID
+	b.Namespace = old.Namespace
+	b.Version = old.Version + 1
+	b.CreatedAt = old.CreatedAt
+	b.UpdatedAt = now()
+	r.booleans[b.ID] = b
+	r.record(action, &old, &b)
+	return b
+}
+
+// moveKey moves reservation of boolean with id from one key to another in namespace of the repo, and returns
+// ErrDuplicateKey when another boolean of the namespace has the new key. Empty key means no reservation.
+// Caller must hold the mutex.
+func (r *MemoryRepo) moveKey(id uuid.UUID, from string, to string) error {
+	if owner, ok := r.keys[r.keyOf(to)]; ok && to != "" && owner != id {
+		if _, exists := r.current(owner); exists {
+			return ErrDuplicateKey
+		}
+	}
+	if from != "" && r.keys[r.keyOf(from)] == id {
+		delete(r.keys, r.keyOf(from))
+	}
+	if to != "" {
+		r.keys[r.keyOf(to)] = id
+	}
+	return nil
+}
+
+// keyOf returns key in namespace of the repo.
+func (r *MemoryRepo) keyOf(key string) namespacedKey {
+	return namespacedKey{namespace: r.namespaceName(), key: key}
+}
+
+// lookup returns stored boolean with id, checking its version unless it is AnyVersion.
+// Caller must hold the mutex.
+func (r *MemoryRepo) lookup(id uuid.UUID, version int64) (Boolean, error) {
+	b, ok := r.current(id)
+	if !ok {
+		return Boolean{}, ErrNotFound
+	}
+	if version != AnyVersion && b.Version != version {
+		return Boolean{}, ErrVersionMismatch
+	}
+	return b, nil
+}
+
+// visible returns boolean of the namespace with id as it is now, with expiry which passed but is not stored yet.
+// Caller must hold the mutex at least for reading.
+func (r *MemoryRepo) visible(id uuid.UUID) (Boolean, bool) {
+	b, ok := r.stored(id)
+	if !ok {
+		return Boolean{}, false
+	}
+	return b.atTime(now())
+}
+
+// current returns boolean of the namespace with id as it is now, and stores its expiry first when it passed.
+// Caller must hold the mutex for writing.
+func (r *MemoryRepo) current(id uuid.UUID) (Boolean, bool) {
+	b, ok := r.stored(id)
+	if ok && b.expired(now()) {
+		return r.expire(b)
+	}
+	return b, ok
+}
+
+// stored returns boolean of the namespace with id as it is stored. Booleans of other namespaces
+// are not found. Caller must hold the mutex at least for reading.
+func (r *MemoryRepo) stored(id uuid.UUID) (Boolean, bool) {
+	b, ok := r.booleans[id]
+	if !ok || b.Namespace != r.namespaceName() {
+		return Boolean{}, false
+	}
+	return b, true
+}
@@ -0,0 +1,14 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/hrishi32/boolean-as-service/models"
+	"github.com/hrishi32/boolean-as-service/models/repotest"
+)
+
+func TestMemoryRepo(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) models.Repo {
+		return models.NewMemoryRepo()
+	})
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on descriptive metadata of a boolean.
+const (
+	MaxDescriptionLength = 1000
+	MaxOwnerLength       = 255
+	MaxTags              = 20
+	MaxTagLength         = 64
+)
+
+// Tags are free-form labels of a boolean, kept in order they were given.
+// They are stored in a single column as ",first,second,", so booleans with a tag are found with LIKE.
+type Tags []string
+
+// GormDataType stores tags in a text column.
+func (Tags) GormDataType() string {
+	return "string"
+}
+
+// Value stores tags in database.
+func (t Tags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return "", nil
+	}
+	return "," + strings.Join(t, ",") + ",", nil
+}
+
+// Scan reads tags stored in database.
+func (t *Tags) Scan(src interface{}) error {
+	var stored string
+	switch src := src.(type) {
+	case nil:
+	case string:
+		stored = src
+	case []byte:
+		stored = string(src)
+	default:
+		return fmt.Errorf("can not read tags from %T", src)
+	}
+
+	*t = nil
+	if stored = strings.Trim(stored, ","); stored != "" {
+		*t = strings.Split(stored, ",")
+	}
+	return nil
+}
+
+// Has reports whether tag is one of t.
+func (t Tags) Has(tag string) bool {
+	for _, own := range t {
+		if own == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalized returns t without repeated tags, and nil when there are none.
+func (t Tags) normalized() Tags {
+	var unique Tags
+	for _, tag := range t {
+		if !unique.Has(tag) {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// validateTags returns ErrValidation when tags can not be stored.
+// A tag is made of letters, digits and any of "-_.:/", so it never needs escaping where it is stored.
+func validateTags(tags Tags) error {
+	if len(tags.normalized()) > MaxTags {
+		return fmt.Errorf("%w: boolean can have at most %d tags", ErrValidation, MaxTags)
+	}
+	for _, tag := range tags {
+		if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+			return fmt.Errorf("%w: tag must be from 1 to %d characters long", ErrValidation, MaxTagLength)
+		}
+		for _, r := range tag {
+			if !isTagRune(r) {
+				return fmt.Errorf("%w: tag %q may only have letters, digits and any of \"-_.:/\"", ErrValidation, tag)
+			}
+		}
+	}
+	return nil
+}
+
+// isTagRune reports whether r may be a part of a tag.
+func isTagRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	default:
+		return strings.ContainsRune("-_.:/", r)
+	}
+}
+
+// validateDescription returns ErrValidation when description is too long to be stored.
+func validateDescription(description string) error {
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters long", ErrValidation, MaxDescriptionLength)
+	}
+	return nil
+}
+
+// validateOwner returns ErrValidation when owner is too long to be stored.
+func validateOwner(owner string) error {
+	if utf8.RuneCountInString(owner) > MaxOwnerLength {
+		return fmt.Errorf("%w: owner must be at most %d characters long", ErrValidation, MaxOwnerLength)
+	}
+	return nil
+}
+
+// validateMetadata returns ErrValidation when descriptive metadata of b can not be stored.
+func validateMetadata(b Boolean) error {
+	if err := validateDescription(b.Description); err != nil {
+		return err
+	}
+	if err := validateOwner(b.Owner); err != nil {
+		return err
+	}
+	return validateTags(b.Tags)
+}
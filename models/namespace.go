@@ -0,0 +1,337 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// DefaultNamespace is namespace of booleans stored before namespaces existed, and of repos made without one.
+const DefaultNamespace = "default"
+
+// DefaultMaxBooleans is quota of a namespace created without one.
+const DefaultMaxBooleans = 10000
+
+// MaxNamespaceLength is maximum number of characters in name of a namespace.
+const MaxNamespaceLength = 63
+
+// namespacePattern matches names of namespaces, which are lowercase letters, digits and dashes,
+// and neither start nor end with a dash, so they can be used in paths as they are.
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Namespace is a tenant, which owns booleans apart from every other tenant. Keys are unique only
+// within a namespace. MaxBooleans is quota on number of booleans in the namespace, and zero means no quota.
+// Booleans is number of booleans in the namespace when it was read, and it is not stored.
+type Namespace struct {
+	Name        string `gorm:"primaryKey;size:63"`
+	MaxBooleans int64  `gorm:"not null;default:0"`
+	CreatedAt   time.Time
+	Booleans    int64 `gorm:"-"`
+}
+
+// ValidateNamespace returns ErrValidation when name can not be name of a namespace.
+func ValidateNamespace(name string) error {
+	if len(name) > MaxNamespaceLength || !namespacePattern.MatchString(name) {
+		return fmt.Errorf("%w: namespace must be at most %d lowercase letters, digits and dashes, and must not start or end with a dash", ErrValidation, MaxNamespaceLength)
+	}
+	return nil
+}
+
+// validateQuota returns ErrValidation when namespace has a quota which can not be kept.
+func validateQuota(ns Namespace) error {
+	if ns.MaxBooleans < 0 {
+		return fmt.Errorf("%w: quota must not be negative", ErrValidation)
+	}
+	return nil
+}
+
+// full reports whether namespace with given number of booleans has no room for another one.
+func (ns Namespace) full(booleans int64) bool {
+	return ns.MaxBooleans > 0 && booleans >= ns.MaxBooleans
+}
+
+// namespaceName returns namespace of the repo, which is DefaultNamespace for a repo made without one.
+func (r *RepoImplement) namespaceName() string {
+	if r.namespace == "" {
+		return DefaultNamespace
+	}
+	return r.namespace
+}
+
+// InNamespace returns a repo over the same database, which reads and changes only booleans of given namespace.
+func (r *RepoImplement) InNamespace(namespace string) Repo {
+	return &RepoImplement{audit: r.audit, namespace: namespace}
+}
+
+// createDefaultNamespace stores DefaultNamespace without quota, so booleans stored before namespaces existed
+// are not limited. It is found by name alone, so it is kept as it is when it was stored by an earlier migration.
+func createDefaultNamespace(db *gorm.DB) error {
+	return db.Where(Namespace{Name: DefaultNamespace}).Attrs(Namespace{CreatedAt: now()}).FirstOrCreate(&Namespace{}).Error
+}
+
+// countBooleans counts booleans which exist in namespace at given time.
+func countBooleans(tx *gorm.DB, namespace string, at time.Time) (int64, error) {
+	var count int64
+	err := notExpired(tx.Model(&Boolean{}), at).Where("namespace = ?", namespace).Count(&count).Error
+	return count, err
+}
+
+// reserveQuota locks namespace in tx until it ends, and returns ErrQuotaExceeded when it has no room
+// for another boolean, or ErrNamespaceNotFound when it does not exist. The lock keeps booleans created
+// by concurrent transactions from going over the quota together.
+func reserveQuota(tx *gorm.DB, namespace string) error {
+	var ns Namespace
+	err := lockedQuery(tx).First(&ns, "name = ?", namespace).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNamespaceNotFound
+	}
+	if err != nil || ns.MaxBooleans == 0 {
+		return err
+	}
+
+	count, err := countBooleans(tx, namespace, now())
+	if err != nil {
+		return err
+	}
+	if ns.full(count) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// namespaceExists returns ErrNamespaceNotFound when namespace is not stored.
+func namespaceExists(db *gorm.DB, namespace string) error {
+	var namespaces []Namespace
+	if err := db.Limit(1).Find(&namespaces, "name = ?", namespace).Error; err != nil {
+		return err
+	}
+	if len(namespaces) == 0 {
+		return ErrNamespaceNotFound
+	}
+	return nil
+}
+
+// Namespaces returns every namespace in database with number of its booleans, in order of their names.
+func (*RepoImplement) Namespaces() ([]Namespace, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	namespaces := []Namespace{}
+	if err := db.Order("name").Find(&namespaces).Error; err != nil {
+		return nil, storageError(err)
+	}
+
+	var counts []struct {
+		Namespace string
+		Count     int64
+	}
+	err = notExpired(db.Model(&Boolean{}), now()).Select("namespace, COUNT(*) AS count").Group("namespace").Scan(&counts).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	booleans := map[string]int64{}
+	for _, count := range counts {
+		booleans[count.Namespace] = count.Count
+	}
+	for i := range namespaces {
+		namespaces[i].Booleans = booleans[namespaces[i].Name]
+	}
+	return namespaces, nil
+}
+
+// NamespaceNames returns names of every namespace in database in order, without counting their booleans.
+func (*RepoImplement) NamespaceNames() ([]string, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	names := []string{}
+	if err := db.Model(&Namespace{}).Order("name").Pluck("name", &names).Error; err != nil {
+		return nil, storageError(err)
+	}
+	return names, nil
+}
+
+// GetNamespace receives namespace with name from database, with number of its booleans.
+func (*RepoImplement) GetNamespace(name string) (Namespace, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return Namespace{}, connectionError(err)
+	}
+
+	var ns Namespace
+	if err := db.First(&ns, "name = ?", name).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Namespace{}, ErrNamespaceNotFound
+		}
+		return Namespace{}, storageError(err)
+	}
+	if ns.Booleans, err = countBooleans(db, name, now()); err != nil {
+		return Namespace{}, storageError(err)
+	}
+	return ns, nil
+}
+
+// PutNamespace creates namespace with name of ns, or changes quota of the existing one.
+// Created is true for a new namespace. Quota lower than number of booleans in the namespace
+// only keeps new booleans from being created.
+func (*RepoImplement) PutNamespace(ns Namespace) (namespace Namespace, created bool, err error) {
+	if err := ValidateNamespace(ns.Name); err != nil {
+		return Namespace{}, false, err
+	}
+	if err := validateQuota(ns); err != nil {
+		return Namespace{}, false, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return Namespace{}, false, connectionError(err)
+	}
+
+	put := func(tx *gorm.DB) error {
+		var existing []Namespace
+		if err := lockedQuery(tx).Limit(1).Find(&existing, "name = ?", ns.Name).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			namespace = Namespace{Name: ns.Name, MaxBooleans: ns.MaxBooleans, CreatedAt: now()}
+			created = true
+			return tx.Create(&namespace).Error
+		}
+
+		namespace, created = existing[0], false
+		namespace.MaxBooleans = ns.MaxBooleans
+		return tx.Model(&Namespace{}).Where("name = ?", ns.Name).UpdateColumn("max_booleans", ns.MaxBooleans).Error
+	}
+
+	// When two requests create the same namespace, the one which loses changes the namespace created by the other.
+	err = db.Transaction(put)
+	if database.IsDuplicateKey(err) {
+		err = db.Transaction(put)
+	}
+	if err != nil {
+		return Namespace{}, false, storageError(err)
+	}
+
+	if namespace.Booleans, err = countBooleans(db, ns.Name, now()); err != nil {
+		return Namespace{}, false, storageError(err)
+	}
+	return namespace, created, nil
+}
+
+// namespaceName returns namespace of the repo, which is DefaultNamespace for a repo made without one.
+func (r *MemoryRepo) namespaceName() string {
+	if r.namespace == "" {
+		return DefaultNamespace
+	}
+	return r.namespace
+}
+
+// InNamespace returns a repo over the same memory, which reads and changes only booleans of given namespace.
+func (r *MemoryRepo) InNamespace(namespace string) Repo {
+	return &MemoryRepo{memoryStore: r.memoryStore, audit: r.audit, namespace: namespace}
+}
+
+// withAudit returns a repo over the same memory and namespace, which records changes with given audit.
+func (r *MemoryRepo) withAudit(audit Audit) *MemoryRepo {
+	return &MemoryRepo{memoryStore: r.memoryStore, audit: audit, namespace: r.namespace}
+}
+
+// countBooleans counts booleans which exist in namespace now. Caller must hold the mutex at least for reading.
+func (r *MemoryRepo) countBooleans(namespace string) int64 {
+	var count int64
+	for _, b := range r.booleans {
+		if _, ok := b.atTime(now()); ok && b.Namespace == namespace {
+			count++
+		}
+	}
+	return count
+}
+
+// reserveQuota returns ErrQuotaExceeded when namespace of the repo has no room for another boolean,
+// or ErrNamespaceNotFound when it does not exist. Caller must hold the mutex.
+func (r *MemoryRepo) reserveQuota() error {
+	ns, ok := r.namespaces[r.namespaceName()]
+	if !ok {
+		return ErrNamespaceNotFound
+	}
+	if ns.full(r.countBooleans(ns.Name)) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Namespaces returns every namespace in memory with number of its booleans, in order of their names.
+func (r *MemoryRepo) Namespaces() ([]Namespace, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	namespaces := make([]Namespace, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		ns.Booleans = r.countBooleans(ns.Name)
+		namespaces = append(namespaces, ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	return namespaces, nil
+}
+
+// NamespaceNames returns names of every namespace in memory in order, without counting their booleans.
+func (r *MemoryRepo) NamespaceNames() ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.namespaces))
+	for name := range r.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetNamespace receives namespace with name from memory, with number of its booleans.
+func (r *MemoryRepo) GetNamespace(name string) (Namespace, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ns, ok := r.namespaces[name]
+	if !ok {
+		return Namespace{}, ErrNamespaceNotFound
+	}
+	ns.Booleans = r.countBooleans(name)
+	return ns, nil
+}
+
+// PutNamespace creates namespace with name of ns, or changes quota of the existing one.
+// Created is true for a new namespace. Quota lower than number of booleans in the namespace
+// only keeps new booleans from being created.
+func (r *MemoryRepo) PutNamespace(ns Namespace) (Namespace, bool, error) {
+	if err := ValidateNamespace(ns.Name); err != nil {
+		return Namespace{}, false, err
+	}
+	if err := validateQuota(ns); err != nil {
+		return Namespace{}, false, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	namespace, ok := r.namespaces[ns.Name]
+	if !ok {
+		namespace = Namespace{Name: ns.Name, CreatedAt: now()}
+	}
+	namespace.MaxBooleans = ns.MaxBooleans
+	r.namespaces[ns.Name] = namespace
+
+	namespace.Booleans = r.countBooleans(ns.Name)
+	return namespace, !ok, nil
+}
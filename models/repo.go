@@ -1,13 +1,89 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
 
-// Repo is an interface which will help in mock
+	"github.com/google/uuid"
+)
+
+// Repo is an interface which will help in mock. It reads and changes booleans of a single namespace,
+// and reports failures with ErrNotFound, ErrConflict, ErrValidation or ErrUnavailable, possibly wrapped.
 type Repo interface {
+	// InNamespace returns repo of given namespace. Booleans of other namespaces are not found by it,
+	// and keys are unique within it.
+	InNamespace(namespace string) Repo
 	Get(uuid.UUID) (Boolean, error)
-	Create(Boolean) (uuid.UUID, error)
-	Update(uuid.UUID, Boolean) error
-	Delete(uuid.UUID) error
+	// GetMany leaves out booleans which are not found instead of failing.
+	GetMany(ids []uuid.UUID, keys []string) ([]Boolean, error)
+	// Create returns ErrDuplicateKey when key is given to another boolean, ErrNamespaceNotFound when
+	// namespace does not exist, and ErrQuotaExceeded when it has as many booleans as its quota allows.
+	Create(Boolean) (Boolean, error)
+	// Update changes boolean only when it has given version, or any version for AnyVersion,
+	// and returns ErrVersionMismatch otherwise.
+	Update(id uuid.UUID, b Boolean, version int64) (Boolean, error)
+	// Patch changes fields set in patch, with versions checked same as Update.
+	Patch(id uuid.UUID, patch BooleanPatch, version int64) (Boolean, error)
+	Toggle(uuid.UUID) (Boolean, error)
+	// CompareAndSwap returns the current boolean along with ErrValueMismatch when its value is not expected.
+	CompareAndSwap(id uuid.UUID, expected bool, new bool) (Boolean, error)
+	// List returns booleans page by page, and a page is continued from Next cursor of the previous one.
+	List(options ListOptions) (Page, error)
+	GetByKey(key string) (Boolean, error)
+	UpsertByKey(key string, b Boolean, version int64) (Boolean, bool, error)
+	// Delete hides boolean until Purge removes it, with versions checked same as Update.
+	Delete(id uuid.UUID, version int64) error
+	// Bulk applies many operations, in a single transaction when atomic, and reports outcome of each of them.
+	Bulk(operations []Operation, atomic bool) ([]OperationResult, error)
+	// History lists every change of a boolean, newest first, and is kept after the boolean is deleted.
+	History(id uuid.UUID, options HistoryOptions) (HistoryPage, error)
+	// GetAt returns boolean as it was at given time, read from its history.
+	GetAt(id uuid.UUID, at time.Time) (Boolean, error)
+	// Rollback brings back value of an earlier version, and returns ErrVersionNotFound when
+	// history does not have it.
+	Rollback(id uuid.UUID, to int64, version int64) (Boolean, error)
+	// Promote copies value of one environment to another as a single change, recorded in history.
+	// Environments without a value of their own share value of the boolean.
+	Promote(id uuid.UUID, from string, to string, version int64) (Boolean, error)
+	// Restore brings back a deleted boolean with its key, and clears its expiry when it has passed.
+	// It returns ErrNotDeleted for a boolean which is not deleted.
+	Restore(id uuid.UUID) (Boolean, error)
+	// Purge removes booleans deleted before given time, along with their access lists.
+	Purge(before time.Time) (int64, error)
+	// Expire stores expiry of booleans whose ExpiresAt has passed. Booleans are read as expired even before it.
+	Expire(at time.Time) (int64, error)
+	Schedules(id uuid.UUID) ([]Schedule, error)
+	CreateSchedule(id uuid.UUID, s Schedule) (Schedule, error)
+	// CancelSchedule returns ErrScheduleNotPending for a schedule which was already applied or canceled.
+	CancelSchedule(id uuid.UUID, scheduleID uuid.UUID) error
+	// ApplySchedules applies each schedule whose time has passed exactly once, even when it is called concurrently.
+	ApplySchedules(at time.Time) (int64, error)
+	// Namespaces lists all namespaces, whichever namespace the repo is in.
+	Namespaces() ([]Namespace, error)
+	// NamespaceNames lists names of all namespaces. It does not count booleans, so it is cheap enough
+	// for background jobs.
+	NamespaceNames() ([]string, error)
+	GetNamespace(name string) (Namespace, error)
+	PutNamespace(ns Namespace) (Namespace, bool, error)
+	// APIKeys lists API keys, which are not in any namespace.
+	APIKeys() ([]APIKey, error)
+	// CreateAPIKey returns the key itself along with it, as only its hash is stored.
+	CreateAPIKey(k APIKey) (APIKey, string, error)
+	// RotateAPIKey replaces the key, and returns the new one same as CreateAPIKey.
+	RotateAPIKey(id uuid.UUID) (APIKey, string, error)
+	RevokeAPIKey(id uuid.UUID) (APIKey, error)
+	// AuthenticateAPIKey returns ErrAPIKeyNotFound for a key which is unknown or revoked.
+	AuthenticateAPIKey(secret string) (APIKey, error)
+	// RoleBindings lists roles granted to subjects in namespace, and returns ErrNamespaceNotFound
+	// when the namespace does not exist. PutRoleBinding and DeleteRoleBinding do the same.
+	RoleBindings(namespace string) ([]RoleBinding, error)
+	PutRoleBinding(b RoleBinding) (RoleBinding, bool, error)
+	DeleteRoleBinding(namespace string, subject string) error
+	// AccessList lists subjects which may change the boolean. It is empty for most booleans.
+	AccessList(id uuid.UUID) ([]string, error)
+	// DeletedAccessList lists subjects which may restore a deleted boolean, as its list is kept until it is purged.
+	// It returns ErrNotFound for a boolean which is not deleted.
+	DeletedAccessList(id uuid.UUID) ([]string, error)
+	SetAccessList(id uuid.UUID, subjects []string) ([]string, error)
 }
 
 var repo Repo
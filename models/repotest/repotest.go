@@ -0,0 +1,2424 @@
+// Package repotest provides conformance tests which every implementation of models.Repo must pass,
+// so all storage backends behave the same for the controllers.
+package repotest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// Factory returns a new, empty Repo for a single test.
+type Factory func(t *testing.T) models.Repo
+
+// concurrency is number of goroutines used by concurrent tests.
+const concurrency = 20
+
+// Run runs the whole conformance suite against repos made by newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, r models.Repo)
+	}{
+		{"GetMissing", testGetMissing},
+		{"GetMany", testGetMany},
+		{"GetManyEmpty", testGetManyEmpty},
+		{"CreateAssignsID", testCreateAssignsID},
+		{"CreateIgnoresGivenID", testCreateIgnoresGivenID},
+		{"GetReturnsCreated", testGetReturnsCreated},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"UpdateVersion", testUpdateVersion},
+		{"Patch", testPatch},
+		{"PatchMissing", testPatchMissing},
+		{"PatchVersion", testPatchVersion},
+		{"Toggle", testToggle},
+		{"ToggleMissing", testToggleMissing},
+		{"CompareAndSwap", testCompareAndSwap},
+		{"CompareAndSwapMismatch", testCompareAndSwapMismatch},
+		{"CompareAndSwapMissing", testCompareAndSwapMissing},
+		{"KeyTooLong", testKeyTooLong},
+		{"CreatedAtKept", testCreatedAtKept},
+		{"UpdatedAt", testUpdatedAt},
+		{"Metadata", testMetadata},
+		{"MetadataInvalid", testMetadataInvalid},
+		{"ListEmpty", testListEmpty},
+		{"ListByCreated", testListByCreated},
+		{"ListByKey", testListByKey},
+		{"ListFilters", testListFilters},
+		{"ListByOwnerAndTags", testListByOwnerAndTags},
+		{"ListKeyPrefixWildcards", testListKeyPrefixWildcards},
+		{"ListKeyPrefixCase", testListKeyPrefixCase},
+		{"KeyUnique", testKeyUnique},
+		{"KeyReleased", testKeyReleased},
+		{"GetByKey", testGetByKey},
+		{"GetByKeyMissing", testGetByKeyMissing},
+		{"UpsertByKey", testUpsertByKey},
+		{"UpsertByKeyVersion", testUpsertByKeyVersion},
+		{"BulkAtomic", testBulkAtomic},
+		{"BulkAtomicRollsBack", testBulkAtomicRollsBack},
+		{"BulkBestEffort", testBulkBestEffort},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"DeleteTwice", testDeleteTwice},
+		{"DeleteVersion", testDeleteVersion},
+		{"DeleteHides", testDeleteHides},
+		{"Restore", testRestore},
+		{"RestoreFailures", testRestoreFailures},
+		{"Purge", testPurge},
+		{"History", testHistory},
+		{"HistoryAudit", testHistoryAudit},
+		{"HistoryPages", testHistoryPages},
+		{"HistoryMissing", testHistoryMissing},
+		{"HistoryBulkRollsBack", testHistoryBulkRollsBack},
+		{"GetAt", testGetAt},
+		{"GetAtMissing", testGetAtMissing},
+		{"Rollback", testRollback},
+		{"RollbackFailures", testRollbackFailures},
+		{"Environments", testEnvironments},
+		{"EnvironmentsInvalid", testEnvironmentsInvalid},
+		{"EnvironmentsHistory", testEnvironmentsHistory},
+		{"Promote", testPromote},
+		{"PromoteFailures", testPromoteFailures},
+		{"ExpiryFallback", testExpiryFallback},
+		{"ExpiryDeletes", testExpiryDeletes},
+		{"ExpiryAhead", testExpiryAhead},
+		{"ExpiryChanges", testExpiryChanges},
+		{"ExpiryInvalid", testExpiryInvalid},
+		{"ExpiryRestore", testExpiryRestore},
+		{"Schedules", testSchedules},
+		{"ScheduleFailures", testScheduleFailures},
+		{"ScheduleOfDeleted", testScheduleOfDeleted},
+		{"Namespaces", testNamespaces},
+		{"NamespaceInvalid", testNamespaceInvalid},
+		{"NamespaceMissing", testNamespaceMissing},
+		{"NamespaceIsolation", testNamespaceIsolation},
+		{"NamespaceKeys", testNamespaceKeys},
+		{"NamespaceMaintenance", testNamespaceMaintenance},
+		{"NamespaceQuota", testNamespaceQuota},
+		{"APIKeys", testAPIKeys},
+		{"APIKeyInvalid", testAPIKeyInvalid},
+		{"APIKeyRotate", testAPIKeyRotate},
+		{"APIKeyRevoke", testAPIKeyRevoke},
+		{"RoleBindings", testRoleBindings},
+		{"RoleBindingInvalid", testRoleBindingInvalid},
+		{"AccessList", testAccessList},
+		{"AccessListInvalid", testAccessListInvalid},
+		{"AccessListPurged", testAccessListPurged},
+		{"ConcurrentLifecycles", testConcurrentLifecycles},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ConcurrentVersionedUpdates", testConcurrentVersionedUpdates},
+		{"ConcurrentToggles", testConcurrentToggles},
+		{"ConcurrentCompareAndSwaps", testConcurrentCompareAndSwaps},
+		{"ConcurrentUpsertsByKey", testConcurrentUpsertsByKey},
+		{"ConcurrentScheduleApplies", testConcurrentScheduleApplies},
+		{"ConcurrentCreatesWithinQuota", testConcurrentCreatesWithinQuota},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// assertNotFound checks that err reports a missing boolean.
+func assertNotFound(t *testing.T, err error) bool {
+	return assert.True(t, errors.Is(err, models.ErrNotFound), "expected models.ErrNotFound, got %v", err)
+}
+
+// assertVersionMismatch checks that err reports a boolean changed since expected version.
+func assertVersionMismatch(t *testing.T, err error) bool {
+	return assert.True(t, errors.Is(err, models.ErrVersionMismatch), "expected models.ErrVersionMismatch, got %v", err) &&
+		assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+}
+
+// assertValidation checks that err reports a boolean which can not be stored.
+func assertValidation(t *testing.T, err error) bool {
+	return assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+}
+
+// assertDuplicateKey checks that err reports a key used by another boolean.
+func assertDuplicateKey(t *testing.T, err error) bool {
+	return assert.True(t, errors.Is(err, models.ErrDuplicateKey), "expected models.ErrDuplicateKey, got %v", err) &&
+		assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+}
+
+// mustCreate creates b in r and fails the test on error.
+func mustCreate(t *testing.T, r models.Repo, b models.Boolean) uuid.UUID {
+	created, err := r.Create(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.ID
+}
+
+// update replaces boolean with id, and returns only the error, for tests which check just that.
+func update(r models.Repo, id uuid.UUID, b models.Boolean, version int64) error {
+	_, err := r.Update(id, b, version)
+	return err
+}
+
+// assertStored checks that r holds expected boolean.
+func assertStored(t *testing.T, r models.Repo, expected models.Boolean) {
+	b, err := r.Get(expected.ID)
+	assert.NoError(t, err)
+	assertBoolean(t, expected, b)
+}
+
+// assertBoolean checks that actual is expected boolean, which is in DefaultNamespace unless it says otherwise.
+// Creation and update times are set by the repo, so they are only checked to be set.
+func assertBoolean(t *testing.T, expected models.Boolean, actual models.Boolean) {
+	if expected.Namespace == "" {
+		expected.Namespace = models.DefaultNamespace
+	}
+	assert.False(t, actual.CreatedAt.IsZero(), "expected creation time to be set")
+	assert.False(t, actual.UpdatedAt.Before(actual.CreatedAt), "expected update time not to be before creation time")
+	actual.CreatedAt = expected.CreatedAt
+	actual.UpdatedAt = expected.UpdatedAt
+	assert.Equal(t, expected, actual)
+}
+
+// listIDs lists all booleans matching options page by page, and returns their ids in order.
+func listIDs(t *testing.T, r models.Repo, options models.ListOptions) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for {
+		page, err := r.List(options)
+		if !assert.NoError(t, err) {
+			return ids
+		}
+		assert.LessOrEqual(t, len(page.Booleans), options.Limit)
+		for _, b := range page.Booleans {
+			ids = append(ids, b.ID)
+		}
+		if page.Next == nil {
+			return ids
+		}
+		options.After = page.Next
+	}
+}
+
+// historyOf returns the whole history of boolean with id, read page by page.
+func historyOf(t *testing.T, r models.Repo, id uuid.UUID) []models.HistoryEntry {
+	entries := []models.HistoryEntry{}
+	options := models.HistoryOptions{Limit: 2}
+	for {
+		page, err := r.History(id, options)
+		if !assert.NoError(t, err) {
+			return entries
+		}
+		assert.LessOrEqual(t, len(page.Entries), options.Limit)
+		entries = append(entries, page.Entries...)
+		if page.Next == 0 {
+			return entries
+		}
+		options.Before = page.Next
+	}
+}
+
+// assertEntry checks action and change recorded by entry. Nil old or new boolean means
+// boolean did not exist before or after the change.
+func assertEntry(t *testing.T, action models.HistoryAction, old *models.Boolean, new *models.Boolean, entry models.HistoryEntry) {
+	assert.Equal(t, action, entry.Action)
+	assert.False(t, entry.CreatedAt.IsZero(), "expected time of change to be set")
+	if old != nil {
+		assert.Equal(t, old.ID, entry.BooleanID)
+		assert.Equal(t, &old.Value, entry.OldValue)
+		assert.Equal(t, &old.Key, entry.OldKey)
+		assert.Equal(t, old.Environments, entry.OldEnvironments)
+	} else {
+		assert.Nil(t, entry.OldValue)
+		assert.Nil(t, entry.OldKey)
+	}
+	if new != nil {
+		assert.Equal(t, new.ID, entry.BooleanID)
+		assert.Equal(t, new.Version, entry.Version)
+		assert.Equal(t, &new.Value, entry.NewValue)
+		assert.Equal(t, &new.Key, entry.NewKey)
+		assert.Equal(t, new.Environments, entry.NewEnvironments)
+	} else {
+		assert.Equal(t, old.Version, entry.Version)
+		assert.Nil(t, entry.NewValue)
+		assert.Nil(t, entry.NewKey)
+	}
+}
+
+// createInOrder creates booleans with keys one by one, so each has later creation time than the previous one.
+func createInOrder(t *testing.T, r models.Repo, keys ...string) []uuid.UUID {
+	ids := make([]uuid.UUID, len(keys))
+	for i, key := range keys {
+		ids[i] = mustCreate(t, r, models.Boolean{Value: i%2 == 0, Key: key})
+		time.Sleep(2 * time.Millisecond)
+	}
+	return ids
+}
+
+func testGetMissing(t *testing.T, r models.Repo) {
+	b, err := r.Get(uuid.New())
+	assertNotFound(t, err)
+	assert.Equal(t, models.Boolean{}, b)
+}
+
+func testGetMany(t *testing.T, r models.Repo) {
+	byID := mustCreate(t, r, models.Boolean{Value: true, Key: "by id"})
+	byKey := mustCreate(t, r, models.Boolean{Value: false, Key: "by key"})
+	both := mustCreate(t, r, models.Boolean{Value: true, Key: "both"})
+	mustCreate(t, r, models.Boolean{Value: true, Key: "other"})
+
+	booleans, err := r.GetMany(
+		[]uuid.UUID{byID, both, uuid.New(), byID},
+		[]string{"by key", "both", "missing"},
+	)
+	assert.NoError(t, err)
+
+	found := map[uuid.UUID]models.Boolean{}
+	for _, b := range booleans {
+		found[b.ID] = b
+	}
+	assert.Len(t, booleans, 3)
+	assertBoolean(t, models.Boolean{ID: byID, Value: true, Key: "by id", Version: 1}, found[byID])
+	assertBoolean(t, models.Boolean{ID: byKey, Value: false, Key: "by key", Version: 1}, found[byKey])
+	assertBoolean(t, models.Boolean{ID: both, Value: true, Key: "both", Version: 1}, found[both])
+}
+
+func testGetManyEmpty(t *testing.T, r models.Repo) {
+	mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+
+	booleans, err := r.GetMany(nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, booleans)
+
+	booleans, err = r.GetMany([]uuid.UUID{uuid.New()}, []string{"missing"})
+	assert.NoError(t, err)
+	assert.Empty(t, booleans)
+
+	booleans, err = r.GetMany(nil, []string{"name"})
+	assert.NoError(t, err)
+	assert.Len(t, booleans, 1)
+}
+
+func testCreateAssignsID(t *testing.T, r models.Repo) {
+	first := mustCreate(t, r, models.Boolean{Value: true, Key: "first"})
+	second := mustCreate(t, r, models.Boolean{Value: true, Key: "second"})
+
+	assert.NotEqual(t, uuid.UUID{}, first)
+	assert.NotEqual(t, uuid.UUID{}, second)
+	assert.NotEqual(t, first, second)
+}
+
+func testCreateIgnoresGivenID(t *testing.T, r models.Repo) {
+	given := uuid.New()
+	id := mustCreate(t, r, models.Boolean{ID: given, Value: true, Version: 7})
+
+	assert.NotEqual(t, given, id)
+	_, err := r.Get(given)
+	assertNotFound(t, err)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: models.FirstVersion})
+}
+
+func testGetReturnsCreated(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "name", Version: models.FirstVersion})
+
+	id = mustCreate(t, r, models.Boolean{Value: false})
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Version: models.FirstVersion})
+}
+
+func testUpdate(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	other := mustCreate(t, r, models.Boolean{Value: true, Key: "other"})
+
+	assert.NoError(t, update(r, id, models.Boolean{ID: other, Value: false, Key: "new name", Version: 9}, models.AnyVersion))
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "new name", Version: 2})
+	assertStored(t, r, models.Boolean{ID: other, Value: true, Key: "other", Version: 1})
+
+	// Storing same values is still a change
+	assert.NoError(t, update(r, id, models.Boolean{Value: false, Key: "new name"}, models.AnyVersion))
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "new name", Version: 3})
+}
+
+func testUpdateMissing(t *testing.T, r models.Repo) {
+	id := uuid.New()
+	assertNotFound(t, update(r, id, models.Boolean{Value: true}, models.AnyVersion))
+	assertNotFound(t, update(r, id, models.Boolean{Value: true}, models.FirstVersion))
+
+	_, err := r.Get(id)
+	assertNotFound(t, err)
+}
+
+func testUpdateVersion(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+
+	assert.NoError(t, update(r, id, models.Boolean{Value: false, Key: "first"}, 1))
+	assertVersionMismatch(t, update(r, id, models.Boolean{Value: true, Key: "stale"}, 1))
+	assertVersionMismatch(t, update(r, id, models.Boolean{Value: true, Key: "future"}, 3))
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "first", Version: 2})
+}
+
+func testPatch(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+
+	value := false
+	b, err := r.Patch(id, models.BooleanPatch{Value: &value}, models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "name", Version: 2}, b)
+
+	key := "new name"
+	b, err = r.Patch(id, models.BooleanPatch{Key: &key}, models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "new name", Version: 3}, b)
+
+	// Empty patch changes nothing, version included
+	b, err = r.Patch(id, models.BooleanPatch{}, models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "new name", Version: 3}, b)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "new name", Version: 3})
+}
+
+func testPatchMissing(t *testing.T, r models.Repo) {
+	value := true
+	_, err := r.Patch(uuid.New(), models.BooleanPatch{Value: &value}, models.AnyVersion)
+	assertNotFound(t, err)
+
+	_, err = r.Patch(uuid.New(), models.BooleanPatch{}, models.FirstVersion)
+	assertNotFound(t, err)
+}
+
+func testPatchVersion(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+
+	value := false
+	b, err := r.Patch(id, models.BooleanPatch{Value: &value}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), b.Version)
+
+	value = true
+	_, err = r.Patch(id, models.BooleanPatch{Value: &value}, 1)
+	assertVersionMismatch(t, err)
+
+	_, err = r.Patch(id, models.BooleanPatch{}, 1)
+	assertVersionMismatch(t, err)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "name", Version: 2})
+}
+
+func testToggle(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+
+	b, err := r.Toggle(id)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "name", Version: 2}, b)
+
+	b, err = r.Toggle(id)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "name", Version: 3}, b)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "name", Version: 3})
+}
+
+func testToggleMissing(t *testing.T, r models.Repo) {
+	_, err := r.Toggle(uuid.New())
+	assertNotFound(t, err)
+}
+
+func testCompareAndSwap(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: false, Key: "lock"})
+
+	b, err := r.CompareAndSwap(id, false, true)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "lock", Version: 2}, b)
+
+	// Swapping to the same value succeeds without a change
+	b, err = r.CompareAndSwap(id, true, true)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "lock", Version: 2}, b)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "lock", Version: 2})
+}
+
+func testCompareAndSwapMismatch(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "lock"})
+
+	b, err := r.CompareAndSwap(id, false, true)
+	assert.True(t, errors.Is(err, models.ErrValueMismatch), "expected models.ErrValueMismatch, got %v", err)
+	assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "lock", Version: 1}, b)
+
+	b, err = r.CompareAndSwap(id, false, false)
+	assert.True(t, errors.Is(err, models.ErrValueMismatch), "expected models.ErrValueMismatch, got %v", err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "lock", Version: 1}, b)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "lock", Version: 1})
+}
+
+func testCompareAndSwapMissing(t *testing.T, r models.Repo) {
+	_, err := r.CompareAndSwap(uuid.New(), false, true)
+	assertNotFound(t, err)
+
+	_, err = r.CompareAndSwap(uuid.New(), true, true)
+	assertNotFound(t, err)
+}
+
+func testKeyTooLong(t *testing.T, r models.Repo) {
+	long := strings.Repeat("k", models.MaxKeyLength+1)
+
+	_, err := r.Create(models.Boolean{Value: true, Key: long})
+	assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: strings.Repeat("k", models.MaxKeyLength)})
+	err = update(r, id, models.Boolean{Value: true, Key: long}, models.AnyVersion)
+	assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+
+	_, err = r.Patch(id, models.BooleanPatch{Key: &long}, models.AnyVersion)
+	assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: strings.Repeat("k", models.MaxKeyLength), Version: 1})
+}
+
+func testCreatedAtKept(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, CreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)})
+	created, err := r.Get(id)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), created.CreatedAt, time.Minute)
+
+	assert.NoError(t, update(r, id, models.Boolean{Value: false}, models.AnyVersion))
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+
+	b, err := r.Get(id)
+	assert.NoError(t, err)
+	assert.True(t, created.CreatedAt.Equal(b.CreatedAt), "expected creation time %v, got %v", created.CreatedAt, b.CreatedAt)
+}
+
+func testUpdatedAt(t *testing.T, r models.Repo) {
+	created, err := r.Create(models.Boolean{Value: true, UpdatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), created.UpdatedAt, time.Minute)
+	assert.True(t, created.CreatedAt.Equal(created.UpdatedAt), "expected update time %v, got %v", created.CreatedAt, created.UpdatedAt)
+	time.Sleep(2 * time.Millisecond)
+
+	updated, err := r.Update(created.ID, models.Boolean{Value: false}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt), "expected update time after %v, got %v", created.UpdatedAt, updated.UpdatedAt)
+	time.Sleep(2 * time.Millisecond)
+
+	toggled, err := r.Toggle(created.ID)
+	assert.NoError(t, err)
+	assert.True(t, toggled.UpdatedAt.After(updated.UpdatedAt), "expected update time after %v, got %v", updated.UpdatedAt, toggled.UpdatedAt)
+
+	b, err := r.Get(created.ID)
+	assert.NoError(t, err)
+	assert.True(t, created.CreatedAt.Equal(b.CreatedAt), "expected creation time %v, got %v", created.CreatedAt, b.CreatedAt)
+	assert.True(t, toggled.UpdatedAt.Equal(b.UpdatedAt), "expected update time %v, got %v", toggled.UpdatedAt, b.UpdatedAt)
+}
+
+func testMetadata(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Description: "Dark mode", Owner: "web", Tags: models.Tags{"ui", "beta", "ui"}})
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 1, Description: "Dark mode", Owner: "web", Tags: models.Tags{"ui", "beta"}})
+
+	// Fields left out of a patch are kept, and empty tags remove every tag.
+	description := "Dark mode for everyone"
+	b, err := r.Patch(id, models.BooleanPatch{Description: &description, Tags: &models.Tags{}}, models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Version: 2, Description: description, Owner: "web"}, b)
+
+	// Update replaces every field.
+	assert.NoError(t, update(r, id, models.Boolean{Value: false, Tags: models.Tags{"ops"}}, models.AnyVersion))
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Version: 3, Tags: models.Tags{"ops"}})
+
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 4, Tags: models.Tags{"ops"}})
+}
+
+func testMetadataInvalid(t *testing.T, r models.Repo) {
+	invalid := []models.Boolean{
+		{Value: true, Description: strings.Repeat("d", models.MaxDescriptionLength+1)},
+		{Value: true, Owner: strings.Repeat("o", models.MaxOwnerLength+1)},
+		{Value: true, Tags: models.Tags{""}},
+		{Value: true, Tags: models.Tags{"with space"}},
+		{Value: true, Tags: models.Tags{"with,comma"}},
+		{Value: true, Tags: models.Tags{strings.Repeat("t", models.MaxTagLength+1)}},
+	}
+	tooMany := models.Boolean{Value: true}
+	for i := 0; i <= models.MaxTags; i++ {
+		tooMany.Tags = append(tooMany.Tags, fmt.Sprintf("tag%d", i))
+	}
+	invalid = append(invalid, tooMany)
+
+	id := mustCreate(t, r, models.Boolean{Value: true, Owner: "web"})
+	for _, b := range invalid {
+		_, err := r.Create(b)
+		assertValidation(t, err)
+		assertValidation(t, update(r, id, b, models.AnyVersion))
+		_, err = r.Patch(id, models.BooleanPatch{Description: &b.Description, Owner: &b.Owner, Tags: &b.Tags}, models.AnyVersion)
+		assertValidation(t, err)
+	}
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 1, Owner: "web"})
+
+	// Longest description, owner and tags are stored.
+	longest := models.Boolean{
+		Value:       true,
+		Description: strings.Repeat("é", models.MaxDescriptionLength),
+		Owner:       strings.Repeat("o", models.MaxOwnerLength),
+	}
+	for i := 0; i < models.MaxTags; i++ {
+		longest.Tags = append(longest.Tags, fmt.Sprintf("%0*d", models.MaxTagLength, i))
+	}
+	longest.ID = mustCreate(t, r, longest)
+	longest.Version = 1
+	assertStored(t, r, longest)
+}
+
+func testListEmpty(t *testing.T, r models.Repo) {
+	page, err := r.List(models.ListOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Booleans)
+	assert.Nil(t, page.Next)
+}
+
+func testListByCreated(t *testing.T, r models.Repo) {
+	ids := createInOrder(t, r, "c", "a", "e", "b", "d")
+
+	page, err := r.List(models.ListOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, page.Next)
+	if assert.Len(t, page.Booleans, 5) {
+		assertBoolean(t, models.Boolean{ID: ids[0], Value: true, Key: "c", Version: 1}, page.Booleans[0])
+	}
+
+	assert.Equal(t, ids, listIDs(t, r, models.ListOptions{Limit: 2}))
+	assert.Equal(t, ids, listIDs(t, r, models.ListOptions{Sort: models.SortByCreated, Limit: 5}))
+	assert.Equal(t, []uuid.UUID{ids[4], ids[3], ids[2], ids[1], ids[0]}, listIDs(t, r, models.ListOptions{Descending: true, Limit: 2}))
+}
+
+func testListByKey(t *testing.T, r models.Repo) {
+	ids := createInOrder(t, r, "c", "a", "e", "b", "d")
+
+	assert.Equal(t, []uuid.UUID{ids[1], ids[3], ids[0], ids[4], ids[2]},
+		listIDs(t, r, models.ListOptions{Sort: models.SortByKey, Limit: 2}))
+	assert.Equal(t, []uuid.UUID{ids[2], ids[4], ids[0], ids[3], ids[1]},
+		listIDs(t, r, models.ListOptions{Sort: models.SortByKey, Descending: true, Limit: 3}))
+
+	// Booleans with equal keys are not skipped or repeated between pages.
+	same := createInOrder(t, r, "", "", "")
+	key := ""
+	listed := listIDs(t, r, models.ListOptions{Key: &key, Sort: models.SortByKey, Limit: 1})
+	assert.ElementsMatch(t, same, listed)
+}
+
+func testListFilters(t *testing.T, r models.Repo) {
+	ids := createInOrder(t, r, "feature.a", "feature.b", "feature", "other.a", "")
+
+	value := true
+	assert.Equal(t, []uuid.UUID{ids[0], ids[2], ids[4]}, listIDs(t, r, models.ListOptions{Value: &value, Limit: 10}))
+
+	key := "feature"
+	assert.Equal(t, []uuid.UUID{ids[2]}, listIDs(t, r, models.ListOptions{Key: &key, Limit: 10}))
+
+	empty := ""
+	assert.Equal(t, []uuid.UUID{ids[4]}, listIDs(t, r, models.ListOptions{Key: &empty, Limit: 10}))
+
+	assert.Equal(t, []uuid.UUID{ids[0], ids[1]}, listIDs(t, r, models.ListOptions{KeyPrefix: "feature.", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[0], ids[1], ids[2]}, listIDs(t, r, models.ListOptions{KeyPrefix: "feature", Limit: 1}))
+
+	value = false
+	assert.Equal(t, []uuid.UUID{ids[1]}, listIDs(t, r, models.ListOptions{Value: &value, KeyPrefix: "feature", Limit: 10}))
+}
+
+func testListByOwnerAndTags(t *testing.T, r models.Repo) {
+	ids := []uuid.UUID{
+		mustCreate(t, r, models.Boolean{Value: true, Owner: "web", Tags: models.Tags{"ui", "beta"}}),
+		mustCreate(t, r, models.Boolean{Value: true, Owner: "web", Tags: models.Tags{"ui", "uiyx"}}),
+		mustCreate(t, r, models.Boolean{Value: false, Owner: "ops", Tags: models.Tags{"beta", "ui_x"}}),
+		mustCreate(t, r, models.Boolean{Value: false}),
+	}
+
+	owner := "web"
+	assert.ElementsMatch(t, ids[:2], listIDs(t, r, models.ListOptions{Owner: &owner, Limit: 10}))
+	owner = ""
+	assert.ElementsMatch(t, ids[3:], listIDs(t, r, models.ListOptions{Owner: &owner, Limit: 10}))
+
+	assert.ElementsMatch(t, ids[:2], listIDs(t, r, models.ListOptions{Tags: []string{"ui"}, Limit: 10}))
+	assert.ElementsMatch(t, []uuid.UUID{ids[0], ids[2]}, listIDs(t, r, models.ListOptions{Tags: []string{"beta"}, Limit: 1}))
+	assert.ElementsMatch(t, ids[:1], listIDs(t, r, models.ListOptions{Tags: []string{"beta", "ui"}, Limit: 10}))
+	assert.Empty(t, listIDs(t, r, models.ListOptions{Tags: []string{"u"}, Limit: 10}))
+	assert.ElementsMatch(t, ids[2:3], listIDs(t, r, models.ListOptions{Tags: []string{"ui_x"}, Limit: 10}))
+	assert.Empty(t, listIDs(t, r, models.ListOptions{Tags: []string{"UI"}, Limit: 10}))
+
+	owner = "ops"
+	value := false
+	assert.ElementsMatch(t, ids[2:3], listIDs(t, r, models.ListOptions{Owner: &owner, Tags: []string{"beta"}, Value: &value, Limit: 10}))
+}
+
+func testListKeyPrefixWildcards(t *testing.T, r models.Repo) {
+	ids := createInOrder(t, r, "a_b", "axb", "100%", "1000", "x!y")
+
+	assert.Equal(t, []uuid.UUID{ids[0]}, listIDs(t, r, models.ListOptions{KeyPrefix: "a_", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[2]}, listIDs(t, r, models.ListOptions{KeyPrefix: "100%", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[4]}, listIDs(t, r, models.ListOptions{KeyPrefix: "x!", Limit: 10}))
+}
+
+func testListKeyPrefixCase(t *testing.T, r models.Repo) {
+	ids := createInOrder(t, r, "Foo", "foo", "a*b", "a[b]", "a?b")
+
+	assert.Equal(t, []uuid.UUID{ids[1]}, listIDs(t, r, models.ListOptions{KeyPrefix: "fo", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[0]}, listIDs(t, r, models.ListOptions{KeyPrefix: "Fo", Limit: 10}))
+	assert.Empty(t, listIDs(t, r, models.ListOptions{KeyPrefix: "FOO", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[2]}, listIDs(t, r, models.ListOptions{KeyPrefix: "a*", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[3]}, listIDs(t, r, models.ListOptions{KeyPrefix: "a[", Limit: 10}))
+	assert.Equal(t, []uuid.UUID{ids[4]}, listIDs(t, r, models.ListOptions{KeyPrefix: "a?", Limit: 10}))
+}
+
+func testKeyUnique(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "unique"})
+	other := mustCreate(t, r, models.Boolean{Value: true, Key: "other"})
+
+	_, err := r.Create(models.Boolean{Value: false, Key: "unique"})
+	assertDuplicateKey(t, err)
+
+	assertDuplicateKey(t, update(r, other, models.Boolean{Value: false, Key: "unique"}, models.AnyVersion))
+
+	key := "unique"
+	_, err = r.Patch(other, models.BooleanPatch{Key: &key}, models.AnyVersion)
+	assertDuplicateKey(t, err)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "unique", Version: 1})
+	assertStored(t, r, models.Boolean{ID: other, Value: true, Key: "other", Version: 1})
+
+	// Keys are optional, and booleans without key do not conflict.
+	mustCreate(t, r, models.Boolean{Value: true})
+	mustCreate(t, r, models.Boolean{Value: true})
+
+	// Boolean may keep its own key.
+	assert.NoError(t, update(r, id, models.Boolean{Value: false, Key: "unique"}, models.AnyVersion))
+
+	// Keys which differ only in case are different keys.
+	upper := mustCreate(t, r, models.Boolean{Value: true, Key: "Unique"})
+	b, err := r.GetByKey("Unique")
+	assert.NoError(t, err)
+	assert.Equal(t, upper, b.ID)
+}
+
+func testKeyReleased(t *testing.T, r models.Repo) {
+	updated := mustCreate(t, r, models.Boolean{Value: true, Key: "updated"})
+	patched := mustCreate(t, r, models.Boolean{Value: true, Key: "patched"})
+	deleted := mustCreate(t, r, models.Boolean{Value: true, Key: "deleted"})
+
+	assert.NoError(t, update(r, updated, models.Boolean{Value: true, Key: "renamed"}, models.AnyVersion))
+	empty := ""
+	_, err := r.Patch(patched, models.BooleanPatch{Key: &empty}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Delete(deleted, models.AnyVersion))
+
+	for _, key := range []string{"updated", "patched", "deleted"} {
+		_, err := r.GetByKey(key)
+		assertNotFound(t, err)
+		mustCreate(t, r, models.Boolean{Value: false, Key: key})
+	}
+
+	b, err := r.GetByKey("renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, updated, b.ID)
+}
+
+func testGetByKey(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	mustCreate(t, r, models.Boolean{Value: false, Key: "other"})
+
+	b, err := r.GetByKey("name")
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "name", Version: 1}, b)
+}
+
+func testGetByKeyMissing(t *testing.T, r models.Repo) {
+	mustCreate(t, r, models.Boolean{Value: true})
+
+	_, err := r.GetByKey("missing")
+	assertNotFound(t, err)
+
+	_, err = r.GetByKey("")
+	assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+}
+
+func testUpsertByKey(t *testing.T, r models.Repo) {
+	b, created, err := r.UpsertByKey("name", models.Boolean{Value: true, Key: "ignored"}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assertBoolean(t, models.Boolean{ID: b.ID, Value: true, Key: "name", Version: 1}, b)
+	id := b.ID
+
+	b, created, err = r.UpsertByKey("name", models.Boolean{Value: false}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "name", Version: 2}, b)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "name", Version: 2})
+
+	_, _, err = r.UpsertByKey("", models.Boolean{Value: true}, models.AnyVersion)
+	assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+}
+
+func testUpsertByKeyVersion(t *testing.T, r models.Repo) {
+	_, _, err := r.UpsertByKey("name", models.Boolean{Value: true}, models.FirstVersion)
+	assertNotFound(t, err)
+
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+
+	b, created, err := r.UpsertByKey("name", models.Boolean{Value: false}, 1)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, int64(2), b.Version)
+
+	_, _, err = r.UpsertByKey("name", models.Boolean{Value: true}, 1)
+	assertVersionMismatch(t, err)
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "name", Version: 2})
+}
+
+func testBulkAtomic(t *testing.T, r models.Repo) {
+	updated := mustCreate(t, r, models.Boolean{Value: true, Key: "updated"})
+	deleted := mustCreate(t, r, models.Boolean{Value: true, Key: "deleted"})
+
+	value := false
+	results, err := r.Bulk([]models.Operation{
+		{Type: models.OperationCreate, Boolean: models.Boolean{Value: true, Key: "first"}},
+		{Type: models.OperationCreate, Boolean: models.Boolean{Value: false}},
+		{Type: models.OperationUpdate, ID: updated, Patch: models.BooleanPatch{Value: &value}, Version: 1},
+		{Type: models.OperationDelete, ID: deleted, Version: models.AnyVersion},
+	}, true)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 4) {
+		return
+	}
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+
+	assertStored(t, r, models.Boolean{ID: results[0].Boolean.ID, Value: true, Key: "first", Version: 1})
+	assertStored(t, r, models.Boolean{ID: results[1].Boolean.ID, Value: false, Version: 1})
+	assertBoolean(t, models.Boolean{ID: updated, Value: false, Key: "updated", Version: 2}, results[2].Boolean)
+	assertStored(t, r, models.Boolean{ID: updated, Value: false, Key: "updated", Version: 2})
+	assert.Equal(t, deleted, results[3].Boolean.ID)
+	_, err = r.Get(deleted)
+	assertNotFound(t, err)
+}
+
+func testBulkAtomicRollsBack(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "existing"})
+
+	value := false
+	results, err := r.Bulk([]models.Operation{
+		{Type: models.OperationCreate, Boolean: models.Boolean{Value: true, Key: "created"}},
+		{Type: models.OperationUpdate, ID: id, Patch: models.BooleanPatch{Value: &value}, Version: models.AnyVersion},
+		{Type: models.OperationDelete, ID: uuid.New(), Version: models.AnyVersion},
+	}, true)
+	assert.Nil(t, results)
+	var operationError *models.OperationError
+	if assert.True(t, errors.As(err, &operationError), "expected models.OperationError, got %v", err) {
+		assert.Equal(t, 2, operationError.Index)
+	}
+	assertNotFound(t, err)
+
+	_, err = r.GetByKey("created")
+	assertNotFound(t, err)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "existing", Version: 1})
+
+	// Unknown operation fails the whole batch too.
+	_, err = r.Bulk([]models.Operation{
+		{Type: models.OperationCreate, Boolean: models.Boolean{Value: true, Key: "created"}},
+		{Type: "toggle", ID: id},
+	}, true)
+	assert.True(t, errors.Is(err, models.ErrValidation), "expected models.ErrValidation, got %v", err)
+	_, err = r.GetByKey("created")
+	assertNotFound(t, err)
+}
+
+func testBulkBestEffort(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "existing"})
+
+	value := false
+	results, err := r.Bulk([]models.Operation{
+		{Type: models.OperationCreate, Boolean: models.Boolean{Value: true, Key: "created"}},
+		{Type: models.OperationDelete, ID: uuid.New(), Version: models.AnyVersion},
+		{Type: models.OperationCreate, Boolean: models.Boolean{Value: false, Key: "existing"}},
+		{Type: models.OperationUpdate, ID: id, Patch: models.BooleanPatch{Value: &value}, Version: 2},
+		{Type: models.OperationUpdate, ID: id, Patch: models.BooleanPatch{Value: &value}, Version: 1},
+	}, false)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 5) {
+		return
+	}
+
+	assert.NoError(t, results[0].Err)
+	assertNotFound(t, results[1].Err)
+	assertDuplicateKey(t, results[2].Err)
+	assertVersionMismatch(t, results[3].Err)
+	assert.NoError(t, results[4].Err)
+
+	assertStored(t, r, models.Boolean{ID: results[0].Boolean.ID, Value: true, Key: "created", Version: 1})
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "existing", Version: 2})
+}
+
+func testDelete(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	other := mustCreate(t, r, models.Boolean{Value: true})
+
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+
+	_, err := r.Get(id)
+	assertNotFound(t, err)
+
+	_, err = r.Get(other)
+	assert.NoError(t, err)
+}
+
+func testDeleteMissing(t *testing.T, r models.Repo) {
+	assertNotFound(t, r.Delete(uuid.New(), models.AnyVersion))
+	assertNotFound(t, r.Delete(uuid.New(), models.FirstVersion))
+}
+
+func testDeleteTwice(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+	assertNotFound(t, r.Delete(id, models.AnyVersion))
+}
+
+func testDeleteVersion(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	_, err := r.Toggle(id)
+	assert.NoError(t, err)
+
+	assertVersionMismatch(t, r.Delete(id, 1))
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Version: 2})
+
+	assert.NoError(t, r.Delete(id, 2))
+	_, err = r.Get(id)
+	assertNotFound(t, err)
+}
+
+func testDeleteHides(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+
+	booleans, err := r.GetMany([]uuid.UUID{id}, []string{"name"})
+	assert.NoError(t, err)
+	assert.Empty(t, booleans)
+	_, err = r.GetByKey("name")
+	assertNotFound(t, err)
+	assert.Empty(t, listIDs(t, r, models.ListOptions{Limit: 10}))
+	_, err = r.Toggle(id)
+	assertNotFound(t, err)
+	_, err = r.Rollback(id, 1, models.AnyVersion)
+	assertNotFound(t, err)
+
+	// Key of deleted boolean is free.
+	mustCreate(t, r, models.Boolean{Value: false, Key: "name"})
+}
+
+func testRestore(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	_, err := r.Toggle(id)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+
+	b, err := r.Restore(id)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "name", Version: 3}, b)
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "name", Version: 3})
+	b, err = r.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, id, b.ID)
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 4) {
+		assertEntry(t, models.HistoryRestore, nil, &models.Boolean{ID: id, Value: false, Key: "name", Version: 3}, entries[0])
+	}
+
+	// Restored boolean is deleted and restored same as any other.
+	assert.NoError(t, r.Delete(id, 3))
+	_, err = r.Restore(id)
+	assert.NoError(t, err)
+}
+
+func testRestoreFailures(t *testing.T, r models.Repo) {
+	_, err := r.Restore(uuid.New())
+	assertNotFound(t, err)
+
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	_, err = r.Restore(id)
+	assert.True(t, errors.Is(err, models.ErrNotDeleted), "expected models.ErrNotDeleted, got %v", err)
+	assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+
+	// Key taken while boolean was deleted is not taken back.
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+	other := mustCreate(t, r, models.Boolean{Value: false, Key: "name"})
+	_, err = r.Restore(id)
+	assertDuplicateKey(t, err)
+	_, err = r.Get(id)
+	assertNotFound(t, err)
+	b, err := r.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, other, b.ID)
+}
+
+func testPurge(t *testing.T, r models.Repo) {
+	old := mustCreate(t, r, models.Boolean{Value: true})
+	assert.NoError(t, r.Delete(old, models.AnyVersion))
+	between := moment()
+	recent := mustCreate(t, r, models.Boolean{Value: true})
+	assert.NoError(t, r.Delete(recent, models.AnyVersion))
+	kept := mustCreate(t, r, models.Boolean{Value: true})
+
+	purged, err := r.Purge(between)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = r.Restore(old)
+	assertNotFound(t, err)
+	_, err = r.Restore(recent)
+	assert.NoError(t, err)
+	assertStored(t, r, models.Boolean{ID: kept, Value: true, Version: 1})
+
+	// History of purged boolean is kept.
+	assert.Len(t, historyOf(t, r, old), 2)
+
+	purged, err = r.Purge(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func testHistory(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	other := mustCreate(t, r, models.Boolean{Value: true})
+
+	key := "renamed"
+	_, err := r.Patch(id, models.BooleanPatch{Key: &key}, models.AnyVersion)
+	assert.NoError(t, err)
+	_, err = r.Patch(id, models.BooleanPatch{}, models.AnyVersion)
+	assert.NoError(t, err)
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+	_, err = r.CompareAndSwap(id, true, false)
+	assert.True(t, errors.Is(err, models.ErrValueMismatch), "expected models.ErrValueMismatch, got %v", err)
+	_, err = r.CompareAndSwap(id, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, update(r, id, models.Boolean{Value: true, Key: key}, 3))
+	_, _, err = r.UpsertByKey(key, models.Boolean{Value: false}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+
+	// Changes which did not change anything are not recorded, and history is kept after delete.
+	entries := historyOf(t, r, id)
+	if !assert.Len(t, entries, 6) {
+		return
+	}
+	assertEntry(t, models.HistoryDelete, &models.Boolean{ID: id, Value: false, Key: key, Version: 5}, nil, entries[0])
+	assertEntry(t, models.HistoryUpdate,
+		&models.Boolean{ID: id, Value: true, Key: key, Version: 4},
+		&models.Boolean{ID: id, Value: false, Key: key, Version: 5}, entries[1])
+	assertEntry(t, models.HistoryUpdate,
+		&models.Boolean{ID: id, Value: false, Key: key, Version: 3},
+		&models.Boolean{ID: id, Value: true, Key: key, Version: 4}, entries[2])
+	assertEntry(t, models.HistoryUpdate,
+		&models.Boolean{ID: id, Value: true, Key: key, Version: 2},
+		&models.Boolean{ID: id, Value: false, Key: key, Version: 3}, entries[3])
+	assertEntry(t, models.HistoryUpdate,
+		&models.Boolean{ID: id, Value: true, Key: "name", Version: 1},
+		&models.Boolean{ID: id, Value: true, Key: key, Version: 2}, entries[4])
+	assertEntry(t, models.HistoryCreate, nil, &models.Boolean{ID: id, Value: true, Key: "name", Version: 1}, entries[5])
+
+	entries = historyOf(t, r, other)
+	if assert.Len(t, entries, 1) {
+		assertEntry(t, models.HistoryCreate, nil, &models.Boolean{ID: other, Value: true, Version: 1}, entries[0])
+	}
+}
+
+func testHistoryAudit(t *testing.T, r models.Repo) {
+	auditable, ok := r.(models.Auditable)
+	if !assert.True(t, ok, "expected repo to be models.Auditable") {
+		return
+	}
+	audited := auditable.WithAudit(models.Audit{Actor: "alice", Principal: "token:alice", RequestID: "request"})
+
+	id := mustCreate(t, audited, models.Boolean{Value: true})
+	_, err := r.Toggle(id)
+	assert.NoError(t, err)
+
+	// Audited repo works on the same booleans.
+	b, err := audited.Get(id)
+	assert.NoError(t, err)
+	assert.False(t, b.Value)
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "", entries[0].Actor)
+		assert.Equal(t, "", entries[0].RequestID)
+		assert.Equal(t, "", entries[0].Principal)
+		assert.Equal(t, "alice", entries[1].Actor)
+		assert.Equal(t, "token:alice", entries[1].Principal)
+		assert.Equal(t, "request", entries[1].RequestID)
+	}
+}
+
+func testHistoryPages(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	for i := 0; i < 4; i++ {
+		_, err := r.Toggle(id)
+		assert.NoError(t, err)
+	}
+
+	entries := historyOf(t, r, id)
+	if !assert.Len(t, entries, 5) {
+		return
+	}
+	for i, entry := range entries {
+		assert.Equal(t, int64(5-i), entry.Version)
+		if i > 0 {
+			assert.Less(t, entry.ID, entries[i-1].ID)
+		}
+	}
+
+	page, err := r.History(id, models.HistoryOptions{Limit: 5})
+	assert.NoError(t, err)
+	assert.Len(t, page.Entries, 5)
+	assert.Zero(t, page.Next)
+
+	page, err = r.History(id, models.HistoryOptions{Before: entries[4].ID})
+	assert.NoError(t, err)
+	assert.Empty(t, page.Entries)
+}
+
+func testHistoryMissing(t *testing.T, r models.Repo) {
+	_, err := r.History(uuid.New(), models.HistoryOptions{})
+	assertNotFound(t, err)
+}
+
+func testHistoryBulkRollsBack(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	value := false
+	_, err := r.Bulk([]models.Operation{
+		{Type: models.OperationUpdate, ID: id, Patch: models.BooleanPatch{Value: &value}, Version: models.AnyVersion},
+		{Type: models.OperationDelete, ID: uuid.New(), Version: models.AnyVersion},
+	}, true)
+	assertNotFound(t, err)
+	assert.Len(t, historyOf(t, r, id), 1)
+
+	results, err := r.Bulk([]models.Operation{
+		{Type: models.OperationUpdate, ID: id, Patch: models.BooleanPatch{Value: &value}, Version: models.AnyVersion},
+		{Type: models.OperationDelete, ID: uuid.New(), Version: models.AnyVersion},
+	}, false)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Len(t, historyOf(t, r, id), 2)
+}
+
+// moment returns current time, with changes made before and after it a few milliseconds apart.
+func moment() time.Time {
+	time.Sleep(2 * time.Millisecond)
+	at := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	return at
+}
+
+// assertState checks value, key and version of boolean, which may be an earlier state of it.
+func assertState(t *testing.T, expected models.Boolean, actual models.Boolean) {
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.Value, actual.Value)
+	assert.Equal(t, expected.Key, actual.Key)
+	assert.Equal(t, expected.Version, actual.Version)
+}
+
+func testGetAt(t *testing.T, r models.Repo) {
+	beforeCreate := moment()
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	created := moment()
+	key := "renamed"
+	_, err := r.Patch(id, models.BooleanPatch{Key: &key}, models.AnyVersion)
+	assert.NoError(t, err)
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+	changed := moment()
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+	deleted := moment()
+
+	_, err = r.GetAt(id, beforeCreate)
+	assertNotFound(t, err)
+
+	b, err := r.GetAt(id, created)
+	assert.NoError(t, err)
+	assertState(t, models.Boolean{ID: id, Value: true, Key: "name", Version: 1}, b)
+
+	b, err = r.GetAt(id, changed)
+	assert.NoError(t, err)
+	assertState(t, models.Boolean{ID: id, Value: false, Key: key, Version: 3}, b)
+
+	_, err = r.GetAt(id, deleted)
+	assertNotFound(t, err)
+}
+
+func testGetAtMissing(t *testing.T, r models.Repo) {
+	_, err := r.GetAt(uuid.New(), time.Now())
+	assertNotFound(t, err)
+}
+
+func testRollback(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	key := "renamed"
+	_, err := r.Patch(id, models.BooleanPatch{Key: &key}, models.AnyVersion)
+	assert.NoError(t, err)
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+
+	b, err := r.Rollback(id, 1, 3)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Key: "name", Version: 4}, b)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Key: "name", Version: 4})
+
+	// Key of rolled back version is reserved again, and the one it replaced is released.
+	_, err = r.GetByKey(key)
+	assertNotFound(t, err)
+	b, err = r.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, id, b.ID)
+
+	// Rollback is recorded, and can be rolled back too.
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 4) {
+		assertEntry(t, models.HistoryRollback,
+			&models.Boolean{ID: id, Value: false, Key: key, Version: 3},
+			&models.Boolean{ID: id, Value: true, Key: "name", Version: 4}, entries[0])
+	}
+	b, err = r.Rollback(id, 3, models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: key, Version: 5}, b)
+}
+
+func testRollbackFailures(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	_, err := r.Toggle(id)
+	assert.NoError(t, err)
+	other := mustCreate(t, r, models.Boolean{Value: true})
+
+	_, err = r.Rollback(uuid.New(), 1, models.AnyVersion)
+	assertNotFound(t, err)
+
+	_, err = r.Rollback(id, 1, 1)
+	assertVersionMismatch(t, err)
+
+	_, err = r.Rollback(id, 7, models.AnyVersion)
+	assert.True(t, errors.Is(err, models.ErrVersionNotFound), "expected models.ErrVersionNotFound, got %v", err)
+	assertNotFound(t, err)
+
+	// Key of other boolean is not taken over by rollback.
+	otherKey := "taken"
+	_, err = r.Patch(other, models.BooleanPatch{Key: &otherKey}, models.AnyVersion)
+	assert.NoError(t, err)
+	renamed := "renamed"
+	_, err = r.Patch(id, models.BooleanPatch{Key: &renamed}, models.AnyVersion)
+	assert.NoError(t, err)
+	key := "name"
+	_, err = r.Patch(other, models.BooleanPatch{Key: &key}, models.AnyVersion)
+	assert.NoError(t, err)
+	_, err = r.Rollback(id, 1, models.AnyVersion)
+	assertDuplicateKey(t, err)
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: renamed, Version: 3})
+}
+
+// environmentPatch returns patch which gives environments their values, or removes value of those given nil.
+func environmentPatch(values map[string]*bool) models.BooleanPatch {
+	patch := models.EnvironmentPatch(values)
+	return models.BooleanPatch{Environments: &patch}
+}
+
+// valueOf returns pointer to value, for patches.
+func valueOf(value bool) *bool {
+	return &value
+}
+
+func testEnvironments(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: false, Key: "name", Environments: models.EnvironmentValues{"dev": true}})
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "name", Version: 1, Environments: models.EnvironmentValues{"dev": true}})
+
+	b, err := r.Get(id)
+	assert.NoError(t, err)
+	assert.True(t, b.In("dev").Value)
+	assert.False(t, b.In("prod").Value)
+	assert.False(t, b.Value)
+
+	// Patch changes only given environments, and nil removes value of an environment.
+	b, err = r.Patch(id, environmentPatch(map[string]*bool{"staging": valueOf(true), "dev": nil}), models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "name", Version: 2, Environments: models.EnvironmentValues{"staging": true}}, b)
+	b, err = r.Patch(id, environmentPatch(map[string]*bool{"staging": nil}), models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Key: "name", Version: 3}, b)
+
+	// Own value is shared by environments without one, and other changes keep environment values.
+	_, err = r.Patch(id, environmentPatch(map[string]*bool{"prod": valueOf(false)}), models.AnyVersion)
+	assert.NoError(t, err)
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+	_, _, err = r.UpsertByKey("name", models.Boolean{Value: true}, models.AnyVersion)
+	assert.NoError(t, err)
+	b, err = r.GetByKey("name")
+	assert.NoError(t, err)
+	assert.True(t, b.In("staging").Value)
+	assert.False(t, b.In("prod").Value)
+	booleans, err := r.GetMany([]uuid.UUID{id}, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, booleans, 1) {
+		assert.Equal(t, models.EnvironmentValues{"prod": false}, booleans[0].Environments)
+	}
+
+	// Update replaces environment values along with every other field.
+	assert.NoError(t, update(r, id, models.Boolean{Value: true, Environments: models.EnvironmentValues{"dev": false}}, models.AnyVersion))
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 7, Environments: models.EnvironmentValues{"dev": false}})
+	assert.NoError(t, update(r, id, models.Boolean{Value: true}, models.AnyVersion))
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 8})
+}
+
+func testEnvironmentsInvalid(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	_, err := r.Create(models.Boolean{Value: true, Environments: models.EnvironmentValues{"qa": true}})
+	assertValidation(t, err)
+	assertValidation(t, update(r, id, models.Boolean{Value: true, Environments: models.EnvironmentValues{"Prod": true}}, models.AnyVersion))
+	_, err = r.Patch(id, environmentPatch(map[string]*bool{"qa": nil}), models.AnyVersion)
+	assertValidation(t, err)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 1})
+}
+
+func testEnvironmentsHistory(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Environments: models.EnvironmentValues{"prod": false}})
+	before := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	_, err := r.Patch(id, environmentPatch(map[string]*bool{"prod": nil, "dev": valueOf(false)}), models.AnyVersion)
+	assert.NoError(t, err)
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 2) {
+		assertEntry(t, models.HistoryUpdate,
+			&models.Boolean{ID: id, Value: true, Version: 1, Environments: models.EnvironmentValues{"prod": false}},
+			&models.Boolean{ID: id, Value: true, Version: 2, Environments: models.EnvironmentValues{"dev": false}}, entries[0])
+	}
+
+	// Earlier states and rollback have environment values of their version.
+	b, err := r.GetAt(id, before)
+	assert.NoError(t, err)
+	assert.Equal(t, models.EnvironmentValues{"prod": false}, b.Environments)
+	b, err = r.Rollback(id, 1, models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: true, Version: 3, Environments: models.EnvironmentValues{"prod": false}}, b)
+}
+
+func testPromote(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: false, Environments: models.EnvironmentValues{"dev": true}})
+
+	// Value is promoted to the next environment when none is given.
+	b, err := r.Promote(id, "dev", "", 1)
+	assert.NoError(t, err)
+	staged := models.Boolean{ID: id, Value: false, Version: 2, Environments: models.EnvironmentValues{"dev": true, "staging": true}}
+	assertBoolean(t, staged, b)
+
+	// Promotion of a value environment already has is not a change.
+	b, err = r.Promote(id, "dev", "staging", models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, staged, b)
+
+	// Environment without own value promotes own value of the boolean.
+	b, err = r.Promote(id, "prod", "dev", models.AnyVersion)
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: id, Value: false, Version: 3, Environments: models.EnvironmentValues{"dev": false, "staging": true}}, b)
+	b, err = r.Promote(id, "staging", "prod", models.AnyVersion)
+	assert.NoError(t, err)
+	promoted := models.Boolean{ID: id, Value: false, Version: 4, Environments: models.EnvironmentValues{"dev": false, "staging": true, "prod": true}}
+	assertBoolean(t, promoted, b)
+	assertStored(t, r, promoted)
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 4) {
+		assertEntry(t, models.HistoryPromote,
+			&models.Boolean{ID: id, Value: false, Version: 3, Environments: models.EnvironmentValues{"dev": false, "staging": true}},
+			&promoted, entries[0])
+	}
+}
+
+func testPromoteFailures(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	_, err := r.Promote(uuid.New(), "dev", "staging", models.AnyVersion)
+	assertNotFound(t, err)
+	_, err = r.Promote(id, "dev", "staging", 2)
+	assertVersionMismatch(t, err)
+	for _, environments := range [][2]string{{"qa", "prod"}, {"dev", "qa"}, {"prod", ""}, {"dev", "dev"}} {
+		_, err = r.Promote(id, environments[0], environments[1], models.AnyVersion)
+		assertValidation(t, err)
+	}
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 1})
+}
+
+// expiresIn returns expiry time which passes after d, and waits for it to pass.
+func expiresIn(d time.Duration) (sql.NullTime, func()) {
+	at := time.Now().Add(d)
+	return sql.NullTime{Time: at, Valid: true}, func() { time.Sleep(time.Until(at) + 10*time.Millisecond) }
+}
+
+func testExpiryFallback(t *testing.T, r models.Repo) {
+	expiresAt, wait := expiresIn(100 * time.Millisecond)
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name", ExpiresAt: expiresAt, Fallback: sql.NullBool{Bool: false, Valid: true}})
+
+	b, err := r.Get(id)
+	assert.NoError(t, err)
+	assert.True(t, b.Value)
+	assert.True(t, b.ExpiresAt.Valid)
+	assert.WithinDuration(t, expiresAt.Time, b.ExpiresAt.Time, time.Millisecond)
+	assert.Equal(t, sql.NullBool{Bool: false, Valid: true}, b.Fallback)
+	wait()
+
+	// Expiry is honoured before it is stored.
+	expected := models.Boolean{ID: id, Value: false, Key: "name", Version: 2}
+	b, err = r.Get(id)
+	assert.NoError(t, err)
+	assertState(t, expected, b)
+	assert.False(t, b.ExpiresAt.Valid)
+	assert.False(t, b.Fallback.Valid)
+	b, err = r.GetByKey("name")
+	assert.NoError(t, err)
+	assertState(t, expected, b)
+	value, other := false, true
+	assert.Equal(t, []uuid.UUID{id}, listIDs(t, r, models.ListOptions{Value: &value, Limit: 2}))
+	assert.Empty(t, listIDs(t, r, models.ListOptions{Value: &other, Limit: 2}))
+
+	expired, err := r.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+	assertStored(t, r, expected)
+	expired, err = r.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 2) {
+		assertEntry(t, models.HistoryExpire,
+			&models.Boolean{ID: id, Value: true, Key: "name", Version: 1}, &expected, entries[0])
+		assert.Equal(t, models.ExpiryActor, entries[0].Actor)
+	}
+}
+
+func testExpiryAhead(t *testing.T, r models.Repo) {
+	expiresAt := sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	reverted := mustCreate(t, r, models.Boolean{Value: true, Key: "reverted", ExpiresAt: expiresAt, Fallback: sql.NullBool{Bool: false, Valid: true}})
+	deleted := mustCreate(t, r, models.Boolean{Value: true, Key: "deleted", ExpiresAt: expiresAt})
+	kept := mustCreate(t, r, models.Boolean{Value: true, ExpiresAt: sql.NullTime{Time: time.Now().Add(3 * time.Hour), Valid: true}})
+
+	// Expiry is stored as it is at given time, even when that time has not come yet.
+	expired, err := r.Expire(time.Now().Add(2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), expired)
+
+	assertStored(t, r, models.Boolean{ID: reverted, Value: false, Key: "reverted", Version: 2})
+	_, err = r.Get(deleted)
+	assertNotFound(t, err)
+	b, err := r.Get(kept)
+	assert.NoError(t, err)
+	assert.True(t, b.ExpiresAt.Valid)
+}
+
+func testExpiryDeletes(t *testing.T, r models.Repo) {
+	expiresAt, wait := expiresIn(100 * time.Millisecond)
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name", ExpiresAt: expiresAt})
+	kept := mustCreate(t, r, models.Boolean{Value: true, ExpiresAt: sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}})
+	wait()
+
+	_, err := r.Get(id)
+	assertNotFound(t, err)
+	_, err = r.GetByKey("name")
+	assertNotFound(t, err)
+	_, err = r.Toggle(id)
+	assertNotFound(t, err)
+	booleans, err := r.GetMany([]uuid.UUID{id, kept}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, booleans, 1)
+	assert.Equal(t, []uuid.UUID{kept}, listIDs(t, r, models.ListOptions{Limit: 2}))
+
+	// Key of expired boolean is free before expiry is stored.
+	other := mustCreate(t, r, models.Boolean{Value: false, Key: "name"})
+	b, err := r.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, other, b.ID)
+
+	expired, err := r.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 2) {
+		assertEntry(t, models.HistoryExpire, &models.Boolean{ID: id, Value: true, Key: "name", Version: 1}, nil, entries[0])
+		assert.Equal(t, models.ExpiryActor, entries[0].Actor)
+	}
+
+	// Upsert creates a new boolean in place of the expired one.
+	expiresAt, wait = expiresIn(100 * time.Millisecond)
+	upserted := mustCreate(t, r, models.Boolean{Value: true, Key: "upserted", ExpiresAt: expiresAt})
+	wait()
+	b, created, err := r.UpsertByKey("upserted", models.Boolean{Value: false}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.NotEqual(t, upserted, b.ID)
+
+	expired, err = r.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+}
+
+func testExpiryChanges(t *testing.T, r models.Repo) {
+	expiresAt, wait := expiresIn(100 * time.Millisecond)
+	fallback := sql.NullBool{Bool: false, Valid: true}
+	id := mustCreate(t, r, models.Boolean{Value: true, ExpiresAt: expiresAt, Fallback: fallback})
+	wait()
+
+	// Changes apply to boolean as it is after expiry.
+	b, err := r.Toggle(id)
+	assert.NoError(t, err)
+	assertState(t, models.Boolean{ID: id, Value: true, Version: 3}, b)
+	assert.False(t, b.ExpiresAt.Valid)
+	assert.Len(t, historyOf(t, r, id), 3)
+
+	// Patch sets and removes expiry, and Update replaces it.
+	later := sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	b, err = r.Patch(id, models.BooleanPatch{ExpiresAt: &later, Fallback: &fallback}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.True(t, b.ExpiresAt.Valid)
+	assert.True(t, b.Fallback.Valid)
+	b, err = r.Patch(id, models.BooleanPatch{ExpiresAt: &sql.NullTime{}}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.False(t, b.ExpiresAt.Valid)
+	assert.True(t, b.Fallback.Valid)
+
+	assert.NoError(t, update(r, id, models.Boolean{Value: true, ExpiresAt: later}, models.AnyVersion))
+	b, err = r.Get(id)
+	assert.NoError(t, err)
+	assert.True(t, b.ExpiresAt.Valid)
+	assert.False(t, b.Fallback.Valid)
+
+	expired, err := r.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+}
+
+func testExpiryRestore(t *testing.T, r models.Repo) {
+	expiresAt, wait := expiresIn(100 * time.Millisecond)
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name", ExpiresAt: expiresAt})
+	future := sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	kept := mustCreate(t, r, models.Boolean{Value: true, ExpiresAt: future, Fallback: sql.NullBool{Bool: false, Valid: true}})
+	assert.NoError(t, r.Delete(kept, models.AnyVersion))
+	wait()
+	_, err := r.Expire(time.Now())
+	assert.NoError(t, err)
+
+	// Expiry which passed is cleared, so boolean deleted by it stays after it is restored.
+	b, err := r.Restore(id)
+	assert.NoError(t, err)
+	assert.True(t, b.Value)
+	assert.False(t, b.ExpiresAt.Valid)
+	assert.False(t, b.Fallback.Valid)
+	b, err = r.Get(id)
+	assert.NoError(t, err)
+	assert.False(t, b.ExpiresAt.Valid)
+	b, err = r.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, id, b.ID)
+	_, err = r.Restore(id)
+	assert.True(t, errors.Is(err, models.ErrNotDeleted), "expected models.ErrNotDeleted, got %v", err)
+
+	// Expiry which did not pass yet is kept along with its fallback.
+	b, err = r.Restore(kept)
+	assert.NoError(t, err)
+	assert.True(t, b.ExpiresAt.Valid)
+	assert.Equal(t, sql.NullBool{Bool: false, Valid: true}, b.Fallback)
+	b, err = r.Get(kept)
+	assert.NoError(t, err)
+	assert.True(t, b.ExpiresAt.Valid)
+}
+
+func testExpiryInvalid(t *testing.T, r models.Repo) {
+	past := sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true}
+	_, err := r.Create(models.Boolean{Value: true, ExpiresAt: past})
+	assertValidation(t, err)
+
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	_, err = r.Patch(id, models.BooleanPatch{ExpiresAt: &past}, models.AnyVersion)
+	assertValidation(t, err)
+	assertValidation(t, update(r, id, models.Boolean{Value: true, ExpiresAt: past}, models.AnyVersion))
+
+	// Fallback is given only along with expiry, or to a boolean which has one.
+	fallback := sql.NullBool{Bool: false, Valid: true}
+	_, err = r.Patch(id, models.BooleanPatch{Fallback: &fallback}, models.AnyVersion)
+	assertValidation(t, err)
+	_, err = r.Patch(id, models.BooleanPatch{ExpiresAt: &sql.NullTime{}, Fallback: &fallback}, models.AnyVersion)
+	assertValidation(t, err)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 1})
+
+	later := sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	expiring := mustCreate(t, r, models.Boolean{Value: true, ExpiresAt: later})
+	b, err := r.Patch(expiring, models.BooleanPatch{Fallback: &fallback}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, fallback, b.Fallback)
+}
+
+// mustSchedule stores schedule of value for boolean with id, which is due after d.
+func mustSchedule(t *testing.T, r models.Repo, id uuid.UUID, value bool, d time.Duration) models.Schedule {
+	s, err := r.CreateSchedule(id, models.Schedule{Value: value, ApplyAt: time.Now().Add(d)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// scheduleIDs returns ids of pending schedules of boolean with id, in order they are applied.
+func scheduleIDs(t *testing.T, r models.Repo, id uuid.UUID) []uuid.UUID {
+	schedules, err := r.Schedules(id)
+	assert.NoError(t, err)
+	ids := []uuid.UUID{}
+	for _, s := range schedules {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func testSchedules(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true, Key: "name"})
+	later := mustSchedule(t, r, id, true, time.Hour)
+	soon := mustSchedule(t, r, id, false, 100*time.Millisecond)
+	assert.Equal(t, models.SchedulePending, soon.Status)
+	assert.Equal(t, id, soon.BooleanID)
+	assert.Equal(t, []uuid.UUID{soon.ID, later.ID}, scheduleIDs(t, r, id))
+
+	applied, err := r.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, applied)
+	time.Sleep(110 * time.Millisecond)
+
+	applied, err = r.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), applied)
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "name", Version: 2})
+	assert.Equal(t, []uuid.UUID{later.ID}, scheduleIDs(t, r, id))
+
+	entries := historyOf(t, r, id)
+	if assert.Len(t, entries, 2) {
+		assertEntry(t, models.HistoryUpdate,
+			&models.Boolean{ID: id, Value: true, Key: "name", Version: 1},
+			&models.Boolean{ID: id, Value: false, Key: "name", Version: 2}, entries[0])
+		assert.Equal(t, models.ScheduleActor, entries[0].Actor)
+		assert.Equal(t, soon.ID.String(), entries[0].RequestID)
+	}
+
+	// Schedule is applied once only.
+	applied, err = r.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, applied)
+
+	assert.NoError(t, r.CancelSchedule(id, later.ID))
+	assert.Empty(t, scheduleIDs(t, r, id))
+	applied, err = r.ApplySchedules(time.Now().Add(2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, applied)
+
+	err = r.CancelSchedule(id, later.ID)
+	assert.True(t, errors.Is(err, models.ErrScheduleNotPending), "expected models.ErrScheduleNotPending, got %v", err)
+	err = r.CancelSchedule(id, soon.ID)
+	assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+}
+
+func testScheduleFailures(t *testing.T, r models.Repo) {
+	_, err := r.Schedules(uuid.New())
+	assertNotFound(t, err)
+	_, err = r.CreateSchedule(uuid.New(), models.Schedule{Value: true, ApplyAt: time.Now().Add(time.Hour)})
+	assertNotFound(t, err)
+
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	_, err = r.CreateSchedule(id, models.Schedule{Value: false, ApplyAt: time.Now().Add(-time.Second)})
+	assertValidation(t, err)
+	assert.Empty(t, scheduleIDs(t, r, id))
+
+	s := mustSchedule(t, r, id, false, time.Hour)
+	assertNotFound(t, r.CancelSchedule(id, uuid.New()))
+	assertNotFound(t, r.CancelSchedule(mustCreate(t, r, models.Boolean{}), s.ID))
+	assert.Equal(t, []uuid.UUID{s.ID}, scheduleIDs(t, r, id))
+}
+
+func testScheduleOfDeleted(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	mustSchedule(t, r, id, false, 100*time.Millisecond)
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+	time.Sleep(110 * time.Millisecond)
+
+	// Schedule which found no boolean is not applied later.
+	applied, err := r.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, applied)
+	_, err = r.Restore(id)
+	assert.NoError(t, err)
+	assert.Empty(t, scheduleIDs(t, r, id))
+	applied, err = r.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, applied)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 2})
+}
+
+// mustNamespace creates namespace with given quota and returns repo of it.
+func mustNamespace(t *testing.T, r models.Repo, name string, maxBooleans int64) models.Repo {
+	if _, _, err := r.PutNamespace(models.Namespace{Name: name, MaxBooleans: maxBooleans}); err != nil {
+		t.Fatal(err)
+	}
+	return r.InNamespace(name)
+}
+
+// assertNamespace checks that r has namespace with given quota and number of booleans.
+func assertNamespace(t *testing.T, r models.Repo, name string, maxBooleans int64, booleans int64) {
+	ns, err := r.GetNamespace(name)
+	if assert.NoError(t, err) {
+		assert.Equal(t, name, ns.Name)
+		assert.Equal(t, maxBooleans, ns.MaxBooleans)
+		assert.Equal(t, booleans, ns.Booleans)
+		assert.False(t, ns.CreatedAt.IsZero(), "expected creation time to be set")
+	}
+}
+
+func testNamespaces(t *testing.T, r models.Repo) {
+	// Default namespace exists without quota.
+	assertNamespace(t, r, models.DefaultNamespace, 0, 0)
+	mustCreate(t, r, models.Boolean{Value: true})
+
+	ns, created, err := r.PutNamespace(models.Namespace{Name: "team-a", MaxBooleans: 5})
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, int64(5), ns.MaxBooleans)
+	mustCreate(t, r.InNamespace("team-a"), models.Boolean{Value: true})
+	mustCreate(t, r.InNamespace("team-a"), models.Boolean{Value: false})
+
+	ns, created, err = r.PutNamespace(models.Namespace{Name: "team-a", MaxBooleans: 10})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, int64(10), ns.MaxBooleans)
+	assert.Equal(t, int64(2), ns.Booleans)
+	assertNamespace(t, r, "team-a", 10, 2)
+
+	mustNamespace(t, r, "b", 0)
+	namespaces, err := r.Namespaces()
+	assert.NoError(t, err)
+	names := []string{}
+	counts := map[string]int64{}
+	for _, ns := range namespaces {
+		names = append(names, ns.Name)
+		counts[ns.Name] = ns.Booleans
+	}
+	assert.Equal(t, []string{"b", models.DefaultNamespace, "team-a"}, names)
+	assert.Equal(t, map[string]int64{"b": 0, models.DefaultNamespace: 1, "team-a": 2}, counts)
+	names, err = r.InNamespace("team-a").NamespaceNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", models.DefaultNamespace, "team-a"}, names)
+
+	// Namespaces are the same through a repo of any namespace.
+	other, err := r.InNamespace("team-a").GetNamespace("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", other.Name)
+}
+
+func testNamespaceInvalid(t *testing.T, r models.Repo) {
+	for _, name := range []string{"", "Team", "team_a", "-team", "team-", "team a", strings.Repeat("a", models.MaxNamespaceLength+1)} {
+		_, _, err := r.PutNamespace(models.Namespace{Name: name})
+		assertValidation(t, err)
+	}
+	_, _, err := r.PutNamespace(models.Namespace{Name: "team", MaxBooleans: -1})
+	assertValidation(t, err)
+	_, err = r.GetNamespace("team")
+	assertNotFound(t, err)
+
+	mustNamespace(t, r, strings.Repeat("a", models.MaxNamespaceLength), 0)
+	mustNamespace(t, r, "0", 0)
+}
+
+func testNamespaceMissing(t *testing.T, r models.Repo) {
+	missing := r.InNamespace("missing")
+
+	_, err := missing.GetNamespace("missing")
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+	assertNotFound(t, err)
+
+	_, err = missing.Create(models.Boolean{Value: true})
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+	_, _, err = missing.UpsertByKey("name", models.Boolean{Value: true}, models.AnyVersion)
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+	_, err = missing.List(models.ListOptions{})
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+
+	_, err = missing.Get(uuid.New())
+	assertNotFound(t, err)
+	_, err = missing.GetByKey("name")
+	assertNotFound(t, err)
+}
+
+func testNamespaceIsolation(t *testing.T, r models.Repo) {
+	a := mustNamespace(t, r, "a", 0)
+	b := mustNamespace(t, r, "b", 0)
+	id := mustCreate(t, a, models.Boolean{Value: true, Key: "name"})
+	other := mustCreate(t, b, models.Boolean{Value: true})
+	mustSchedule(t, a, id, false, time.Hour)
+
+	// Boolean of another namespace can not be read or changed by its id.
+	_, err := b.Get(id)
+	assertNotFound(t, err)
+	booleans, err := b.GetMany([]uuid.UUID{id, other}, []string{"name"})
+	assert.NoError(t, err)
+	if assert.Len(t, booleans, 1) {
+		assert.Equal(t, other, booleans[0].ID)
+		assert.Equal(t, "b", booleans[0].Namespace)
+	}
+	assert.Equal(t, []uuid.UUID{other}, listIDs(t, b, models.ListOptions{Limit: 10}))
+	assertNotFound(t, update(b, id, models.Boolean{Value: false}, models.AnyVersion))
+	value := false
+	_, err = b.Patch(id, models.BooleanPatch{Value: &value}, models.AnyVersion)
+	assertNotFound(t, err)
+	_, err = b.Toggle(id)
+	assertNotFound(t, err)
+	_, err = b.CompareAndSwap(id, true, false)
+	assertNotFound(t, err)
+	_, err = b.Rollback(id, 1, models.AnyVersion)
+	assertNotFound(t, err)
+	_, err = b.History(id, models.HistoryOptions{})
+	assertNotFound(t, err)
+	_, err = b.GetAt(id, time.Now())
+	assertNotFound(t, err)
+	_, err = b.Schedules(id)
+	assertNotFound(t, err)
+	_, err = b.CreateSchedule(id, models.Schedule{Value: false, ApplyAt: time.Now().Add(time.Hour)})
+	assertNotFound(t, err)
+	assertNotFound(t, b.CancelSchedule(id, scheduleIDs(t, a, id)[0]))
+	assertNotFound(t, b.Delete(id, models.AnyVersion))
+	results, err := b.Bulk([]models.Operation{
+		{Type: models.OperationUpdate, ID: id, Patch: models.BooleanPatch{Value: &value}},
+		{Type: models.OperationDelete, ID: id},
+	}, false)
+	assert.NoError(t, err)
+	for _, result := range results {
+		assertNotFound(t, result.Err)
+	}
+	assertStored(t, a, models.Boolean{ID: id, Namespace: "a", Value: true, Key: "name", Version: 1})
+	assert.Len(t, scheduleIDs(t, a, id), 1)
+
+	// Nor can it be restored or purged once it is deleted.
+	assert.NoError(t, a.Delete(id, models.AnyVersion))
+	_, err = b.Restore(id)
+	assertNotFound(t, err)
+	purged, err := b.Purge(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	_, err = b.History(id, models.HistoryOptions{})
+	assertNotFound(t, err)
+	_, err = a.Restore(id)
+	assert.NoError(t, err)
+
+	// Default namespace is a namespace as any other.
+	_, err = r.Get(id)
+	assertNotFound(t, err)
+	_, err = a.Get(other)
+	assertNotFound(t, err)
+}
+
+func testNamespaceKeys(t *testing.T, r models.Repo) {
+	a := mustNamespace(t, r, "a", 0)
+	b := mustNamespace(t, r, "b", 0)
+
+	// Keys are unique only within a namespace.
+	inA := mustCreate(t, a, models.Boolean{Value: true, Key: "name"})
+	inB := mustCreate(t, b, models.Boolean{Value: false, Key: "name"})
+	inDefault, created, err := r.UpsertByKey("name", models.Boolean{Value: true}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	_, err = a.Create(models.Boolean{Value: true, Key: "name"})
+	assertDuplicateKey(t, err)
+
+	found, err := a.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, inA, found.ID)
+	found, err = b.GetByKey("name")
+	assert.NoError(t, err)
+	assert.Equal(t, inB, found.ID)
+	booleans, err := b.GetMany(nil, []string{"name"})
+	assert.NoError(t, err)
+	if assert.Len(t, booleans, 1) {
+		assert.Equal(t, inB, booleans[0].ID)
+	}
+
+	// Upsert changes boolean of its own namespace only.
+	found, created, err = b.UpsertByKey("name", models.Boolean{Value: true}, models.AnyVersion)
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, inB, found.ID)
+	assertStored(t, a, models.Boolean{ID: inA, Namespace: "a", Value: true, Key: "name", Version: 1})
+	assertStored(t, r, models.Boolean{ID: inDefault.ID, Value: true, Key: "name", Version: 1})
+
+	// Key released in one namespace is free there, and still taken in the others.
+	assert.NoError(t, a.Delete(inA, models.AnyVersion))
+	mustCreate(t, a, models.Boolean{Value: true, Key: "name"})
+	_, err = b.Create(models.Boolean{Value: true, Key: "name"})
+	assertDuplicateKey(t, err)
+}
+
+func testNamespaceMaintenance(t *testing.T, r models.Repo) {
+	a := mustNamespace(t, r, "a", 0)
+	b := mustNamespace(t, r, "b", 0)
+
+	expiresAt := sql.NullTime{Time: time.Now().Add(100 * time.Millisecond), Valid: true}
+	expiring := mustCreate(t, a, models.Boolean{Value: true, ExpiresAt: expiresAt})
+	scheduled := mustCreate(t, a, models.Boolean{Value: true})
+	mustSchedule(t, a, scheduled, false, 100*time.Millisecond)
+	deleted := mustCreate(t, a, models.Boolean{Value: true})
+	assert.NoError(t, a.Delete(deleted, models.AnyVersion))
+	time.Sleep(110 * time.Millisecond)
+
+	// Maintenance of one namespace leaves the others alone.
+	expired, err := b.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+	applied, err := b.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, applied)
+	purged, err := b.Purge(time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.Len(t, historyOf(t, a, expiring), 1)
+	assert.Len(t, scheduleIDs(t, a, scheduled), 1)
+
+	expired, err = a.Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+	applied, err = a.ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), applied)
+	// Expired boolean has no fallback, so it is purged along with the deleted one.
+	purged, err = a.Purge(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	assertStored(t, a, models.Boolean{ID: scheduled, Namespace: "a", Value: false, Version: 2})
+}
+
+func testNamespaceQuota(t *testing.T, r models.Repo) {
+	small := mustNamespace(t, r, "small", 2)
+	first := mustCreate(t, small, models.Boolean{Value: true})
+	mustCreate(t, small, models.Boolean{Value: true, Key: "second"})
+	assertNamespace(t, r, "small", 2, 2)
+
+	assertQuotaExceeded := func(err error) {
+		assert.True(t, errors.Is(err, models.ErrQuotaExceeded), "expected models.ErrQuotaExceeded, got %v", err)
+		assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+	}
+	_, err := small.Create(models.Boolean{Value: true})
+	assertQuotaExceeded(err)
+	_, _, err = small.UpsertByKey("third", models.Boolean{Value: true}, models.AnyVersion)
+	assertQuotaExceeded(err)
+	_, err = small.Bulk([]models.Operation{{Type: models.OperationCreate, Boolean: models.Boolean{Value: true}}}, true)
+	assertQuotaExceeded(err)
+
+	// Booleans which exist are changed as usual, and quota of other namespaces is their own.
+	_, _, err = small.UpsertByKey("second", models.Boolean{Value: false}, models.AnyVersion)
+	assert.NoError(t, err)
+	mustCreate(t, r, models.Boolean{Value: true})
+
+	// Deleted boolean makes room, which it needs again to be restored.
+	assert.NoError(t, small.Delete(first, models.AnyVersion))
+	assertNamespace(t, r, "small", 2, 1)
+	third := mustCreate(t, small, models.Boolean{Value: true})
+	_, err = small.Restore(first)
+	assertQuotaExceeded(err)
+
+	// Lower quota keeps booleans which exist, and higher quota makes room.
+	mustNamespace(t, r, "small", 1)
+	assertStored(t, small, models.Boolean{ID: third, Namespace: "small", Value: true, Version: 1})
+	mustNamespace(t, r, "small", 3)
+	_, err = small.Restore(first)
+	assert.NoError(t, err)
+	assertNamespace(t, r, "small", 3, 3)
+
+	// Zero is no quota at all.
+	mustNamespace(t, r, "small", 0)
+	mustCreate(t, small, models.Boolean{Value: true})
+}
+
+func testAPIKeys(t *testing.T, r models.Repo) {
+	keys, err := r.APIKeys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	created, secret, err := r.CreateAPIKey(models.APIKey{Name: "deploy", Scopes: models.Scopes{models.ScopeWrite}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.True(t, strings.HasPrefix(secret, models.APIKeyPrefix), "key %q has no prefix", secret)
+	assert.True(t, strings.HasPrefix(secret, created.Prefix), "key %q does not start with %q", secret, created.Prefix)
+	assert.NotContains(t, created.Hash, secret)
+	assert.False(t, created.Revoked())
+	other, otherSecret, err := r.CreateAPIKey(models.APIKey{Name: "reader", Scopes: models.Scopes{models.ScopeRead}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, otherSecret)
+
+	authenticated, err := r.AuthenticateAPIKey(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, authenticated.ID)
+	assert.Equal(t, "deploy", authenticated.Name)
+	assert.Equal(t, models.Scopes{models.ScopeWrite}, authenticated.Scopes)
+	_, err = r.AuthenticateAPIKey(secret + "x")
+	assert.True(t, errors.Is(err, models.ErrAPIKeyNotFound), "expected models.ErrAPIKeyNotFound, got %v", err)
+
+	// API keys are the same through a repo of any namespace.
+	keys, err = r.InNamespace("team").APIKeys()
+	assert.NoError(t, err)
+	ids := []uuid.UUID{}
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	assert.ElementsMatch(t, []uuid.UUID{created.ID, other.ID}, ids)
+}
+
+func testAPIKeyInvalid(t *testing.T, r models.Repo) {
+	for _, key := range []models.APIKey{
+		{Name: "", Scopes: models.Scopes{models.ScopeRead}},
+		{Name: strings.Repeat("a", models.MaxAPIKeyNameLength+1), Scopes: models.Scopes{models.ScopeRead}},
+		{Name: "none"},
+		{Name: "unknown", Scopes: models.Scopes{"owner"}},
+		{Name: models.AdminAPIKeyName, Scopes: models.Scopes{models.ScopeRead}},
+	} {
+		_, _, err := r.CreateAPIKey(key)
+		assertValidation(t, err)
+	}
+	keys, err := r.APIKeys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func testAPIKeyRotate(t *testing.T, r models.Repo) {
+	created, secret, err := r.CreateAPIKey(models.APIKey{Name: "deploy", Scopes: models.Scopes{models.ScopeRead, models.ScopeWrite}})
+	assert.NoError(t, err)
+
+	rotated, rotatedSecret, err := r.RotateAPIKey(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, rotated.ID)
+	assert.Equal(t, created.Name, rotated.Name)
+	assert.Equal(t, created.Scopes, rotated.Scopes)
+	assert.True(t, rotated.RotatedAt.Valid)
+	assert.NotEqual(t, secret, rotatedSecret)
+
+	// The old key stops working at once.
+	_, err = r.AuthenticateAPIKey(secret)
+	assertNotFound(t, err)
+	authenticated, err := r.AuthenticateAPIKey(rotatedSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, authenticated.ID)
+
+	_, _, err = r.RotateAPIKey(uuid.New())
+	assert.True(t, errors.Is(err, models.ErrAPIKeyNotFound), "expected models.ErrAPIKeyNotFound, got %v", err)
+}
+
+func testAPIKeyRevoke(t *testing.T, r models.Repo) {
+	created, secret, err := r.CreateAPIKey(models.APIKey{Name: "deploy", Scopes: models.Scopes{models.ScopeAdmin}})
+	assert.NoError(t, err)
+
+	revoked, err := r.RevokeAPIKey(created.ID)
+	assert.NoError(t, err)
+	assert.True(t, revoked.Revoked())
+	_, err = r.AuthenticateAPIKey(secret)
+	assertNotFound(t, err)
+
+	// Revoking again keeps time of the first revocation, and revoked key is still listed.
+	again, err := r.RevokeAPIKey(created.ID)
+	assert.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Time.Equal(again.RevokedAt.Time))
+	keys, err := r.APIKeys()
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.True(t, keys[0].Revoked())
+	}
+
+	_, _, err = r.RotateAPIKey(created.ID)
+	assert.True(t, errors.Is(err, models.ErrAPIKeyRevoked), "expected models.ErrAPIKeyRevoked, got %v", err)
+	assert.True(t, errors.Is(err, models.ErrConflict), "expected models.ErrConflict, got %v", err)
+	_, err = r.RevokeAPIKey(uuid.New())
+	assert.True(t, errors.Is(err, models.ErrAPIKeyNotFound), "expected models.ErrAPIKeyNotFound, got %v", err)
+}
+
+func testRoleBindings(t *testing.T, r models.Repo) {
+	mustNamespace(t, r, "team", 0)
+	bindings, err := r.RoleBindings("team")
+	assert.NoError(t, err)
+	assert.Empty(t, bindings)
+
+	binding, created, err := r.PutRoleBinding(models.RoleBinding{Namespace: "team", Subject: "token:alice", Role: models.RoleViewer})
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, models.RoleViewer, binding.Role)
+	_, _, err = r.PutRoleBinding(models.RoleBinding{Namespace: "team", Subject: "role:developers", Role: models.RoleEditor})
+	assert.NoError(t, err)
+	_, _, err = r.PutRoleBinding(models.RoleBinding{Namespace: models.DefaultNamespace, Subject: "token:alice", Role: models.RoleAdmin})
+	assert.NoError(t, err)
+
+	// Subject has a single role in a namespace, which putting again replaces.
+	binding, created, err = r.PutRoleBinding(models.RoleBinding{Namespace: "team", Subject: "token:alice", Role: models.RoleEditor})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, models.RoleEditor, binding.Role)
+
+	// Role bindings are the same through a repo of any namespace.
+	bindings, err = r.InNamespace("other").RoleBindings("team")
+	assert.NoError(t, err)
+	roles := map[string]models.Role{}
+	for _, b := range bindings {
+		assert.Equal(t, "team", b.Namespace)
+		roles[b.Subject] = b.Role
+	}
+	assert.Equal(t, map[string]models.Role{"role:developers": models.RoleEditor, "token:alice": models.RoleEditor}, roles)
+	if assert.Len(t, bindings, 2) {
+		assert.Equal(t, "role:developers", bindings[0].Subject)
+	}
+
+	assert.NoError(t, r.DeleteRoleBinding("team", "token:alice"))
+	err = r.DeleteRoleBinding("team", "token:alice")
+	assert.True(t, errors.Is(err, models.ErrRoleBindingNotFound), "expected models.ErrRoleBindingNotFound, got %v", err)
+	assertNotFound(t, err)
+	bindings, err = r.RoleBindings(models.DefaultNamespace)
+	assert.NoError(t, err)
+	assert.Len(t, bindings, 1)
+}
+
+func testRoleBindingInvalid(t *testing.T, r models.Repo) {
+	for _, binding := range []models.RoleBinding{
+		{Namespace: models.DefaultNamespace, Subject: "alice", Role: models.RoleViewer},
+		{Namespace: models.DefaultNamespace, Subject: "token:", Role: models.RoleViewer},
+		{Namespace: models.DefaultNamespace, Subject: "user:alice", Role: models.RoleViewer},
+		{Namespace: models.DefaultNamespace, Subject: "token:" + strings.Repeat("a", models.MaxSubjectLength), Role: models.RoleViewer},
+		{Namespace: models.DefaultNamespace, Subject: "token:alice", Role: "owner"},
+		{Namespace: models.DefaultNamespace, Subject: "token:alice"},
+	} {
+		_, _, err := r.PutRoleBinding(binding)
+		assertValidation(t, err)
+	}
+
+	_, _, err := r.PutRoleBinding(models.RoleBinding{Namespace: "missing", Subject: "token:alice", Role: models.RoleViewer})
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+	_, err = r.RoleBindings("missing")
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+	err = r.DeleteRoleBinding("missing", "token:alice")
+	assert.True(t, errors.Is(err, models.ErrNamespaceNotFound), "expected models.ErrNamespaceNotFound, got %v", err)
+}
+
+func testAccessList(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	subjects, err := r.AccessList(id)
+	assert.NoError(t, err)
+	assert.Empty(t, subjects)
+
+	// Subjects are kept once, in order of their names.
+	subjects, err = r.SetAccessList(id, []string{"token:bob", "api_key:deploy", "token:bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api_key:deploy", "token:bob"}, subjects)
+	subjects, err = r.AccessList(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api_key:deploy", "token:bob"}, subjects)
+
+	// Access list is kept across changes of the boolean, and replaced as a whole.
+	_, err = r.Toggle(id)
+	assert.NoError(t, err)
+	_, err = r.SetAccessList(id, []string{"role:oncall"})
+	assert.NoError(t, err)
+	subjects, err = r.AccessList(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"role:oncall"}, subjects)
+
+	subjects, err = r.SetAccessList(id, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, subjects)
+	subjects, err = r.AccessList(id)
+	assert.NoError(t, err)
+	assert.Empty(t, subjects)
+
+	// Booleans of other namespaces are not found.
+	mustNamespace(t, r, "team", 0)
+	_, err = r.InNamespace("team").AccessList(id)
+	assertNotFound(t, err)
+	_, err = r.InNamespace("team").SetAccessList(id, []string{"token:bob"})
+	assertNotFound(t, err)
+}
+
+func testAccessListInvalid(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	tooMany := make([]string, models.MaxAccessListLength+1)
+	for i := range tooMany {
+		tooMany[i] = "token:user-" + strconv.Itoa(i)
+	}
+	for _, subjects := range [][]string{{"bob"}, {"token:bob", "group:admins"}, tooMany} {
+		_, err := r.SetAccessList(id, subjects)
+		assertValidation(t, err)
+	}
+	subjects, err := r.AccessList(id)
+	assert.NoError(t, err)
+	assert.Empty(t, subjects)
+
+	_, err = r.AccessList(uuid.New())
+	assertNotFound(t, err)
+	_, err = r.SetAccessList(uuid.New(), []string{"token:bob"})
+	assertNotFound(t, err)
+}
+
+func testAccessListPurged(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	_, err := r.SetAccessList(id, []string{"token:bob"})
+	assert.NoError(t, err)
+
+	// Deleted boolean keeps its access list until it is purged.
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+	_, err = r.AccessList(id)
+	assertNotFound(t, err)
+	subjects, err := r.DeletedAccessList(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"token:bob"}, subjects)
+	_, err = r.InNamespace("team").DeletedAccessList(id)
+	assertNotFound(t, err)
+	_, err = r.Restore(id)
+	assert.NoError(t, err)
+	subjects, err = r.AccessList(id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"token:bob"}, subjects)
+	_, err = r.DeletedAccessList(id)
+	assertNotFound(t, err)
+	_, err = r.DeletedAccessList(uuid.New())
+	assertNotFound(t, err)
+
+	assert.NoError(t, r.Delete(id, models.AnyVersion))
+	purged, err := r.Purge(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	other := mustCreate(t, r, models.Boolean{Value: true})
+	subjects, err = r.AccessList(other)
+	assert.NoError(t, err)
+	assert.Empty(t, subjects)
+}
+
+func testConcurrentLifecycles(t *testing.T, r models.Repo) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			created, err := r.Create(models.Boolean{Value: true, Key: key})
+			if !assert.NoError(t, err) {
+				return
+			}
+			id := created.ID
+			assert.NoError(t, update(r, id, models.Boolean{Value: false, Key: key}, models.FirstVersion))
+
+			b, err := r.Get(id)
+			assert.NoError(t, err)
+			assertBoolean(t, models.Boolean{ID: id, Value: false, Key: key, Version: 2}, b)
+
+			assert.NoError(t, r.Delete(id, 2))
+			_, err = r.Get(id)
+			assertNotFound(t, err)
+		}("concurrent-" + strconv.Itoa(i))
+	}
+	wg.Wait()
+}
+
+func testConcurrentUpdates(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, update(r, id, models.Boolean{Value: false, Key: "writer"}, models.AnyVersion))
+		}()
+		go func() {
+			defer wg.Done()
+			b, err := r.Get(id)
+			assert.NoError(t, err)
+			assert.Equal(t, id, b.ID)
+		}()
+	}
+	wg.Wait()
+
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "writer", Version: 1 + concurrency})
+}
+
+func testConcurrentVersionedUpdates(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	// All writers expect the first version, so exactly one of them wins.
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := update(r, id, models.Boolean{Value: false, Key: "writer"}, models.FirstVersion)
+			if err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+				return
+			}
+			assertVersionMismatch(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, succeeded)
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Key: "writer", Version: 2})
+}
+
+func testConcurrentToggles(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+
+	// Even number of toggles must leave the value unchanged, unless some of them are lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 2*concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.Toggle(id)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 1 + 2*concurrency})
+}
+
+func testConcurrentCompareAndSwaps(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: false})
+
+	// Boolean is used as a lock, so exactly one of the goroutines acquires it.
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	acquired := 0
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := r.CompareAndSwap(id, false, true)
+			if err == nil {
+				mutex.Lock()
+				acquired++
+				mutex.Unlock()
+				return
+			}
+			assert.True(t, errors.Is(err, models.ErrValueMismatch), "expected models.ErrValueMismatch, got %v", err)
+			assert.Equal(t, true, b.Value)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, acquired)
+	assertStored(t, r, models.Boolean{ID: id, Value: true, Version: 2})
+}
+
+func testConcurrentUpsertsByKey(t *testing.T, r models.Repo) {
+	// Key is new, so exactly one upsert creates the boolean and the others update it.
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	created := 0
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, c, err := r.UpsertByKey("concurrent", models.Boolean{Value: true}, models.AnyVersion)
+			if assert.NoError(t, err) && c {
+				mutex.Lock()
+				created++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, created)
+	b, err := r.GetByKey("concurrent")
+	assert.NoError(t, err)
+	assertBoolean(t, models.Boolean{ID: b.ID, Value: true, Key: "concurrent", Version: concurrency}, b)
+}
+
+func testConcurrentCreatesWithinQuota(t *testing.T, r models.Repo) {
+	limited := mustNamespace(t, r, "limited", 3)
+
+	// Concurrent creates never go over the quota together.
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	created := 0
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := limited.Create(models.Boolean{Value: true})
+			if err == nil {
+				mutex.Lock()
+				created++
+				mutex.Unlock()
+				return
+			}
+			assert.True(t, errors.Is(err, models.ErrQuotaExceeded), "expected models.ErrQuotaExceeded, got %v", err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, created)
+	assertNamespace(t, r, "limited", 3, 3)
+}
+
+func testConcurrentScheduleApplies(t *testing.T, r models.Repo) {
+	id := mustCreate(t, r, models.Boolean{Value: true})
+	mustSchedule(t, r, id, false, 100*time.Millisecond)
+	time.Sleep(110 * time.Millisecond)
+
+	// Every schedule is applied by exactly one of concurrent calls.
+	var wg sync.WaitGroup
+	applied := make(chan int64, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := r.ApplySchedules(time.Now())
+			assert.NoError(t, err)
+			applied <- n
+		}()
+	}
+	wg.Wait()
+	close(applied)
+
+	var total int64
+	for n := range applied {
+		total += n
+	}
+	assert.Equal(t, int64(1), total)
+	assertStored(t, r, models.Boolean{ID: id, Value: false, Version: 2})
+	assert.Len(t, historyOf(t, r, id), 2)
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hrishi32/boolean-as-service/database"
+	"gorm.io/gorm"
+)
+
+// ScheduleActor is actor recorded in history for changes made by schedules.
+// Request id of such a change is id of the schedule which made it.
+const ScheduleActor = "scheduler"
+
+// ScheduleStatus tells whether a scheduled change is still waiting for its time.
+type ScheduleStatus string
+
+// Statuses of a schedule. Only pending schedules are applied, every other status is final.
+const (
+	SchedulePending  ScheduleStatus = "pending"
+	ScheduleApplied  ScheduleStatus = "applied"
+	ScheduleCanceled ScheduleStatus = "canceled"
+	// ScheduleSkipped is a schedule whose boolean did not exist when its time came.
+	ScheduleSkipped ScheduleStatus = "skipped"
+)
+
+// Schedule is a change of value of a boolean which is applied once ApplyAt passes.
+// It is stored along with booleans, so it is applied exactly once, even when the service restarts.
+// It is in namespace of the boolean, and only applied along with other schedules of the namespace.
+type Schedule struct {
+	ID        uuid.UUID      `gorm:"primaryKey"`
+	Namespace string         `gorm:"size:63;not null;default:default"`
+	BooleanID uuid.UUID      `gorm:"not null;index:idx_schedules_boolean_id_apply_at,priority:1"`
+	Value     bool           `gorm:"not null"`
+	ApplyAt   time.Time      `gorm:"not null;index:idx_schedules_boolean_id_apply_at,priority:2;index:idx_schedules_status_apply_at,priority:2"`
+	Status    ScheduleStatus `gorm:"size:16;not null;index:idx_schedules_status_apply_at,priority:1"`
+	CreatedAt time.Time
+	AppliedAt sql.NullTime
+}
+
+// audit records changes made by the schedule.
+func (s Schedule) audit() Audit {
+	return Audit{Actor: ScheduleActor, RequestID: s.ID.String()}
+}
+
+// prepareSchedule validates new schedule for boolean of namespace with id, and returns it ready to be stored.
+func prepareSchedule(namespace string, id uuid.UUID, s Schedule) (Schedule, error) {
+	if !s.ApplyAt.After(time.Now()) {
+		return Schedule{}, fmt.Errorf("%w: schedule time must be in the future", ErrValidation)
+	}
+
+	s.ID = uuid.New()
+	s.Namespace = namespace
+	s.BooleanID = id
+	s.ApplyAt = s.ApplyAt.UTC().Truncate(time.Microsecond)
+	s.Status = SchedulePending
+	s.CreatedAt = now()
+	s.AppliedAt = sql.NullTime{}
+	return s, nil
+}
+
+// Schedules returns pending schedules of boolean with id from database, in order they are applied.
+func (r *RepoImplement) Schedules(id uuid.UUID) ([]Schedule, error) {
+	if _, err := r.Get(id); err != nil {
+		return nil, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return nil, connectionError(err)
+	}
+
+	schedules := []Schedule{}
+	err = db.Where("namespace = ? AND boolean_id = ? AND status = ?", r.namespaceName(), id, SchedulePending).
+		Order("apply_at").Order("created_at").Order("id").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, storageError(err)
+	}
+	return schedules, nil
+}
+
+// CreateSchedule stores a new pending schedule for boolean with id, and returns it with newly assigned id.
+func (r *RepoImplement) CreateSchedule(id uuid.UUID, s Schedule) (Schedule, error) {
+	s, err := prepareSchedule(r.namespaceName(), id, s)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	db, err := database.GetConnection()
+	if err != nil {
+		return Schedule{}, connectionError(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Boolean stays locked, so it is not deleted before the schedule is stored.
+		if _, err := lockBoolean(tx, s.Namespace, id, AnyVersion); err != nil {
+			return err
+		}
+		return tx.Create(&s).Error
+	})
+	if err != nil {
+		return Schedule{}, storageError(err)
+	}
+	return s, nil
+}
+
+// CancelSchedule cancels pending schedule of boolean with id, and returns ErrScheduleNotPending
+// when it was already applied or canceled.
+func (r *RepoImplement) CancelSchedule(id uuid.UUID, scheduleID uuid.UUID) error {
+	db, err := database.GetConnection()
+	if err != nil {
+		return connectionError(err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var s Schedule
+		if err := lockedQuery(tx).Where("namespace = ? AND boolean_id = ?", r.namespaceName(), id).First(&s, scheduleID).Error; err != nil {
+			return err
+		}
+		if s.Status != SchedulePending {
+			return ErrScheduleNotPending
+		}
+		return tx.Model(&Schedule{}).Where("id = ?", scheduleID).Update("status", ScheduleCanceled).Error
+	})
+	return storageError(err)
+}
+
+// ApplySchedules applies pending schedules of the namespace whose time passed by given time, each in its own
+// transaction and in order of their time, and returns how many were applied.
+func (r *RepoImplement) ApplySchedules(at time.Time) (int64, error) {
+	db, err := database.GetConnection()
+	if err != nil {
+		return 0, connectionError(err)
+	}
+
+	var due []Schedule
+	err = db.Where("namespace = ? AND status = ? AND apply_at <= ?", r.namespaceName(), SchedulePending, at.UTC()).
+		Order("apply_at").Order("created_at").Order("id").
+		Find(&due).Error
+	if err != nil {
+		return 0, storageError(err)
+	}
+
+	var applied int64
+	for _, s := range due {
+		changed := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Status is changed first, so a schedule which another server applies or cancels meanwhile
+			// is left alone, and the schedule is applied along with the change or not at all.
+			result := tx.Model(&Schedule{}).Where("id = ? AND status = ?", s.ID, SchedulePending).Updates(map[string]interface{}{
+				"status":     ScheduleApplied,
+				"applied_at": now(),
+			})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			old, err := lockBoolean(tx, s.Namespace, s.BooleanID, AnyVersion)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return tx.Model(&Schedule{}).Where("id = ?", s.ID).Update("status", ScheduleSkipped).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			changed = true
+			if old.Value == s.Value {
+				return nil
+			}
+			updated := old
+			updated.Value = s.Value
+			_, err = changeBoolean(tx, s.audit(), HistoryUpdate, old, updated)
+			return err
+		})
+		if err != nil {
+			return applied, storageError(err)
+		}
+		if changed {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// Schedules returns pending schedules of boolean with id from memory, in order they are applied.
+func (r *MemoryRepo) Schedules(id uuid.UUID) ([]Schedule, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if _, ok := r.visible(id); !ok {
+		return nil, ErrNotFound
+	}
+
+	schedules := []Schedule{}
+	for _, s := range r.schedules {
+		if s.BooleanID == id && s.Status == SchedulePending {
+			schedules = append(schedules, s)
+		}
+	}
+	sortSchedules(schedules)
+	return schedules, nil
+}
+
+// CreateSchedule stores a new pending schedule for boolean with id, and returns it with newly assigned id.
+func (r *MemoryRepo) CreateSchedule(id uuid.UUID, s Schedule) (Schedule, error) {
+	s, err := prepareSchedule(r.namespaceName(), id, s)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, err := r.lookup(id, AnyVersion); err != nil {
+		return Schedule{}, err
+	}
+	r.schedules[s.ID] = s
+	return s, nil
+}
+
+// CancelSchedule cancels pending schedule of boolean with id, and returns ErrScheduleNotPending
+// when it was already applied or canceled.
+func (r *MemoryRepo) CancelSchedule(id uuid.UUID, scheduleID uuid.UUID) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s, ok := r.schedules[scheduleID]
+	if !ok || s.Namespace != r.namespaceName() || s.BooleanID != id {
+		return ErrNotFound
+	}
+	if s.Status != SchedulePending {
+		return ErrScheduleNotPending
+	}
+	s.Status = ScheduleCanceled
+	r.schedules[scheduleID] = s
+	return nil
+}
+
+// ApplySchedules applies pending schedules of the namespace whose time passed by given time, in order
+// of their time, and returns how many were applied.
+func (r *MemoryRepo) ApplySchedules(at time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	due := []Schedule{}
+	for _, s := range r.schedules {
+		if s.Namespace == r.namespaceName() && s.Status == SchedulePending && !s.ApplyAt.After(at) {
+			due = append(due, s)
+		}
+	}
+	sortSchedules(due)
+
+	var applied int64
+	for _, s := range due {
+		s.Status = ScheduleApplied
+		s.AppliedAt = sql.NullTime{Time: now(), Valid: true}
+
+		old, err := r.lookup(s.BooleanID, AnyVersion)
+		if err != nil {
+			s.Status = ScheduleSkipped
+			r.schedules[s.ID] = s
+			continue
+		}
+		r.schedules[s.ID] = s
+		applied++
+		if old.Value == s.Value {
+			continue
+		}
+
+		b := old
+		b.Value = s.Value
+		scheduler := r.withAudit(s.audit())
+		scheduler.change(HistoryUpdate, old, b)
+	}
+
+	return applied, nil
+}
+
+// sortSchedules sorts schedules in order they are applied.
+func sortSchedules(schedules []Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		a, b := schedules[i], schedules[j]
+		if !a.ApplyAt.Equal(b.ApplyAt) {
+			return a.ApplyAt.Before(b.ApplyAt)
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+}
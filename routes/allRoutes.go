@@ -3,21 +3,111 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/hrishi32/boolean-as-service/controller"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// Middlewares which let through only requests whose principal may take an action in namespace of the request.
+var (
+	read  = controller.Authorize(controller.ActionRead)
+	write = controller.Authorize(controller.ActionWrite)
+	admin = controller.Authorize(controller.ActionAdmin)
+)
+
+// Middlewares which let through only requests whose principal has a scope, for routes of the whole service.
+var (
+	readScope  = controller.RequireScope(models.ScopeRead)
+	adminScope = controller.RequireScope(models.ScopeAdmin)
 )
 
 // Init function sets all routes to the server.
+// Booleans of the default namespace are served at the root, and booleans of every namespace under /ns/:namespace.
+// Every request needs an API key or a token, whose scopes or roles in the namespace allow the route.
 func Init(server *gin.Engine) {
 
-	server.GET("/:id", controller.GetHandler)
+	server.Use(controller.RequestID(), controller.Authenticate())
+
+	booleanRoutes(server)
+
+	booleanRoutes(server.Group("/ns/:namespace", controller.Namespace()))
+
+	server.GET("/namespaces", readScope, controller.ListNamespacesHandler)
+
+	server.GET("/namespaces/:namespace", controller.Namespace(), read, controller.GetNamespaceHandler)
+
+	server.PUT("/namespaces/:namespace", adminScope, controller.Namespace(), controller.PutNamespaceHandler)
+
+	server.GET("/namespaces/:namespace/roles", controller.Namespace(), admin, controller.RoleBindingsHandler)
+
+	server.PUT("/namespaces/:namespace/roles", controller.Namespace(), admin, controller.PutRoleBindingHandler)
+
+	server.DELETE("/namespaces/:namespace/roles", controller.Namespace(), admin, controller.DeleteRoleBindingHandler)
+
+	server.POST("/policy/evaluate", controller.EvaluatePolicyHandler)
+
+	server.GET("/api-keys", adminScope, controller.ListAPIKeysHandler)
+
+	server.POST("/api-keys", adminScope, controller.CreateAPIKeyHandler)
+
+	server.POST("/api-keys/:id/rotate", adminScope, controller.RotateAPIKeyHandler)
+
+	server.DELETE("/api-keys/:id", adminScope, controller.RevokeAPIKeyHandler)
+
+	server.NoRoute(controller.HandleNoRoute)
+
+}
+
+// booleanRoutes sets routes of booleans to router, which serves booleans of a single namespace.
+// Reading needs viewer role, changing needs editor role, and purge and access lists need admin role.
+func booleanRoutes(router gin.IRoutes) {
+
+	router.GET("/", read, controller.ListHandler)
+
+	router.GET("/:id", read, controller.GetHandler)
+
+	router.POST("/", write, controller.PostHandler)
+
+	router.POST("/bulk", write, controller.BulkHandler)
+
+	router.POST("/batch-get", read, controller.BatchGetHandler)
+
+	router.POST("/purge", admin, controller.PurgeHandler)
+
+	router.PATCH("/:id", write, controller.PatchHandler)
+
+	router.PUT("/:id", write, controller.PutHandler)
+
+	router.DELETE("/:id", write, controller.DeleteHandler)
+
+	router.POST("/:id/toggle", write, controller.ToggleHandler)
+
+	router.POST("/:id/cas", write, controller.CompareAndSwapHandler)
+
+	router.GET("/:id/history", read, controller.HistoryHandler)
+
+	router.POST("/:id/rollback", write, controller.RollbackHandler)
+
+	router.GET("/:id/environments", read, controller.CompareEnvironmentsHandler)
+
+	router.GET("/:id/environments/:environment", read, controller.GetHandler)
+
+	router.POST("/:id/promote", write, controller.PromoteHandler)
+
+	router.POST("/:id/restore", write, controller.RestoreHandler)
+
+	router.GET("/:id/schedules", read, controller.SchedulesHandler)
+
+	router.POST("/:id/schedules", write, controller.CreateScheduleHandler)
+
+	router.DELETE("/:id/schedules/:schedule_id", write, controller.CancelScheduleHandler)
+
+	router.GET("/:id/acl", read, controller.AccessListHandler)
 
-	server.POST("/", controller.PostHandler)
+	router.PUT("/:id/acl", admin, controller.PutAccessListHandler)
 
-	server.PATCH("/:id", controller.PatchHandler)
+	router.GET("/keys/:key", read, controller.GetByKeyHandler)
 
-	server.DELETE("/:id", controller.DeleteHandler)
+	router.PUT("/keys/:key", write, controller.PutByKeyHandler)
 
-	server.NoRoute(func(c *gin.Context) {
-		c.JSON(404, gin.H{"code": "PAGE_NOT_FOUND", "message": "Page not found"})
-	})
+	router.DELETE("/keys/:key", write, controller.DeleteByKeyHandler)
 
 }
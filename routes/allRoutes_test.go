@@ -0,0 +1,1261 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hrishi32/boolean-as-service/auth"
+	"github.com/hrishi32/boolean-as-service/auth/authtest"
+	"github.com/hrishi32/boolean-as-service/controller"
+	"github.com/hrishi32/boolean-as-service/models"
+)
+
+// testKey is API key with admin scope, which serve gives with every request.
+var testKey string
+
+// newTestServer returns server with all routes backed by an empty in-memory repo, which has testKey.
+func newTestServer() *gin.Engine {
+	repo := models.NewMemoryRepo()
+	models.SetRepo(repo)
+	_, testKey, _ = repo.CreateAPIKey(models.APIKey{Name: "test", Scopes: models.Scopes{models.ScopeAdmin}})
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	Init(server)
+	return server
+}
+
+// serve makes a request with testKey to server and returns recorded response.
+func serve(t *testing.T, server *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(controller.APIKeyHeader, testKey)
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+// serveWithHeader makes a request with testKey and a single header to server and returns recorded response.
+func serveWithHeader(t *testing.T, server *gin.Engine, method string, path string, body string, header string, value string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(controller.APIKeyHeader, testKey)
+	request.Header.Set(header, value)
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+// serveWithAuthorization makes a request with Authorization header instead of testKey to server,
+// and returns recorded response.
+func serveWithAuthorization(t *testing.T, server *gin.Engine, method string, path string, body string, authorization string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", authorization)
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+// decodeBoolean parses boolean from response body.
+func decodeBoolean(t *testing.T, response *httptest.ResponseRecorder) models.Boolean {
+	b := models.Boolean{}
+	if err := json.Unmarshal(response.Body.Bytes(), &b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// decodeError parses error body from response.
+func decodeError(t *testing.T, response *httptest.ResponseRecorder) controller.ErrorResponse {
+	errorResponse := controller.ErrorResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &errorResponse); err != nil {
+		t.Fatal(err)
+	}
+	return errorResponse
+}
+
+func TestEndToEnd(t *testing.T) {
+	server := newTestServer()
+
+	// Create
+	response := serve(t, server, http.MethodPost, "/", `{"value": true, "key": "name"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	created := decodeBoolean(t, response)
+	assert.Equal(t, true, created.Value)
+	assert.Equal(t, "name", created.Key)
+
+	// Get
+	response = serve(t, server, http.MethodGet, "/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, created, decodeBoolean(t, response))
+
+	// Update
+	response = serve(t, server, http.MethodPatch, "/"+created.ID.String(), `{"value": false, "key": "new name"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = serve(t, server, http.MethodGet, "/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: created.ID, Value: false, Key: "new name"}, decodeBoolean(t, response))
+
+	// Delete
+	response = serve(t, server, http.MethodDelete, "/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+
+	response = serve(t, server, http.MethodGet, "/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	response = serve(t, server, http.MethodDelete, "/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestPartialUpdates(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/", `{"value": true, "key": "name"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	id := decodeBoolean(t, response).ID
+
+	// Merge patch changes only given fields
+	response = serve(t, server, http.MethodPatch, "/"+id.String(), `{"value": false}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: id, Value: false, Key: "name"}, decodeBoolean(t, response))
+
+	// Null removes key in merge patch
+	response = serve(t, server, http.MethodPatch, "/"+id.String(), `{"key": null}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: id, Value: false}, decodeBoolean(t, response))
+
+	// JSON Patch
+	request, err := http.NewRequest(http.MethodPatch, "/"+id.String(), strings.NewReader(`[
+		{"op": "test", "path": "/value", "value": false},
+		{"op": "replace", "path": "/value", "value": true},
+		{"op": "add", "path": "/key", "value": "patched"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(controller.APIKeyHeader, testKey)
+	request.Header.Set("Content-Type", "application/json-patch+json")
+	response = httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: id, Value: true, Key: "patched"}, decodeBoolean(t, response))
+
+	// PUT replaces the whole boolean
+	response = serve(t, server, http.MethodPut, "/"+id.String(), `{"value": false}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = serve(t, server, http.MethodGet, "/"+id.String(), "")
+	assert.Equal(t, models.Boolean{ID: id, Value: false}, decodeBoolean(t, response))
+}
+
+func TestVersions(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/", `{"value": true, "key": "name"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"1"`, response.Header().Get("ETag"))
+	path := "/" + decodeBoolean(t, response).ID.String()
+
+	// Conditional reads
+	response = serveWithHeader(t, server, http.MethodGet, path, "", "If-None-Match", `W/"1"`)
+	assert.Equal(t, http.StatusNotModified, response.Code)
+	assert.Empty(t, response.Body.String())
+
+	response = serveWithHeader(t, server, http.MethodGet, path, "", "If-None-Match", `"7"`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"1"`, response.Header().Get("ETag"))
+
+	response = serveWithHeader(t, server, http.MethodGet, path, "", "If-Match", `"7"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	// Conditional writes
+	response = serveWithHeader(t, server, http.MethodPatch, path, `{"value": false}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"2"`, response.Header().Get("ETag"))
+
+	response = serveWithHeader(t, server, http.MethodPatch, path, `{"value": true}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+	assert.Equal(t, "PRECONDITION_FAILED", decodeError(t, response).Code)
+
+	response = serveWithHeader(t, server, http.MethodPut, path, `{"value": true}`, "If-Match", `W/"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	response = serve(t, server, http.MethodPost, path+"/toggle", "")
+	assert.Equal(t, `"3"`, response.Header().Get("ETag"))
+
+	response = serveWithHeader(t, server, http.MethodDelete, path, "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	response = serveWithHeader(t, server, http.MethodDelete, path, "", "If-Match", `"1", "3"`)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/", `{"value": false, "key": "lock"}`)
+	id := decodeBoolean(t, response).ID
+	path := "/" + id.String() + "/cas"
+
+	response = serve(t, server, http.MethodPost, path, `{"expected": false, "new": true}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: id, Value: true, Key: "lock"}, decodeBoolean(t, response))
+
+	response = serve(t, server, http.MethodPost, path, `{"expected": false, "new": true}`)
+	assert.Equal(t, http.StatusConflict, response.Code)
+	errorResponse := decodeError(t, response)
+	assert.Equal(t, "VALUE_MISMATCH", errorResponse.Code)
+	assert.Equal(t, true, errorResponse.Current["value"])
+	assert.Equal(t, id.String(), errorResponse.Current["id"])
+
+	response = serve(t, server, http.MethodPost, path, `{"expected": true}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Equal(t, []controller.FieldError{{Field: "new", Message: "failed on required rule"}}, decodeError(t, response).Details)
+}
+
+// listResponse is body of list response.
+type listResponse struct {
+	Booleans   []models.Boolean `json:"booleans"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+// decodeList parses list response body.
+func decodeList(t *testing.T, response *httptest.ResponseRecorder) listResponse {
+	list := listResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestList(t *testing.T) {
+	server := newTestServer()
+
+	for _, key := range []string{"feature.b", "other", "feature.a"} {
+		response := serve(t, server, http.MethodPost, "/", `{"value": true, "key": "`+key+`"}`)
+		assert.Equal(t, http.StatusOK, response.Code)
+	}
+
+	response := serve(t, server, http.MethodGet, "/?key_prefix=feature.&sort=key&limit=1", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	list := decodeList(t, response)
+	if assert.Len(t, list.Booleans, 1) {
+		assert.Equal(t, "feature.a", list.Booleans[0].Key)
+	}
+	assert.NotEmpty(t, list.NextCursor)
+
+	response = serve(t, server, http.MethodGet, "/?key_prefix=feature.&sort=key&limit=1&cursor="+list.NextCursor, "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	list = decodeList(t, response)
+	if assert.Len(t, list.Booleans, 1) {
+		assert.Equal(t, "feature.b", list.Booleans[0].Key)
+	}
+	assert.Empty(t, list.NextCursor)
+
+	response = serve(t, server, http.MethodGet, "/?value=false", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `{"booleans":[]}`, response.Body.String())
+
+	// Cursor belongs to listing by key.
+	response = serve(t, server, http.MethodGet, "/?sort=-key&cursor="+decodeList(t, serve(t, server, http.MethodGet, "/?sort=key&limit=1", "")).NextCursor, "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Equal(t, []controller.FieldError{{Field: "cursor", Message: "does not match sort"}}, decodeError(t, response).Details)
+}
+
+func TestKeys(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPut, "/keys/feature", `{"value": true}`)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	created := decodeBoolean(t, response)
+	assert.Equal(t, models.Boolean{ID: created.ID, Value: true, Key: "feature"}, created)
+	assert.Equal(t, `"1"`, response.Header().Get("ETag"))
+
+	response = serveWithHeader(t, server, http.MethodPut, "/keys/feature", `{"value": false}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: created.ID, Value: false, Key: "feature"}, decodeBoolean(t, response))
+
+	response = serve(t, server, http.MethodGet, "/keys/feature", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.Boolean{ID: created.ID, Value: false, Key: "feature"}, decodeBoolean(t, response))
+	assert.Equal(t, `"2"`, response.Header().Get("ETag"))
+
+	// Key belongs to a single boolean.
+	response = serve(t, server, http.MethodPost, "/", `{"value": true, "key": "feature"}`)
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Equal(t, "DUPLICATE_KEY", decodeError(t, response).Code)
+
+	response = serveWithHeader(t, server, http.MethodDelete, "/keys/feature", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	response = serve(t, server, http.MethodDelete, "/keys/feature", "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+
+	response = serve(t, server, http.MethodGet, "/keys/feature", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	response = serve(t, server, http.MethodGet, "/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+// bulkResult is result of a single operation in bulk response.
+type bulkResult struct {
+	Status int                       `json:"status"`
+	ID     uuid.UUID                 `json:"id"`
+	Value  bool                      `json:"value"`
+	Key    string                    `json:"key"`
+	Error  *controller.ErrorResponse `json:"error"`
+}
+
+// decodeBulk parses results of bulk response.
+func decodeBulk(t *testing.T, response *httptest.ResponseRecorder) []bulkResult {
+	body := struct {
+		Results []bulkResult `json:"results"`
+	}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Results
+}
+
+func TestBulk(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/bulk", `{"operations": [
+		{"op": "create", "value": true, "key": "first"},
+		{"op": "create", "value": false, "key": "second"}
+	]}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	results := decodeBulk(t, response)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, "first", results[0].Key)
+	first, second := results[0].ID.String(), results[1].ID.String()
+
+	// Missing boolean fails the whole batch.
+	response = serve(t, server, http.MethodPost, "/bulk", `{"mode": "atomic", "operations": [
+		{"op": "update", "id": "`+first+`", "value": false},
+		{"op": "delete", "id": "`+uuid.New().String()+`"}
+	]}`)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, []controller.FieldError{{Field: "operations[1]", Message: "Boolean not found"}}, decodeError(t, response).Details)
+	assert.Equal(t, true, decodeBoolean(t, serve(t, server, http.MethodGet, "/"+first, "")).Value)
+
+	response = serve(t, server, http.MethodPost, "/bulk", `{"mode": "best_effort", "operations": [
+		{"op": "update", "id": "`+first+`", "value": false},
+		{"op": "delete", "id": "`+uuid.New().String()+`"},
+		{"op": "update", "id": "`+second+`", "key": "first"},
+		{"op": "delete", "id": "`+second+`", "version": 1}
+	]}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	results = decodeBulk(t, response)
+	if !assert.Len(t, results, 4) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, results[0].Status)
+	assert.Equal(t, false, results[0].Value)
+	assert.Equal(t, http.StatusNotFound, results[1].Status)
+	assert.Equal(t, "NOT_FOUND", results[1].Error.Code)
+	assert.Equal(t, http.StatusConflict, results[2].Status)
+	assert.Equal(t, "DUPLICATE_KEY", results[2].Error.Code)
+	assert.Equal(t, http.StatusNoContent, results[3].Status)
+
+	assert.Equal(t, false, decodeBoolean(t, serve(t, server, http.MethodGet, "/"+first, "")).Value)
+	assert.Equal(t, http.StatusNotFound, serve(t, server, http.MethodGet, "/"+second, "").Code)
+}
+
+func TestBatchGet(t *testing.T) {
+	server := newTestServer()
+
+	first := decodeBoolean(t, serve(t, server, http.MethodPost, "/", `{"value": true, "key": "first"}`))
+	second := decodeBoolean(t, serve(t, server, http.MethodPost, "/", `{"value": false, "key": "second"}`))
+	missing := uuid.New()
+
+	response := serve(t, server, http.MethodPost, "/batch-get", `{
+		"ids": ["`+first.ID.String()+`", "`+missing.String()+`"],
+		"keys": ["second", "missing"]
+	}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	results := decodeBulk(t, response)
+	if !assert.Len(t, results, 4) {
+		return
+	}
+
+	assert.Equal(t, bulkResult{Status: http.StatusOK, ID: first.ID, Value: true, Key: "first"}, results[0])
+	assert.Equal(t, http.StatusNotFound, results[1].Status)
+	assert.Equal(t, missing, results[1].ID)
+	assert.Equal(t, "NOT_FOUND", results[1].Error.Code)
+	assert.Equal(t, bulkResult{Status: http.StatusOK, ID: second.ID, Value: false, Key: "second"}, results[2])
+	assert.Equal(t, http.StatusNotFound, results[3].Status)
+	assert.Equal(t, "missing", results[3].Key)
+
+	response = serve(t, server, http.MethodPost, "/batch-get", `{"ids": ["not-uuid"]}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Equal(t, []controller.FieldError{{Field: "ids[0]", Message: "must be a valid uuid"}}, decodeError(t, response).Details)
+}
+
+// historyEntry is a single entry of history response.
+type historyEntry struct {
+	Version   int64   `json:"version"`
+	Action    string  `json:"action"`
+	OldValue  *bool   `json:"old_value"`
+	NewValue  *bool   `json:"new_value"`
+	OldKey    *string `json:"old_key"`
+	NewKey    *string `json:"new_key"`
+	Actor     string  `json:"actor"`
+	Principal string  `json:"principal"`
+	RequestID string  `json:"request_id"`
+}
+
+// historyResponse is body of history response.
+type historyResponse struct {
+	History    []historyEntry `json:"history"`
+	NextCursor string         `json:"next_cursor"`
+}
+
+func TestHistory(t *testing.T) {
+	server := newTestServer()
+
+	response := serveWithHeader(t, server, http.MethodPost, "/", `{"value": true, "key": "name"}`, controller.ActorHeader, "alice")
+	assert.Equal(t, http.StatusOK, response.Code)
+	b := decodeBoolean(t, response)
+	path := "/" + b.ID.String()
+
+	response = serveWithHeader(t, server, http.MethodPost, path+"/toggle", "", controller.RequestIDHeader, "toggle-request")
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serve(t, server, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+
+	// History is kept after delete, newest first.
+	response = serve(t, server, http.MethodGet, path+"/history?limit=2", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	history := historyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, history.History, 2) {
+		assert.Equal(t, "delete", history.History[0].Action)
+		assert.Equal(t, int64(2), history.History[0].Version)
+		assert.Nil(t, history.History[0].NewValue)
+		assert.Equal(t, "anonymous", history.History[0].Actor)
+		assert.Equal(t, "api_key:test", history.History[0].Principal)
+
+		toggled := history.History[1]
+		assert.Equal(t, "update", toggled.Action)
+		assert.Equal(t, true, *toggled.OldValue)
+		assert.Equal(t, false, *toggled.NewValue)
+		assert.Equal(t, "toggle-request", toggled.RequestID)
+	}
+	assert.NotEmpty(t, history.NextCursor)
+
+	response = serve(t, server, http.MethodGet, path+"/history?limit=2&cursor="+history.NextCursor, "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	history = historyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, history.History, 1) {
+		created := history.History[0]
+		assert.Equal(t, "create", created.Action)
+		assert.Nil(t, created.OldValue)
+		assert.Equal(t, "name", *created.NewKey)
+		assert.Equal(t, "alice", created.Actor)
+	}
+	assert.Empty(t, history.NextCursor)
+
+	response = serve(t, server, http.MethodGet, "/"+uuid.New().String()+"/history", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestRollback(t *testing.T) {
+	server := newTestServer()
+
+	b := decodeBoolean(t, serve(t, server, http.MethodPost, "/", `{"value": true, "key": "name"}`))
+	path := "/" + b.ID.String()
+	time.Sleep(2 * time.Millisecond)
+	created := time.Now()
+	time.Sleep(2 * time.Millisecond)
+
+	response := serve(t, server, http.MethodPatch, path, `{"value": false, "key": "renamed"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = serve(t, server, http.MethodGet, path+"?at="+created.Format(time.RFC3339Nano), "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+b.ID.String()+`", "value": true, "key": "name", "version": 1}`, response.Body.String())
+
+	response = serveWithHeader(t, server, http.MethodPost, path+"/rollback", `{"version": 1}`, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"3"`, response.Header().Get("ETag"))
+	rolledBack := decodeBoolean(t, response)
+	assert.Equal(t, models.Boolean{ID: b.ID, Value: true, Key: "name", CreatedAt: rolledBack.CreatedAt}, rolledBack)
+
+	response = serve(t, server, http.MethodGet, path+"/history?limit=1", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	history := historyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, history.History, 1) {
+		assert.Equal(t, "rollback", history.History[0].Action)
+	}
+
+	response = serve(t, server, http.MethodPost, path+"/rollback", `{"version": 9}`)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, "VERSION_NOT_FOUND", decodeError(t, response).Code)
+
+	response = serve(t, server, http.MethodPost, path+"/rollback", `{"version": 0}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Equal(t, []controller.FieldError{{Field: "version", Message: "must be at least 1"}}, decodeError(t, response).Details)
+}
+
+func TestRestoreAndPurge(t *testing.T) {
+	server := newTestServer()
+
+	b := decodeBoolean(t, serve(t, server, http.MethodPost, "/", `{"value": true, "key": "name"}`))
+	path := "/" + b.ID.String()
+
+	response := serve(t, server, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = serve(t, server, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	response = serve(t, server, http.MethodPost, path+"/restore", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"2"`, response.Header().Get("ETag"))
+	response = serve(t, server, http.MethodGet, "/keys/name", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = serve(t, server, http.MethodPost, path+"/restore", "")
+	assert.Equal(t, http.StatusConflict, response.Code)
+
+	// Default retention keeps a boolean deleted just now.
+	response = serve(t, server, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = serve(t, server, http.MethodPost, "/purge", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"purged": 0}`, response.Body.String())
+
+	response = serve(t, server, http.MethodPost, "/purge", `{"older_than": "0s"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"purged": 1}`, response.Body.String())
+	response = serve(t, server, http.MethodPost, path+"/restore", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestExpiry(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/", `{"value": true, "ttl": "100ms", "fallback": false}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, body, "expires_at")
+	assert.Equal(t, false, body["fallback"])
+	path := "/" + body["id"].(string)
+
+	response = serve(t, server, http.MethodPost, "/", `{"value": true, "key": "temporary", "ttl": "100ms"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	time.Sleep(150 * time.Millisecond)
+
+	// Expired boolean has its fallback value, or is gone when it has no fallback.
+	response = serve(t, server, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"2"`, response.Header().Get("ETag"))
+	reverted := decodeBoolean(t, response)
+	assert.Equal(t, path[1:], reverted.ID.String())
+	assert.Equal(t, false, reverted.Value)
+	assert.Equal(t, "", reverted.Key)
+	response = serve(t, server, http.MethodGet, "/keys/temporary", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	// History records expiry once it is stored by the background job.
+	expired, err := models.GetRepo().Expire(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), expired)
+	response = serve(t, server, http.MethodGet, path+"/history", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	history := historyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, history.History, 2) {
+		assert.Equal(t, "expire", history.History[0].Action)
+		assert.Equal(t, models.ExpiryActor, history.History[0].Actor)
+	}
+
+	response = serve(t, server, http.MethodPatch, path, `{"expires_at": "2000-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+type scheduleResponse struct {
+	ID     uuid.UUID `json:"id"`
+	Value  bool      `json:"value"`
+	Status string    `json:"status"`
+}
+
+func TestSchedules(t *testing.T) {
+	server := newTestServer()
+
+	b := decodeBoolean(t, serve(t, server, http.MethodPost, "/", `{"value": true}`))
+	path := "/" + b.ID.String()
+
+	at := time.Now().Add(100 * time.Millisecond).Format(time.RFC3339Nano)
+	response := serve(t, server, http.MethodPost, path+"/schedules", `{"value": false, "at": "`+at+`"}`)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	soon := scheduleResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &soon); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "pending", soon.Status)
+
+	at = time.Now().Add(time.Hour).Format(time.RFC3339Nano)
+	response = serve(t, server, http.MethodPost, path+"/schedules", `{"value": true, "at": "`+at+`"}`)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	later := scheduleResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &later); err != nil {
+		t.Fatal(err)
+	}
+
+	response = serve(t, server, http.MethodPost, path+"/schedules", `{"value": true, "at": "2000-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Schedule is applied by the background job once its time passes.
+	time.Sleep(110 * time.Millisecond)
+	applied, err := models.GetRepo().ApplySchedules(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), applied)
+	response = serve(t, server, http.MethodGet, path, "")
+	assert.Equal(t, false, decodeBoolean(t, response).Value)
+
+	response = serve(t, server, http.MethodGet, path+"/schedules", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	schedules := struct {
+		Schedules []scheduleResponse `json:"schedules"`
+	}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &schedules); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []scheduleResponse{later}, schedules.Schedules)
+
+	response = serve(t, server, http.MethodDelete, path+"/schedules/"+later.ID.String(), "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = serve(t, server, http.MethodDelete, path+"/schedules/"+soon.ID.String(), "")
+	assert.Equal(t, http.StatusConflict, response.Code)
+	response = serve(t, server, http.MethodDelete, path+"/schedules/"+uuid.New().String(), "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestMetadata(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/", `{"value": true, "description": "Dark mode", "owner": "web", "tags": ["ui"]}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	created := map[string]interface{}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, created["created_at"], created["updated_at"])
+	path := "/" + created["id"].(string)
+
+	response = serve(t, server, http.MethodPost, "/", `{"value": true, "owner": "ops", "tags": ["ui"]}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serve(t, server, http.MethodPost, "/", `{"value": true, "tags": ["bad tag"]}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	time.Sleep(2 * time.Millisecond)
+
+	response = serve(t, server, http.MethodPatch, path, `{"tags": ["ui", "beta"], "description": null}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	patched := map[string]interface{}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &patched); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, created["created_at"], patched["created_at"])
+	assert.NotEqual(t, created["updated_at"], patched["updated_at"])
+	assert.NotContains(t, patched, "description")
+
+	response = serve(t, server, http.MethodGet, "/?tag=ui&tag=beta", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	list := decodeList(t, response)
+	if assert.Len(t, list.Booleans, 1) {
+		assert.Equal(t, path[1:], list.Booleans[0].ID.String())
+		assert.Equal(t, "web", list.Booleans[0].Owner)
+		assert.Equal(t, models.Tags{"ui", "beta"}, list.Booleans[0].Tags)
+	}
+
+	response = serve(t, server, http.MethodGet, "/?owner=ops&tag=ui", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	list = decodeList(t, response)
+	if assert.Len(t, list.Booleans, 1) {
+		assert.Equal(t, "ops", list.Booleans[0].Owner)
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/", `{"value": false, "key": "name", "environments": {"dev": true}}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	created := decodeBoolean(t, response)
+	assert.Equal(t, models.EnvironmentValues{"dev": true}, created.Environments)
+	path := "/" + created.ID.String()
+	response = serve(t, server, http.MethodPost, "/", `{"value": false, "environments": {"qa": true}}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Value is resolved by environment in path or header, and environments without own value share value of the boolean.
+	response = serve(t, server, http.MethodGet, path+"/environments/dev", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.True(t, decodeBoolean(t, response).Value)
+	response = serveWithHeader(t, server, http.MethodGet, "/keys/name", "", controller.EnvironmentHeader, "prod")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.False(t, decodeBoolean(t, response).Value)
+	response = serve(t, server, http.MethodGet, path+"/environments/qa", "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Promotion to the next environment is a single change
+	response = serve(t, server, http.MethodPost, path+"/promote", `{"from": "dev"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, `"2"`, response.Header().Get("ETag"))
+	response = serveWithHeader(t, server, http.MethodPost, path+"/promote", `{"from": "staging", "to": "prod"}`, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serve(t, server, http.MethodPost, path+"/promote", `{"from": "prod"}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = serve(t, server, http.MethodGet, path+"/environments", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+created.ID.String()+`", "version": 3, "value": false, "same": true, "environments": [
+		{"name": "dev", "value": true, "own": true},
+		{"name": "staging", "value": true, "own": true},
+		{"name": "prod", "value": true, "own": true}
+	]}`, response.Body.String())
+
+	response = serve(t, server, http.MethodGet, path+"/history?limit=1", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	history := struct {
+		History []struct {
+			Action          string                   `json:"action"`
+			OldEnvironments models.EnvironmentValues `json:"old_environments"`
+			NewEnvironments models.EnvironmentValues `json:"new_environments"`
+		} `json:"history"`
+	}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, history.History, 1) {
+		assert.Equal(t, "promote", history.History[0].Action)
+		assert.Equal(t, models.EnvironmentValues{"dev": true, "staging": true}, history.History[0].OldEnvironments)
+		assert.Equal(t, models.EnvironmentValues{"dev": true, "staging": true, "prod": true}, history.History[0].NewEnvironments)
+	}
+
+	// Merge patch removes value of a single environment with null
+	response = serve(t, server, http.MethodPatch, path, `{"environments": {"prod": null}}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, models.EnvironmentValues{"dev": true, "staging": true}, decodeBoolean(t, response).Environments)
+}
+
+// namespaceResponse is body of response about a namespace.
+type namespaceResponse struct {
+	Name        string `json:"name"`
+	MaxBooleans int64  `json:"max_booleans"`
+	Booleans    int64  `json:"booleans"`
+}
+
+func TestNamespaces(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodGet, "/ns/team/", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, "NAMESPACE_NOT_FOUND", decodeError(t, response).Code)
+	response = serve(t, server, http.MethodPut, "/namespaces/Team", "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Create with default quota, then change it
+	response = serve(t, server, http.MethodPut, "/namespaces/team", "")
+	assert.Equal(t, http.StatusCreated, response.Code)
+	response = serve(t, server, http.MethodPost, "/ns/team/", `{"value": true}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serve(t, server, http.MethodPut, "/namespaces/team", `{"max_booleans": 5}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	response = serve(t, server, http.MethodGet, "/namespaces/team", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	ns := namespaceResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &ns); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, namespaceResponse{Name: "team", MaxBooleans: 5, Booleans: 1}, ns)
+
+	response = serve(t, server, http.MethodGet, "/namespaces", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	list := struct {
+		Namespaces []namespaceResponse `json:"namespaces"`
+	}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []namespaceResponse{{Name: models.DefaultNamespace}, {Name: "team", MaxBooleans: 5, Booleans: 1}}, list.Namespaces)
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	server := newTestServer()
+	serve(t, server, http.MethodPut, "/namespaces/a", "")
+	serve(t, server, http.MethodPut, "/namespaces/b", "")
+
+	response := serve(t, server, http.MethodPost, "/ns/a/", `{"value": true, "key": "name"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	path := "/" + decodeBoolean(t, response).ID.String()
+	response = serve(t, server, http.MethodPut, "/ns/b/keys/name", `{"value": false}`)
+	assert.Equal(t, http.StatusCreated, response.Code)
+
+	// Boolean is found only in its own namespace
+	response = serve(t, server, http.MethodGet, "/ns/a"+path, "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	for _, prefix := range []string{"/ns/b", "/ns/default", ""} {
+		response = serve(t, server, http.MethodGet, prefix+path, "")
+		assert.Equal(t, http.StatusNotFound, response.Code)
+		response = serve(t, server, http.MethodPost, prefix+path+"/toggle", "")
+		assert.Equal(t, http.StatusNotFound, response.Code)
+		response = serve(t, server, http.MethodDelete, prefix+path, "")
+		assert.Equal(t, http.StatusNotFound, response.Code)
+	}
+
+	// Keys are unique only within a namespace
+	response = serve(t, server, http.MethodGet, "/ns/a/keys/name", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.True(t, decodeBoolean(t, response).Value)
+	response = serve(t, server, http.MethodGet, "/ns/b/keys/name", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.False(t, decodeBoolean(t, response).Value)
+
+	response = serve(t, server, http.MethodGet, "/ns/b/", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Len(t, decodeList(t, response).Booleans, 1)
+	response = serve(t, server, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, decodeList(t, response).Booleans)
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	server := newTestServer()
+	serve(t, server, http.MethodPut, "/namespaces/small", `{"max_booleans": 1}`)
+
+	response := serve(t, server, http.MethodPost, "/ns/small/", `{"value": true}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	path := "/ns/small/" + decodeBoolean(t, response).ID.String()
+
+	response = serve(t, server, http.MethodPost, "/ns/small/", `{"value": true}`)
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Equal(t, "QUOTA_EXCEEDED", decodeError(t, response).Code)
+	response = serve(t, server, http.MethodPut, "/ns/small/keys/name", `{"value": true}`)
+	assert.Equal(t, http.StatusConflict, response.Code)
+
+	// Deleting a boolean makes room for another one
+	response = serve(t, server, http.MethodDelete, path, "")
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = serve(t, server, http.MethodPut, "/ns/small/keys/name", `{"value": true}`)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	response = serve(t, server, http.MethodPost, path+"/restore", "")
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Equal(t, "QUOTA_EXCEEDED", decodeError(t, response).Code)
+}
+
+// apiKeyResponse is body of response about an API key.
+type apiKeyResponse struct {
+	ID     uuid.UUID `json:"id"`
+	Key    string    `json:"key"`
+	Prefix string    `json:"prefix"`
+}
+
+// createAPIKey creates API key with given scopes through server, and returns the key itself.
+func createAPIKey(t *testing.T, server *gin.Engine, scopes string) apiKeyResponse {
+	response := serve(t, server, http.MethodPost, "/api-keys", `{"name": "client", "scopes": [`+scopes+`]}`)
+	if !assert.Equal(t, http.StatusCreated, response.Code) {
+		t.FailNow()
+	}
+	created := apiKeyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func TestAuthentication(t *testing.T) {
+	server := newTestServer()
+
+	response := serveWithHeader(t, server, http.MethodGet, "/", "", controller.APIKeyHeader, "")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Equal(t, "UNAUTHORIZED", decodeError(t, response).Code)
+	assert.NotEmpty(t, decodeError(t, response).RequestID)
+	response = serveWithHeader(t, server, http.MethodGet, "/", "", controller.APIKeyHeader, models.APIKeyPrefix+"unknown")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// Key is also accepted as a bearer token.
+	reader := createAPIKey(t, server, `"read"`)
+	response = serveWithAuthorization(t, server, http.MethodGet, "/", "", "Bearer "+reader.Key)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// Admin key is not stored, and it is enough to create the first stored key.
+	if err := controller.SetAdminKey("0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	defer controller.SetAdminKey("")
+	response = serveWithHeader(t, server, http.MethodGet, "/api-keys", "", controller.APIKeyHeader, "0123456789abcdef")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Error(t, controller.SetAdminKey("short"))
+}
+
+func TestScopes(t *testing.T) {
+	server := newTestServer()
+	reader := createAPIKey(t, server, `"read"`)
+	writer := createAPIKey(t, server, `"write"`)
+
+	response := serveWithHeader(t, server, http.MethodPost, "/", `{"value": true}`, controller.APIKeyHeader, reader.Key)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	assert.Equal(t, "FORBIDDEN", decodeError(t, response).Code)
+
+	response = serveWithHeader(t, server, http.MethodPost, "/", `{"value": true}`, controller.APIKeyHeader, writer.Key)
+	assert.Equal(t, http.StatusOK, response.Code)
+	path := "/" + decodeBoolean(t, response).ID.String()
+
+	// Write scope also reads, but it does not purge or manage keys and namespaces.
+	response = serveWithHeader(t, server, http.MethodGet, path, "", controller.APIKeyHeader, writer.Key)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serveWithHeader(t, server, http.MethodPost, "/batch-get", `{"ids": ["`+path[1:]+`"]}`, controller.APIKeyHeader, reader.Key)
+	assert.Equal(t, http.StatusOK, response.Code)
+	for _, request := range []struct{ method, path string }{
+		{http.MethodPost, "/purge"},
+		{http.MethodPut, "/namespaces/team"},
+		{http.MethodGet, "/api-keys"},
+		{http.MethodPost, "/api-keys/" + reader.ID.String() + "/rotate"},
+	} {
+		response = serveWithHeader(t, server, request.method, request.path, "", controller.APIKeyHeader, writer.Key)
+		assert.Equal(t, http.StatusForbidden, response.Code, request.path)
+	}
+	response = serveWithHeader(t, server, http.MethodGet, "/ns/default/"+path[1:], "", controller.APIKeyHeader, reader.Key)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serveWithHeader(t, server, http.MethodDelete, "/ns/default/"+path[1:], "", controller.APIKeyHeader, reader.Key)
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
+
+// useTokens makes server accept tokens signed by key until the test ends, and returns Authorization header
+// with a token of subject which has roles.
+func useTokens(t *testing.T, key authtest.Key) func(subject string, roles ...string) string {
+	jwks, err := auth.NewJWKS(authtest.NewServer(t, key).URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller.SetTokenVerifier(&auth.Verifier{Keys: jwks, Issuer: authtest.Issuer, Audience: authtest.Audience})
+	t.Cleanup(func() { controller.SetTokenVerifier(nil) })
+	return func(subject string, roles ...string) string {
+		return "Bearer " + authtest.Sign(t, key, "RS256", authtest.Claims(subject, roles...))
+	}
+}
+
+func TestTokens(t *testing.T) {
+	server := newTestServer()
+	key := authtest.NewRSAKey(t, "key")
+	token := useTokens(t, key)
+	bearer := func(roles ...string) string {
+		return token("alice", roles...)
+	}
+
+	response := serveWithAuthorization(t, server, http.MethodPost, "/", `{"value": true}`, bearer("write"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	path := "/" + decodeBoolean(t, response).ID.String()
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", bearer("read"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodGet, path, "", bearer())
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	// Principal of the token is recorded in history, along with actor named by client.
+	response = serveWithAuthorization(t, server, http.MethodGet, path+"/history", "", bearer("read"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	history := historyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, history.History, 1) {
+		assert.Equal(t, "token:alice", history.History[0].Principal)
+		assert.Equal(t, "anonymous", history.History[0].Actor)
+	}
+
+	// Tokens of other identity providers are rejected, and API keys work along with tokens.
+	other := authtest.NewRSAKey(t, "key")
+	response = serveWithAuthorization(t, server, http.MethodGet, path, "", "Bearer "+authtest.Sign(t, other, "RS256", authtest.Claims("alice", "read")))
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodGet, path, "", "Bearer "+testKey)
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+// policyResponse is body of response to policy evaluation.
+type policyResponse struct {
+	Subject string      `json:"subject"`
+	Allowed bool        `json:"allowed"`
+	Role    models.Role `json:"role"`
+	Reason  string      `json:"reason"`
+}
+
+// evaluate asks server whether subject may take action on boolean with id of namespace team,
+// as principal with authorization, and returns the decision.
+func evaluate(t *testing.T, server *gin.Engine, authorization string, subject string, action string, id string) policyResponse {
+	body := fmt.Sprintf(`{"subject": %q, "roles": ["developers"], "action": %q, "namespace": "team", "id": %q}`, subject, action, id)
+	response := serveWithAuthorization(t, server, http.MethodPost, "/policy/evaluate", body, authorization)
+	decision := policyResponse{}
+	if assert.Equal(t, http.StatusOK, response.Code, response.Body.String()) {
+		if err := json.Unmarshal(response.Body.Bytes(), &decision); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return decision
+}
+
+func TestRoles(t *testing.T) {
+	server := newTestServer()
+	bearer := useTokens(t, authtest.NewRSAKey(t, "key"))
+	admin := "Bearer " + testKey
+	response := serve(t, server, http.MethodPut, "/namespaces/team", "")
+	assert.Equal(t, http.StatusCreated, response.Code)
+
+	// Token without scopes has no role until one is granted in a namespace.
+	response = serveWithAuthorization(t, server, http.MethodGet, "/ns/team/", "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	errorResponse := decodeError(t, response)
+	assert.Equal(t, "FORBIDDEN", errorResponse.Code)
+	if assert.Len(t, errorResponse.Details, 1) {
+		assert.Equal(t, "principal", errorResponse.Details[0].Field)
+	}
+
+	response = serveWithAuthorization(t, server, http.MethodPut, "/namespaces/team/roles", `{"subject": "role:developers", "role": "editor"}`, admin)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPut, "/namespaces/team/roles", `{"subject": "token:bob", "role": "viewer"}`, admin)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPut, "/namespaces/team/roles", `{"subject": "token:bob", "role": "owner"}`, admin)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Roles apply only in their namespace.
+	response = serveWithAuthorization(t, server, http.MethodPost, "/ns/team/", `{"value": true}`, bearer("alice", "developers"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	path := "/ns/team/" + decodeBoolean(t, response).ID.String()
+	response = serveWithAuthorization(t, server, http.MethodGet, "/", "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodGet, path, "", bearer("bob"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", bearer("bob"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	// Only admins manage roles.
+	response = serveWithAuthorization(t, server, http.MethodGet, "/namespaces/team/roles", "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodGet, "/namespaces/team/roles", "", admin)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"subject":"role:developers"`)
+
+	response = serveWithAuthorization(t, server, http.MethodDelete, "/namespaces/team/roles?subject=token:bob", "", admin)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodDelete, "/namespaces/team/roles?subject=token:bob", "", admin)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, "ROLE_BINDING_NOT_FOUND", decodeError(t, response).Code)
+	response = serveWithAuthorization(t, server, http.MethodGet, path, "", bearer("bob"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestAccessLists(t *testing.T) {
+	server := newTestServer()
+	bearer := useTokens(t, authtest.NewRSAKey(t, "key"))
+	admin := "Bearer " + testKey
+	serve(t, server, http.MethodPut, "/namespaces/team", "")
+	serveWithAuthorization(t, server, http.MethodPut, "/namespaces/team/roles", `{"subject": "role:developers", "role": "editor"}`, admin)
+	response := serveWithAuthorization(t, server, http.MethodPut, "/ns/team/keys/payments", `{"value": true}`, admin)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	id := decodeBoolean(t, response).ID.String()
+	path := "/ns/team/" + id
+
+	// Only admins set access lists, and every editor reads them.
+	response = serveWithAuthorization(t, server, http.MethodPut, path+"/acl", `{"subjects": ["token:carol"]}`, bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPut, path+"/acl", `{"subjects": ["bob"]}`, admin)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPut, path+"/acl", `{"subjects": ["token:carol"]}`, admin)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodGet, path+"/acl", "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"id": "`+id+`", "subjects": ["token:carol"]}`, response.Body.String())
+
+	// Editors which the list does not have can not change the boolean in any way, while admins still can.
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPut, "/ns/team/keys/payments", `{"value": false}`, bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPost, "/ns/team/bulk", `{"operations": [{"op": "create", "value": true}, {"op": "delete", "id": "`+id+`"}]}`, bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	errorResponse := decodeError(t, response)
+	if assert.Len(t, errorResponse.Details, 1) {
+		assert.Equal(t, "operations[1]", errorResponse.Details[0].Field)
+	}
+	response = serveWithAuthorization(t, server, http.MethodGet, path, "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.True(t, decodeBoolean(t, response).Value)
+
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", bearer("carol", "developers"))
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", admin)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// Listed subject still needs editor role.
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", bearer("carol"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	// Empty list lets every editor change the boolean again.
+	response = serveWithAuthorization(t, server, http.MethodPut, path+"/acl", `{"subjects": []}`, admin)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = serveWithAuthorization(t, server, http.MethodPost, path+"/toggle", "", bearer("alice", "developers"))
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestPolicyEvaluation(t *testing.T) {
+	server := newTestServer()
+	bearer := useTokens(t, authtest.NewRSAKey(t, "key"))
+	admin := "Bearer " + testKey
+	serve(t, server, http.MethodPut, "/namespaces/team", "")
+	serveWithAuthorization(t, server, http.MethodPut, "/namespaces/team/roles", `{"subject": "role:developers", "role": "editor"}`, admin)
+	response := serveWithAuthorization(t, server, http.MethodPost, "/ns/team/", `{"value": true}`, admin)
+	id := decodeBoolean(t, response).ID.String()
+	serveWithAuthorization(t, server, http.MethodPut, "/ns/team/"+id+"/acl", `{"subjects": ["token:carol"]}`, admin)
+
+	// Principal may ask about itself, and decisions are explained.
+	decision := evaluate(t, server, bearer("alice", "developers"), "", "write", "")
+	assert.Equal(t, policyResponse{Subject: "token:alice", Allowed: true, Role: models.RoleEditor, Reason: decision.Reason}, decision)
+	assert.Contains(t, decision.Reason, "role:developers")
+	decision = evaluate(t, server, bearer("alice", "developers"), "", "write", id)
+	assert.False(t, decision.Allowed)
+	assert.Contains(t, decision.Reason, "access list")
+	decision = evaluate(t, server, bearer("alice", "developers"), "", "admin", "")
+	assert.False(t, decision.Allowed)
+
+	// Only admins may ask about others, which are tokens with given roles or API keys with their scopes.
+	response = serveWithAuthorization(t, server, http.MethodPost, "/policy/evaluate", `{"subject": "token:carol", "action": "write"}`, bearer("alice", "developers"))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+	decision = evaluate(t, server, admin, "token:carol", "write", id)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "token:carol", decision.Subject)
+	decision = evaluate(t, server, admin, "api_key:test", "admin", id)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, models.RoleAdmin, decision.Role)
+
+	for _, body := range []string{
+		`{"action": "delete"}`,
+		`{"action": "read", "namespace": "Team"}`,
+		`{"action": "read", "id": "1"}`,
+		`{"subject": "role:developers", "action": "read"}`,
+	} {
+		response = serveWithAuthorization(t, server, http.MethodPost, "/policy/evaluate", body, admin)
+		assert.Equal(t, http.StatusBadRequest, response.Code, body)
+	}
+	response = serveWithAuthorization(t, server, http.MethodPost, "/policy/evaluate", `{"subject": "api_key:missing", "action": "read"}`, admin)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestAPIKeys(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodPost, "/api-keys", `{"name": "client", "scopes": ["owner"]}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	response = serve(t, server, http.MethodPost, "/api-keys", `{"scopes": ["read"]}`)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	created := createAPIKey(t, server, `"read", "write"`)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+
+	// Listed keys never show the key itself.
+	response = serve(t, server, http.MethodGet, "/api-keys", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.NotContains(t, response.Body.String(), created.Key)
+	listed := struct {
+		APIKeys []map[string]interface{} `json:"api_keys"`
+	}{}
+	if err := json.Unmarshal(response.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, listed.APIKeys, 2) {
+		assert.Equal(t, created.ID.String(), listed.APIKeys[1]["id"])
+		assert.Equal(t, []interface{}{"read", "write"}, listed.APIKeys[1]["scopes"])
+		assert.NotContains(t, listed.APIKeys[1], "key")
+	}
+
+	// Rotation gives a new key, and the old one stops working at once.
+	response = serve(t, server, http.MethodPost, "/api-keys/"+created.ID.String()+"/rotate", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	rotated := apiKeyResponse{}
+	if err := json.Unmarshal(response.Body.Bytes(), &rotated); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, created.ID, rotated.ID)
+	response = serveWithHeader(t, server, http.MethodGet, "/", "", controller.APIKeyHeader, created.Key)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serveWithHeader(t, server, http.MethodGet, "/", "", controller.APIKeyHeader, rotated.Key)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// Revoked key is listed, but it does not work and can not be rotated.
+	response = serve(t, server, http.MethodDelete, "/api-keys/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"revoked_at":"`)
+	response = serveWithHeader(t, server, http.MethodGet, "/", "", controller.APIKeyHeader, rotated.Key)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serve(t, server, http.MethodPost, "/api-keys/"+created.ID.String()+"/rotate", "")
+	assert.Equal(t, http.StatusConflict, response.Code)
+
+	response = serve(t, server, http.MethodDelete, "/api-keys/"+uuid.New().String(), "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, "API_KEY_NOT_FOUND", decodeError(t, response).Code)
+	response = serve(t, server, http.MethodDelete, "/api-keys/invalid", "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestNoRoute(t *testing.T) {
+	server := newTestServer()
+
+	response := serve(t, server, http.MethodGet, "/some/unknown/path", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	requestID := response.Header().Get(controller.RequestIDHeader)
+	assert.NotEmpty(t, requestID)
+	assert.JSONEq(t, `{"code": "PAGE_NOT_FOUND", "message": "Page not found", "request_id": "`+requestID+`"}`, response.Body.String())
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	server := newTestServer()
+
+	request, err := http.NewRequest(http.MethodGet, "/"+uuid.New().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(controller.APIKeyHeader, testKey)
+	request.Header.Set(controller.RequestIDHeader, "client-request-id")
+
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	assert.Equal(t, "client-request-id", response.Header().Get(controller.RequestIDHeader))
+	assert.JSONEq(t, `{"code": "NOT_FOUND", "message": "Boolean not found", "request_id": "client-request-id"}`, response.Body.String())
+}